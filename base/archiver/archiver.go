@@ -0,0 +1,276 @@
+package archiver
+
+import (
+	"context"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.shanhai.int/sre/library/base/ctime"
+)
+
+const (
+	// 默认重试次数
+	_defaultRetry = 3
+	// 默认重试间隔
+	_defaultRetryInterval = time.Second
+	// 默认单次上传超时时间
+	_defaultTimeout = 5 * time.Minute
+	// 上传队列大小
+	_queueSize = 128
+)
+
+// 不重试，用于Config.Retry，为0时使用默认重试次数
+const NoRetry = -1
+
+// 归档配置
+type Config struct {
+	// 对象键前缀，对象键为 前缀/主机名/文件名
+	Prefix string `yaml:"prefix"`
+	// 失败后的重试次数，默认3，小于0(如NoRetry)时不重试
+	Retry int `yaml:"retry"`
+	// 重试间隔，每次重试翻倍，默认1s
+	RetryInterval ctime.Duration `yaml:"retryInterval"`
+	// 单次上传超时时间，默认5m
+	Timeout ctime.Duration `yaml:"timeout"`
+	// 确认上传后是否保留本地文件，默认删除
+	KeepLocal bool `yaml:"keepLocal"`
+
+	// S3存储配置，需导入s3子包
+	S3 *S3Config `yaml:"s3"`
+	// 文件系统存储目录，S3为空时使用
+	Dir string `yaml:"dir"`
+}
+
+// 归档统计
+type Stats struct {
+	// 上传成功的文件数
+	Uploaded uint64
+	// 重试次数
+	Retried uint64
+	// 最终失败的文件数
+	Failed uint64
+	// 上传队列已满时未加入队列的文件数
+	Dropped uint64
+}
+
+// 分割文件记录，如filewriter.FileWriter
+type Forgetter interface {
+	// 忽略已被删除的分割文件
+	Forget(path string)
+}
+
+// 归档器
+//
+//	作为filewriter的分割回调使用，在后台协程中按顺序上传分割后的文件
+//	上传前计算md5，上传后比对存储端的md5，确认一致后才删除本地文件
+//	最终失败的文件保留在本地
+type Archiver struct {
+	// 配置
+	config Config
+	// 存储后端
+	storage Storage
+	// 主机名
+	hostname string
+	// 日志
+	stdlog *log.Logger
+
+	// 上传队列
+	queue chan string
+	// 保护closed及队列关闭
+	mu sync.RWMutex
+	// 是否关闭
+	closed bool
+	// 协程wait group
+	wg sync.WaitGroup
+	// 删除本地文件后需通知的分割文件记录
+	forgetters []Forgetter
+
+	// 统计
+	uploaded uint64
+	retried  uint64
+	failed   uint64
+	dropped  uint64
+}
+
+// 按配置新建归档器，配置S3时使用S3存储(需导入s3子包)，否则使用文件系统存储
+func New(config *Config) (*Archiver, error) {
+	if config == nil {
+		return nil, errors.New("archiver config can't be nil")
+	}
+	var (
+		storage Storage
+		err     error
+	)
+	if config.S3 != nil {
+		storage, err = newS3Storage(config.S3)
+	} else {
+		storage, err = NewFileStorage(config.Dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewWithStorage(storage, config), nil
+}
+
+// 使用指定存储后端新建归档器
+func NewWithStorage(storage Storage, config *Config) *Archiver {
+	if config == nil {
+		config = &Config{}
+	}
+	a := &Archiver{
+		config:  *config,
+		storage: storage,
+		stdlog:  log.New(os.Stderr, "archiver ", log.LstdFlags),
+		queue:   make(chan string, _queueSize),
+	}
+	if a.config.Retry == 0 {
+		a.config.Retry = _defaultRetry
+	}
+	if a.config.RetryInterval <= 0 {
+		a.config.RetryInterval = ctime.Duration(_defaultRetryInterval)
+	}
+	if a.config.Timeout <= 0 {
+		a.config.Timeout = ctime.Duration(_defaultTimeout)
+	}
+	a.hostname, _ = os.Hostname()
+
+	a.wg.Add(1)
+	go a.daemon()
+	return a
+}
+
+// 分割回调，加入上传队列，可直接用于filewriter.OnRotate
+//
+//	队列已满时不阻塞调用方，跳过该文件并保留在本地
+func (a *Archiver) OnRotate(fpath string) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		a.stdlog.Printf("archiver already closed, skip %s", fpath)
+		return
+	}
+	select {
+	case a.queue <- fpath:
+	default:
+		atomic.AddUint64(&a.dropped, 1)
+		a.stdlog.Printf("archiver queue is full, skip %s", fpath)
+	}
+}
+
+// 增加分割文件记录，删除本地文件后调用其Forget方法，避免filewriter清理已删除的文件
+func (a *Archiver) AddForgetter(f Forgetter) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.forgetters = append(a.forgetters, f)
+}
+
+// 上传队列守护方法
+func (a *Archiver) daemon() {
+	defer a.wg.Done()
+	for fpath := range a.queue {
+		if err := a.Archive(context.Background(), fpath); err != nil {
+			a.stdlog.Printf("archive %s error: %s", fpath, err)
+		}
+	}
+}
+
+// 上传文件，失败时按配置重试，确认上传后删除本地文件
+func (a *Archiver) Archive(ctx context.Context, fpath string) error {
+	checksum, err := fileChecksum(fpath)
+	if err != nil {
+		atomic.AddUint64(&a.failed, 1)
+		return errors.Wrap(err, "checksum error")
+	}
+	key := a.Key(fpath)
+
+	interval := time.Duration(a.config.RetryInterval)
+	for i := 0; ; i++ {
+		if err = a.upload(ctx, key, fpath, checksum); err == nil {
+			break
+		}
+		if i >= a.config.Retry {
+			atomic.AddUint64(&a.failed, 1)
+			return err
+		}
+		atomic.AddUint64(&a.retried, 1)
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			atomic.AddUint64(&a.failed, 1)
+			return ctx.Err()
+		}
+		interval *= 2
+	}
+	atomic.AddUint64(&a.uploaded, 1)
+
+	if a.config.KeepLocal {
+		return nil
+	}
+	if err = os.Remove(fpath); err != nil {
+		return err
+	}
+	a.mu.RLock()
+	forgetters := a.forgetters
+	a.mu.RUnlock()
+	for _, f := range forgetters {
+		f.Forget(fpath)
+	}
+	return nil
+}
+
+// 单次上传并确认
+func (a *Archiver) upload(ctx context.Context, key, fpath, checksum string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(a.config.Timeout))
+	defer cancel()
+
+	if err := a.storage.Upload(ctx, key, fpath, checksum); err != nil {
+		return errors.Wrap(err, "upload error")
+	}
+	remote, err := a.storage.Checksum(ctx, key)
+	if err != nil {
+		return errors.Wrap(err, "confirm error")
+	}
+	if remote != checksum {
+		return errors.Errorf("checksum mismatch, local %s, remote %s", checksum, remote)
+	}
+	return nil
+}
+
+// 获取文件对应的对象键
+func (a *Archiver) Key(fpath string) string {
+	return path.Join(a.config.Prefix, a.hostname, filepath.Base(fpath))
+}
+
+// 获取统计数据
+func (a *Archiver) Stats() Stats {
+	return Stats{
+		Uploaded: atomic.LoadUint64(&a.uploaded),
+		Retried:  atomic.LoadUint64(&a.retried),
+		Failed:   atomic.LoadUint64(&a.failed),
+		Dropped:  atomic.LoadUint64(&a.dropped),
+	}
+}
+
+// 关闭，等待队列中的文件上传完成
+func (a *Archiver) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.queue)
+	a.mu.Unlock()
+
+	a.wg.Wait()
+	return nil
+}
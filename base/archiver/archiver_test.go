@@ -0,0 +1,241 @@
+package archiver
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.shanhai.int/sre/library/base/ctime"
+	"gitlab.shanhai.int/sre/library/base/filewriter"
+)
+
+// 新建临时目录及待上传文件
+func prepare(t *testing.T, content string) (string, string) {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fpath := filepath.Join(dir, "info.log.2020-01-02")
+	if err := ioutil.WriteFile(fpath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, fpath
+}
+
+// 前N次上传失败的存储后端
+type flakyStorage struct {
+	Storage
+	mu       sync.Mutex
+	failures int
+	// 返回错误的md5
+	badChecksum bool
+}
+
+func (s *flakyStorage) Upload(ctx context.Context, key, path, checksum string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("connection reset")
+	}
+	return s.Storage.Upload(ctx, key, path, checksum)
+}
+
+func (s *flakyStorage) Checksum(ctx context.Context, key string) (string, error) {
+	if s.badChecksum {
+		return "0000", nil
+	}
+	return s.Storage.Checksum(ctx, key)
+}
+
+func TestArchiver_Archive(t *testing.T) {
+	dir, fpath := prepare(t, "hello archiver\n")
+	defer os.RemoveAll(dir)
+	storageDir := filepath.Join(dir, "storage")
+	config := &Config{
+		Prefix:        "logs",
+		RetryInterval: ctime.Duration(time.Millisecond),
+		Dir:           storageDir,
+	}
+
+	t.Run("file storage", func(t *testing.T) {
+		a, err := New(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer a.Close()
+
+		assert.Nil(t, a.Archive(context.Background(), fpath))
+		b, err := ioutil.ReadFile(filepath.Join(storageDir, filepath.FromSlash(a.Key(fpath))))
+		assert.Nil(t, err)
+		assert.Equal(t, "hello archiver\n", string(b))
+		// 确认上传后删除本地文件
+		_, err = os.Stat(fpath)
+		assert.True(t, os.IsNotExist(err))
+		assert.Equal(t, Stats{Uploaded: 1}, a.Stats())
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		_, fpath := prepare(t, "retry\n")
+		defer os.RemoveAll(filepath.Dir(fpath))
+		storage, err := NewFileStorage(storageDir)
+		assert.Nil(t, err)
+		a := NewWithStorage(&flakyStorage{Storage: storage, failures: 2}, config)
+		defer a.Close()
+
+		assert.Nil(t, a.Archive(context.Background(), fpath))
+		assert.Equal(t, Stats{Uploaded: 1, Retried: 2}, a.Stats())
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		_, fpath := prepare(t, "failed\n")
+		defer os.RemoveAll(filepath.Dir(fpath))
+		storage, err := NewFileStorage(storageDir)
+		assert.Nil(t, err)
+		a := NewWithStorage(&flakyStorage{Storage: storage, failures: 10}, config)
+		defer a.Close()
+
+		assert.NotNil(t, a.Archive(context.Background(), fpath))
+		assert.Equal(t, Stats{Retried: 3, Failed: 1}, a.Stats())
+		// 上传失败时保留本地文件
+		_, err = os.Stat(fpath)
+		assert.Nil(t, err)
+	})
+
+	t.Run("no retry", func(t *testing.T) {
+		_, fpath := prepare(t, "no retry\n")
+		defer os.RemoveAll(filepath.Dir(fpath))
+		storage, err := NewFileStorage(storageDir)
+		assert.Nil(t, err)
+		a := NewWithStorage(&flakyStorage{Storage: storage, failures: 1}, &Config{Retry: NoRetry})
+		defer a.Close()
+
+		assert.NotNil(t, a.Archive(context.Background(), fpath))
+		assert.Equal(t, Stats{Failed: 1}, a.Stats())
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		_, fpath := prepare(t, "mismatch\n")
+		defer os.RemoveAll(filepath.Dir(fpath))
+		storage, err := NewFileStorage(storageDir)
+		assert.Nil(t, err)
+		a := NewWithStorage(&flakyStorage{Storage: storage, badChecksum: true}, &Config{Retry: NoRetry})
+		defer a.Close()
+
+		err = a.Archive(context.Background(), fpath)
+		assert.Contains(t, err.Error(), "checksum mismatch")
+		_, err = os.Stat(fpath)
+		assert.Nil(t, err)
+	})
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil)
+	assert.NotNil(t, err)
+	// 未导入s3子包
+	_, err = New(&Config{S3: &S3Config{Bucket: "logs"}})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "s3 storage not registered")
+	}
+}
+
+func TestArchiver_OnRotate(t *testing.T) {
+	dir, fpath := prepare(t, "rotated\n")
+	defer os.RemoveAll(dir)
+	storageDir := filepath.Join(dir, "storage")
+
+	a, err := New(&Config{Dir: storageDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 可直接作为filewriter的分割回调
+	_ = filewriter.OnRotate(a.OnRotate)
+
+	a.OnRotate(fpath)
+	// 关闭时等待队列中的文件上传完成
+	assert.Nil(t, a.Close())
+	assert.Equal(t, Stats{Uploaded: 1}, a.Stats())
+	_, err = os.Stat(filepath.Join(storageDir, filepath.FromSlash(a.Key(fpath))))
+	assert.Nil(t, err)
+
+	// 关闭后忽略
+	a.OnRotate(fpath)
+	assert.Equal(t, Stats{Uploaded: 1}, a.Stats())
+}
+
+// 上传阻塞直到放行的存储后端
+type blockingStorage struct {
+	Storage
+	gate chan struct{}
+}
+
+func (s *blockingStorage) Upload(ctx context.Context, key, path, checksum string) error {
+	<-s.gate
+	return s.Storage.Upload(ctx, key, path, checksum)
+}
+
+func TestArchiver_OnRotateFull(t *testing.T) {
+	dir, fpath := prepare(t, "full\n")
+	defer os.RemoveAll(dir)
+	storage, err := NewFileStorage(filepath.Join(dir, "storage"))
+	assert.Nil(t, err)
+	blocking := &blockingStorage{Storage: storage, gate: make(chan struct{})}
+	a := NewWithStorage(blocking, &Config{KeepLocal: true})
+
+	// 上传阻塞时队列写满，之后的文件跳过而不阻塞调用方
+	for i := 0; i < _queueSize+1; i++ {
+		a.OnRotate(fpath)
+	}
+	done := make(chan struct{})
+	go func() {
+		a.OnRotate(fpath)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("OnRotate blocked")
+	}
+	assert.True(t, a.Stats().Dropped >= 1)
+
+	close(blocking.gate)
+	assert.Nil(t, a.Close())
+	stats := a.Stats()
+	assert.Equal(t, uint64(_queueSize+2), stats.Uploaded+stats.Dropped)
+}
+
+// 记录被忽略的文件
+type recordForgetter struct {
+	paths []string
+}
+
+func (f *recordForgetter) Forget(path string) {
+	f.paths = append(f.paths, path)
+}
+
+func TestArchiver_AddForgetter(t *testing.T) {
+	dir, fpath := prepare(t, "forget\n")
+	defer os.RemoveAll(dir)
+
+	a, err := New(&Config{Dir: filepath.Join(dir, "storage"), KeepLocal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	forgetter := new(recordForgetter)
+	a.AddForgetter(forgetter)
+
+	// 保留本地文件时不通知
+	assert.Nil(t, a.Archive(context.Background(), fpath))
+	assert.Empty(t, forgetter.paths)
+
+	a.config.KeepLocal = false
+	assert.Nil(t, a.Archive(context.Background(), fpath))
+	assert.Equal(t, []string{fpath}, forgetter.paths)
+}
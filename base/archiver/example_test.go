@@ -0,0 +1,41 @@
+package archiver
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func ExampleNew() {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "info.log.2020-01-02")
+	if err := ioutil.WriteFile(fpath, []byte("hello\n"), 0644); err != nil {
+		return
+	}
+
+	// 未配置S3时使用文件系统存储
+	a, err := New(&Config{
+		Prefix: "logs",
+		Dir:    filepath.Join(dir, "storage"),
+	})
+	if err != nil {
+		return
+	}
+	defer a.Close()
+
+	if err := a.Archive(context.Background(), fpath); err != nil {
+		return
+	}
+	_, err = os.Stat(fpath)
+	fmt.Printf("%+v %v\n", a.Stats(), os.IsNotExist(err))
+
+	// Output:
+	// {Uploaded:1 Retried:0 Failed:0 Dropped:0} true
+}
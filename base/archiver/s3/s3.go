@@ -0,0 +1,106 @@
+package s3
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"gitlab.shanhai.int/sre/library/base/archiver"
+)
+
+// 对象元数据中的md5键
+const _metadataMD5 = "md5"
+
+// 导入后注册至archiver，配置archiver.Config.S3时使用
+func init() {
+	archiver.RegisterS3Storage(func(config *archiver.S3Config) (archiver.Storage, error) {
+		return New(config)
+	})
+}
+
+// S3存储后端，兼容MinIO、OSS、COS等S3协议的对象存储
+type Storage struct {
+	// 客户端
+	client *awss3.S3
+	// 存储桶
+	bucket string
+}
+
+// 新建S3存储后端
+func New(config *archiver.S3Config) (*Storage, error) {
+	if config == nil || config.Bucket == "" {
+		return nil, errors.New("s3 bucket can't be empty")
+	}
+	region := config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	awsConfig := &aws.Config{
+		Region:           aws.String(region),
+		S3ForcePathStyle: aws.Bool(config.ForcePathStyle),
+	}
+	if config.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(config.Endpoint)
+	}
+	if config.AccessKey != "" {
+		awsConfig.Credentials = credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, "")
+	}
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "create s3 session error")
+	}
+	return &Storage{
+		client: awss3.New(sess),
+		bucket: config.Bucket,
+	}, nil
+}
+
+// 上传文件，通过Content-MD5由存储端校验内容，并将md5写入对象元数据
+//
+//	单次上传，文件大小不能超过5G
+func (s *Storage) Upload(ctx context.Context, key, path, checksum string) error {
+	sum, err := hex.DecodeString(checksum)
+	if err != nil {
+		return errors.Wrapf(err, "invalid checksum %s", checksum)
+	}
+	fp, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	_, err = s.client.PutObjectWithContext(ctx, &awss3.PutObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(key),
+		Body:       fp,
+		ContentMD5: aws.String(base64.StdEncoding.EncodeToString(sum)),
+		Metadata: map[string]*string{
+			_metadataMD5: aws.String(checksum),
+		},
+	})
+	return err
+}
+
+// 获取对象元数据中的md5，不存在时使用ETag
+func (s *Storage) Checksum(ctx context.Context, key string) (string, error) {
+	out, err := s.client.HeadObjectWithContext(ctx, &awss3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", err
+	}
+	for k, v := range out.Metadata {
+		if strings.EqualFold(k, _metadataMD5) && v != nil {
+			return *v, nil
+		}
+	}
+	return strings.Trim(aws.StringValue(out.ETag), `"`), nil
+}
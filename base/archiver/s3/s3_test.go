@@ -0,0 +1,90 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.shanhai.int/sre/library/base/archiver"
+)
+
+// 模拟S3协议的对象存储，校验Content-MD5并保存元数据
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	md5s    map[string]string
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		sum := md5.Sum(body)
+		if r.Header.Get("Content-MD5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.objects[r.URL.Path] = body
+		s.md5s[r.URL.Path] = r.Header.Get("X-Amz-Meta-Md5")
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	case http.MethodHead:
+		if _, ok := s.objects[r.URL.Path]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Amz-Meta-Md5", s.md5s[r.URL.Path])
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestStorage(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string][]byte), md5s: make(map[string]string)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fpath := filepath.Join(dir, "info.log.2020-01-02")
+	if err := ioutil.WriteFile(fpath, []byte("hello s3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := archiver.New(&archiver.Config{
+		Prefix: "logs",
+		S3: &archiver.S3Config{
+			Endpoint:       server.URL,
+			Bucket:         "archive",
+			AccessKey:      "minio",
+			SecretKey:      "minio123",
+			ForcePathStyle: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	assert.Nil(t, a.Archive(context.Background(), fpath))
+	assert.Equal(t, []byte("hello s3\n"), fake.objects["/archive/"+a.Key(fpath)])
+	_, err = os.Stat(fpath)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,130 @@
+package archiver
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// 存储后端
+type Storage interface {
+	// 上传本地文件，checksum为文件内容的md5(十六进制)，用于存储端校验
+	Upload(ctx context.Context, key, path, checksum string) error
+	// 获取已上传对象的md5(十六进制)，用于确认上传
+	Checksum(ctx context.Context, key string) (string, error)
+}
+
+// S3存储配置，兼容MinIO、OSS、COS等S3协议的对象存储
+type S3Config struct {
+	// 服务地址，如 http://minio:9000，为空时使用AWS默认地址
+	Endpoint string `yaml:"endpoint"`
+	// 区域
+	Region string `yaml:"region"`
+	// 存储桶
+	Bucket string `yaml:"bucket"`
+	// 访问密钥ID
+	AccessKey string `yaml:"accessKey"`
+	// 访问密钥
+	SecretKey string `yaml:"secretKey"`
+	// 是否使用路径形式访问存储桶，MinIO需开启
+	ForcePathStyle bool `yaml:"forcePathStyle"`
+}
+
+// S3存储后端的创建方法，由s3子包注册，避免未使用S3时依赖aws-sdk-go
+var _newS3Storage func(config *S3Config) (Storage, error)
+
+// 注册S3存储后端的创建方法，由s3子包在init中调用
+func RegisterS3Storage(fn func(config *S3Config) (Storage, error)) {
+	_newS3Storage = fn
+}
+
+// 新建S3存储后端，需导入s3子包
+func newS3Storage(config *S3Config) (Storage, error) {
+	if _newS3Storage == nil {
+		return nil, errors.New("s3 storage not registered, import gitlab.shanhai.int/sre/library/base/archiver/s3")
+	}
+	return _newS3Storage(config)
+}
+
+// 文件系统存储后端，用于测试或挂载的网络存储
+type FileStorage struct {
+	// 存储目录
+	dir string
+}
+
+// 新建文件系统存储后端
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if dir == "" {
+		return nil, errors.New("file storage dir can't be empty")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "create dir %s error", dir)
+	}
+	return &FileStorage{dir: dir}, nil
+}
+
+// 上传文件，先写入临时文件，校验通过后重命名
+func (s *FileStorage) Upload(ctx context.Context, key, path, checksum string) (err error) {
+	dst := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.Wrapf(err, "create dir %s error", filepath.Dir(dst))
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(tmp)
+		}
+	}()
+
+	h := md5.New()
+	if _, err = io.Copy(io.MultiWriter(out, h), in); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != checksum {
+		return errors.Errorf("checksum mismatch, expect %s, got %s", checksum, sum)
+	}
+	if err = out.Sync(); err != nil {
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// 获取已上传文件的md5
+func (s *FileStorage) Checksum(ctx context.Context, key string) (string, error) {
+	return fileChecksum(filepath.Join(s.dir, filepath.FromSlash(key)))
+}
+
+// 计算文件md5
+func fileChecksum(path string) (string, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, fp); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,120 @@
+package context
+
+import (
+	"context"
+	"sync"
+)
+
+// 上下文中日志字段的键名
+const ContextLogFieldsKey = "log_fields"
+
+// 可设置键值的上下文，如*gin.Context
+type SettableContext interface {
+	context.Context
+	// 设置键值
+	Set(key string, value interface{})
+}
+
+// 自动输出到日志的上下文键，key为日志字段名，value为上下文键名
+var (
+	logFieldKeys     = make(map[string]string)
+	logFieldKeysLock sync.RWMutex
+)
+
+// 注册自动输出到日志的上下文键
+//
+//	field为日志字段名，contextKey为上下文键名，contextKey为空时与field相同
+//	如 RegisterLogField("phone_type", middleware.ContextPhoneTypeKey)
+//	tracing及ParseUserAgentMiddleware中间件已通过SetLogField写入trace_id、app_version，无需注册
+//	注册后，log包及基于hook的组件日志会自动输出上下文中该键的值
+func RegisterLogField(field, contextKey string) {
+	if contextKey == "" {
+		contextKey = field
+	}
+
+	logFieldKeysLock.Lock()
+	defer logFieldKeysLock.Unlock()
+
+	logFieldKeys[field] = contextKey
+}
+
+// 取消注册自动输出到日志的上下文键
+func UnregisterLogField(field string) {
+	logFieldKeysLock.Lock()
+	defer logFieldKeysLock.Unlock()
+
+	delete(logFieldKeys, field)
+}
+
+// 在上下文中增加日志字段，返回新的上下文
+func WithLogField(ctx context.Context, field string, value interface{}) context.Context {
+	return WithLogFields(ctx, map[string]interface{}{field: value})
+}
+
+// 在上下文中增加多个日志字段，返回新的上下文
+func WithLogFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	return context.WithValue(ctx, ContextLogFieldsKey, mergeLogFields(ctx, fields))
+}
+
+// 在可设置键值的上下文(如*gin.Context)中增加日志字段
+func SetLogField(ctx SettableContext, field string, value interface{}) {
+	ctx.Set(ContextLogFieldsKey, mergeLogFields(ctx, map[string]interface{}{field: value}))
+}
+
+// 合并日志字段，总是返回新的字典，避免修改父上下文中的字段
+func mergeLogFields(ctx context.Context, fields map[string]interface{}) map[string]interface{} {
+	parent, _ := ctx.Value(ContextLogFieldsKey).(map[string]interface{})
+	m := make(map[string]interface{}, len(parent)+len(fields))
+	for k, v := range parent {
+		m[k] = v
+	}
+	for k, v := range fields {
+		m[k] = v
+	}
+	return m
+}
+
+// 获取上下文中需要输出到日志的全部字段
+//
+//	包含注册的上下文键及通过WithLogField增加的字段，值为空时不输出
+func GetLogFields(ctx context.Context) map[string]interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	var m map[string]interface{}
+	logFieldKeysLock.RLock()
+	for field, key := range logFieldKeys {
+		v := ctx.Value(key)
+		if isEmptyLogField(v) {
+			continue
+		}
+		if m == nil {
+			m = make(map[string]interface{})
+		}
+		m[field] = v
+	}
+	logFieldKeysLock.RUnlock()
+
+	fields, _ := ctx.Value(ContextLogFieldsKey).(map[string]interface{})
+	for k, v := range fields {
+		if isEmptyLogField(v) {
+			continue
+		}
+		if m == nil {
+			m = make(map[string]interface{}, len(fields))
+		}
+		m[k] = v
+	}
+
+	return m
+}
+
+// 是否为空日志字段
+func isEmptyLogField(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	s, ok := v.(string)
+	return ok && s == ""
+}
@@ -0,0 +1,61 @@
+package context
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 用于测试的可设置键值的上下文
+type settableContext struct {
+	context.Context
+	keys map[string]interface{}
+}
+
+func (c *settableContext) Set(key string, value interface{}) {
+	c.keys[key] = value
+}
+
+func (c *settableContext) Value(key interface{}) interface{} {
+	if k, ok := key.(string); ok {
+		if v, ok := c.keys[k]; ok {
+			return v
+		}
+	}
+	return c.Context.Value(key)
+}
+
+func TestGetLogFields(t *testing.T) {
+	RegisterLogField("app_version", "")
+	RegisterLogField("trace_id", "context_trace_id")
+	defer func() {
+		UnregisterLogField("app_version")
+		UnregisterLogField("trace_id")
+	}()
+
+	t.Run("registered", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), "app_version", "1.0.0")
+		ctx = context.WithValue(ctx, "context_trace_id", "")
+		assert.Equal(t, map[string]interface{}{"app_version": "1.0.0"}, GetLogFields(ctx))
+	})
+
+	t.Run("with", func(t *testing.T) {
+		parent := WithLogField(context.Background(), "user_id", 1)
+		child := WithLogFields(parent, map[string]interface{}{"tenant": "t1"})
+		assert.Equal(t, map[string]interface{}{"user_id": 1}, GetLogFields(parent))
+		assert.Equal(t, map[string]interface{}{"user_id": 1, "tenant": "t1"}, GetLogFields(child))
+	})
+
+	t.Run("settable", func(t *testing.T) {
+		ctx := &settableContext{Context: context.Background(), keys: make(map[string]interface{})}
+		SetLogField(ctx, "tenant", "t1")
+		ctx.Set("context_trace_id", "abc")
+		assert.Equal(t, map[string]interface{}{"tenant": "t1", "trace_id": "abc"}, GetLogFields(ctx))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		assert.Nil(t, GetLogFields(context.Background()))
+		assert.Nil(t, GetLogFields(nil))
+	})
+}
@@ -0,0 +1,83 @@
+package filewriter
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 错误操作
+const (
+	// 写入文件
+	OpWrite = "write"
+	// fsync
+	OpSync = "sync"
+	// 打开文件
+	OpOpen = "open"
+	// 分割文件
+	OpRotate = "rotate"
+	// 压缩文件
+	OpCompress = "compress"
+	// 删除文件
+	OpRemove = "remove"
+	// 缓冲区已满或已关闭，丢弃数据
+	OpDiscard = "discard"
+)
+
+// 错误总数，需通过metric包注册
+var ErrorTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "filewriter_error_total",
+	},
+	[]string{"file", "op"},
+)
+
+// 写入错误
+type WriteError struct {
+	// 文件路径
+	Path string
+	// 操作
+	Op string
+	// 错误
+	Err error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("filewriter %s %s error: %s", e.Op, e.Path, e.Err)
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// 上报错误，并将未写入的数据写入备用writer
+func (f *FileWriter) reportError(op string, err error, p []byte) {
+	werr := &WriteError{Path: f.path, Op: op, Err: err}
+	ErrorTotal.WithLabelValues(f.path, op).Inc()
+	if f.opt.OnError != nil {
+		f.opt.OnError(werr)
+	} else if op == OpDiscard {
+		f.printDiscard(werr)
+	} else {
+		f.stdlog.Printf("%s", werr)
+	}
+
+	if len(p) != 0 && f.opt.Fallback != nil {
+		if _, ferr := f.opt.Fallback.Write(p); ferr != nil {
+			f.stdlog.Printf("write fallback error: %s", ferr)
+		}
+	}
+}
+
+// 限频输出丢弃数据错误，缓冲区已满时避免每条丢弃的数据均输出一次
+func (f *FileWriter) printDiscard(werr *WriteError) {
+	atomic.AddUint64(&f.discarded, 1)
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&f.discardPrinted)
+	if now-last < int64(_discardPrintInterval) || !atomic.CompareAndSwapInt64(&f.discardPrinted, last, now) {
+		return
+	}
+	f.stdlog.Printf("%s, %d discarded", werr, atomic.SwapUint64(&f.discarded, 0))
+}
@@ -2,8 +2,10 @@ package filewriter
 
 import (
 	"bytes"
+	"compress/gzip"
 	"container/list"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -14,6 +16,21 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// 压缩方式对应的文件后缀
+var _compressSuffixes = map[string]string{
+	CompressGzip: ".gz",
+	CompressZstd: ".zst",
+}
+
+const (
+	// 外部删除通知管道大小
+	_forgetChanSize = 128
+	// 未设置错误回调时，丢弃数据错误输出至标准错误的最小间隔
+	_discardPrintInterval = 10 * time.Second
 )
 
 // FileWriter
@@ -24,6 +41,8 @@ type FileWriter struct {
 	dir string
 	// 文件名
 	fname string
+	// 文件路径
+	path string
 	// 管道缓冲区
 	ch chan *bytes.Buffer
 	// 日志logger
@@ -45,6 +64,31 @@ type FileWriter struct {
 	closed int32
 	// 用于内部协程的waitgroup
 	wg sync.WaitGroup
+	// fsync请求管道
+	syncCh chan *syncRequest
+	// 写入协程退出后关闭
+	exit chan struct{}
+	// 通过WriteSync写入的writer
+	syncWriter *syncWriter
+	// 当前文件是否有未fsync的数据，只在写入协程中使用
+	dirty bool
+
+	// 被外部删除的分割文件路径管道，由写入协程移除对应记录
+	forgetCh chan string
+
+	// 分割后待处理的文件路径队列，用于后台压缩及回调，写入协程入队时不阻塞
+	postQueue []string
+	// 保护postQueue
+	postMu sync.Mutex
+	// 通知分割后处理协程，为空时不开启分割后处理
+	postCh chan struct{}
+	// 用于分割后处理协程的waitgroup
+	postWg sync.WaitGroup
+
+	// 上次输出丢弃错误后丢弃的次数
+	discarded uint64
+	// 上次输出丢弃错误的时间
+	discardPrinted int64
 }
 
 // 每个分割文件的相关信息
@@ -52,6 +96,8 @@ type rotateItem struct {
 	rotateTime int64
 	rotateNum  int
 	fname      string
+	// 文件修改时间，用于按保留时间删除
+	modTime time.Time
 }
 
 // 获取当前目录下符合条件的分割文件信息列表
@@ -67,6 +113,8 @@ func parseRotateItem(dir, fname, rotateFormat string) (*list.List, error) {
 
 		// 去除文件前的 '.' error.log.2018-09-12.001 -> 2018-09-12.001
 		s = strings.TrimLeft(s[len(fname):], ".")
+		// 去除压缩文件后缀 2018-09-12.001.gz -> 2018-09-12.001
+		s = trimCompressSuffix(s)
 
 		seqs := strings.Split(s, ".")
 		var t time.Time
@@ -96,6 +144,7 @@ func parseRotateItem(dir, fname, rotateFormat string) (*list.List, error) {
 				// todo:处理错误
 				continue
 			}
+			rt.modTime = fi.ModTime()
 			items = append(items, rt)
 		}
 	}
@@ -172,6 +221,14 @@ func New(fpath string, fns ...OptionFunc) (*FileWriter, error) {
 	for _, fn := range fns {
 		fn(&opt)
 	}
+	// 分割周期小于1天时，使用更精确的时间格式，防止同一天内的分割文件名重复
+	if opt.RotatePeriod > 0 && opt.RotateFormat == RotateDaily && opt.RotatePeriod < 24*time.Hour {
+		if opt.RotatePeriod%time.Hour == 0 {
+			opt.RotateFormat = RotateHourly
+		} else {
+			opt.RotateFormat = RotateMinutely
+		}
+	}
 
 	fname := filepath.Base(fpath)
 	if fname == "" {
@@ -203,37 +260,54 @@ func New(fpath string, fns ...OptionFunc) (*FileWriter, error) {
 		stdlog.Printf("parseRotateItem error: %s", err)
 	}
 
-	// 获取最晚的编号
-	lastRotateFormat := time.Now().Format(opt.RotateFormat)
-	var lastSplitNum int
-	if files.Len() > 0 {
-		rt := files.Front().Value.(rotateItem)
-		//  check contains is mush esay than compared with timestamp
-		if strings.Contains(rt.fname, lastRotateFormat) {
-			lastSplitNum = rt.rotateNum
-		}
+	// 分割文件列表按时间由旧到新排列，便于删除最旧的文件
+	ordered := list.New()
+	for e := files.Front(); e != nil; e = e.Next() {
+		ordered.PushFront(e.Value)
 	}
+	files = ordered
 
 	ch := make(chan *bytes.Buffer, opt.ChanSize)
 	fw := &FileWriter{
 		opt:    opt,
 		dir:    dir,
 		fname:  fname,
+		path:   filepath.Join(dir, fname),
 		stdlog: stdlog,
 		ch:     ch,
+		syncCh: make(chan *syncRequest),
+		exit:   make(chan struct{}),
+
+		forgetCh: make(chan string, _forgetChanSize),
 		pool: &sync.Pool{
 			New: func() interface{} {
 				return new(bytes.Buffer)
 			},
 		},
 
-		lastSplitNum:     lastSplitNum,
-		lastRotateFormat: lastRotateFormat,
-
 		files:   files,
 		current: current,
 	}
 
+	fw.syncWriter = &syncWriter{fw: fw}
+
+	// 获取下一个编号，同一周期内已存在分割文件时继续编号
+	fw.lastRotateFormat = fw.rotateKey(time.Now())
+	if files.Len() > 0 {
+		rt := files.Back().Value.(rotateItem)
+		//  check contains is mush esay than compared with timestamp
+		if strings.Contains(rt.fname, fw.lastRotateFormat) {
+			fw.lastSplitNum = rt.rotateNum + 1
+		}
+	}
+
+	// 开启分割后处理协程
+	if opt.compressMethod() != "" || opt.OnRotate != nil {
+		fw.postCh = make(chan struct{}, 1)
+		fw.postWg.Add(1)
+		go fw.postRotate()
+	}
+
 	fw.wg.Add(1)
 	// 开启写入及检查协程
 	go fw.daemon()
@@ -245,8 +319,13 @@ func New(fpath string, fns ...OptionFunc) (*FileWriter, error) {
 func (f *FileWriter) Write(p []byte) (int, error) {
 	// 检查文件是否关闭
 	if atomic.LoadInt32(&f.closed) == 1 {
-		f.stdlog.Printf("%s", p)
-		return 0, fmt.Errorf("filewriter already closed")
+		err := fmt.Errorf("filewriter already closed")
+		if f.opt.Fallback != nil {
+			f.reportError(OpDiscard, err, p)
+		} else {
+			f.stdlog.Printf("%s", p)
+		}
+		return 0, err
 	}
 	// 由于写入是异步操作，为防止写入时数据发生变化，将其先存入buf中
 	buf := f.getBuf()
@@ -258,18 +337,76 @@ func (f *FileWriter) Write(p []byte) (int, error) {
 			return len(p), nil
 		// 缓冲区被占用，返回错误
 		default:
-			return 0, fmt.Errorf("log channel is full, discard log")
+			return 0, f.discard(buf)
 		}
 	}
 
 	timeout := time.NewTimer(f.opt.WriteTimeout)
+	defer timeout.Stop()
 	select {
 	case f.ch <- buf:
 		return len(p), nil
 	// 写入超时，返回错误
 	case <-timeout.C:
-		return 0, fmt.Errorf("log channel is full, discard log")
+		return 0, f.discard(buf)
+	}
+}
+
+// 丢弃数据并上报错误
+func (f *FileWriter) discard(buf *bytes.Buffer) error {
+	err := fmt.Errorf("log channel is full, discard log")
+	f.reportError(OpDiscard, err, buf.Bytes())
+	f.putBuf(buf)
+	return err
+}
+
+// 写入并在error落盘策略下等待数据fsync完成，用于错误级别日志等需要保证落盘的数据
+// 其他落盘策略下与Write一致
+func (f *FileWriter) WriteSync(p []byte) (int, error) {
+	if f.opt.Durability != DurabilityError || atomic.LoadInt32(&f.closed) == 1 {
+		return f.Write(p)
+	}
+
+	req := &syncRequest{
+		data: append([]byte(nil), p...),
+		done: make(chan error, 1),
 	}
+	select {
+	case f.syncCh <- req:
+	case <-f.exit:
+		return 0, fmt.Errorf("filewriter already closed")
+	}
+	select {
+	case err := <-req.done:
+		if err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	case <-f.exit:
+		return 0, fmt.Errorf("filewriter already closed")
+	}
+}
+
+// 获取通过WriteSync写入的writer
+func (f *FileWriter) SyncWriter() io.Writer {
+	return f.syncWriter
+}
+
+// 通过WriteSync写入的writer
+type syncWriter struct {
+	fw *FileWriter
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	return w.fw.WriteSync(p)
+}
+
+// fsync请求
+type syncRequest struct {
+	// 写入数据
+	data []byte
+	// 结果管道
+	done chan error
 }
 
 // 写入及检查分割操作
@@ -278,10 +415,18 @@ func (f *FileWriter) daemon() {
 	// 待写入区
 	aggsbuf := &bytes.Buffer{}
 	tk := time.NewTicker(f.opt.RotateInterval)
+	defer tk.Stop()
 	// todo:可配置aggstk
 	// 待写入区写入延迟
 	aggstk := time.NewTicker(10 * time.Millisecond)
-	var err error
+	defer aggstk.Stop()
+	// 定时fsync
+	var synctk <-chan time.Time
+	if f.opt.Durability == DurabilityInterval && f.opt.SyncInterval > 0 {
+		ticker := time.NewTicker(f.opt.SyncInterval)
+		defer ticker.Stop()
+		synctk = ticker.C
+	}
 	for {
 		select {
 		// 定时检查分割
@@ -296,39 +441,103 @@ func (f *FileWriter) daemon() {
 		// 从待写入区读取并写入文件，并重置待写入区
 		case <-aggstk.C:
 			if aggsbuf.Len() > 0 {
-				if err = f.write(aggsbuf.Bytes()); err != nil {
-					f.stdlog.Printf("write log error: %s", err)
-				}
+				f.write(aggsbuf.Bytes())
 				aggsbuf.Reset()
 			}
+		// 定时fsync
+		case <-synctk:
+			if f.dirty {
+				f.sync()
+			}
+		// 写入并fsync
+		case req := <-f.syncCh:
+			f.handleSync(aggsbuf, req)
+		// 移除被外部删除的分割文件记录
+		case fpath := <-f.forgetCh:
+			f.forget(fpath)
 		}
 		// 检查文件是否关闭，如未关闭则继续
 		if atomic.LoadInt32(&f.closed) != 1 {
 			continue
 		}
 		// 文件关闭，写入剩下的数据
-		if err = f.write(aggsbuf.Bytes()); err != nil {
-			f.stdlog.Printf("write log error: %s", err)
-		}
+		f.write(aggsbuf.Bytes())
+		aggsbuf.Reset()
 		for buf := range f.ch {
-			if err = f.write(buf.Bytes()); err != nil {
-				f.stdlog.Printf("write log error: %s", err)
-			}
+			f.write(buf.Bytes())
 			f.putBuf(buf)
 		}
+		// 处理等待中的fsync请求及文件记录移除
+		for done := false; !done; {
+			select {
+			case req := <-f.syncCh:
+				f.handleSync(aggsbuf, req)
+			case fpath := <-f.forgetCh:
+				f.forget(fpath)
+			default:
+				done = true
+			}
+		}
+		if f.opt.Durability != DurabilityNone && f.dirty {
+			f.sync()
+		}
 		break
 	}
+	close(f.exit)
 	f.wg.Done()
 }
 
+// 处理fsync请求，先写入之前的数据以保证顺序
+func (f *FileWriter) handleSync(aggsbuf *bytes.Buffer, req *syncRequest) {
+	for drained := false; !drained; {
+		select {
+		case buf, ok := <-f.ch:
+			if !ok {
+				drained = true
+				break
+			}
+			aggsbuf.Write(buf.Bytes())
+			f.putBuf(buf)
+		default:
+			drained = true
+		}
+	}
+	aggsbuf.Write(req.data)
+	err := f.write(aggsbuf.Bytes())
+	aggsbuf.Reset()
+	if err == nil {
+		err = f.sync()
+	}
+	req.done <- err
+}
+
 // 关闭文件写入
 func (f *FileWriter) Close() error {
 	atomic.StoreInt32(&f.closed, 1)
 	close(f.ch)
 	f.wg.Wait()
+	if f.postCh != nil {
+		close(f.postCh)
+		f.postWg.Wait()
+	}
 	return nil
 }
 
+// 获取时间对应的分割时间格式，设置分割周期时按周期起始时间格式化
+func (f *FileWriter) rotateKey(t time.Time) string {
+	if f.opt.RotatePeriod > 0 {
+		t = periodStart(t, f.opt.RotatePeriod)
+	}
+	return t.Format(f.opt.RotateFormat)
+}
+
+// 获取时间所在周期的起始时间，按本地时间对齐
+func periodStart(t time.Time, period time.Duration) time.Time {
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(period).Add(-shift)
+}
+
 // 检查文件分割
 func (f *FileWriter) checkRotate(t time.Time) {
 	formatFname := func(format string, num int) string {
@@ -337,38 +546,73 @@ func (f *FileWriter) checkRotate(t time.Time) {
 		}
 		return fmt.Sprintf("%s.%s.%03d", f.fname, format, num)
 	}
-	format := t.Format(f.opt.RotateFormat)
+	format := f.rotateKey(t)
 
 	if f.opt.MaxFile != 0 {
 		// 若大于最大文件数，则删除文件
 		for f.files.Len() > f.opt.MaxFile {
 			rt := f.files.Remove(f.files.Front()).(rotateItem)
-			fpath := filepath.Join(f.dir, rt.fname)
-			if err := os.Remove(fpath); err != nil {
-				f.stdlog.Printf("remove file %s error: %s", fpath, err)
+			f.remove(rt)
+		}
+	}
+
+	if f.opt.MaxAge > 0 {
+		// 删除超过保留时间的文件
+		expired := t.Add(-f.opt.MaxAge)
+		for e := f.files.Front(); e != nil; {
+			next := e.Next()
+			if rt := e.Value.(rotateItem); rt.modTime.Before(expired) {
+				f.files.Remove(e)
+				f.remove(rt)
 			}
+			e = next
 		}
 	}
 
+	if f.opt.MaxTotalSize > 0 {
+		f.checkTotalSize()
+	}
+
+	// 当前文件不可用时，由写入时重新创建
+	if f.current == nil {
+		return
+	}
+
 	// 检查条件并分割文件
 	if format != f.lastRotateFormat || (f.opt.MaxSize != 0 && f.current.size() > f.opt.MaxSize) {
 		var err error
+		// 按落盘策略fsync
+		if f.opt.Durability != DurabilityNone && f.dirty {
+			f.sync()
+		}
 		// 关闭当前文件，防止写入
 		if err = f.current.fp.Close(); err != nil {
-			f.stdlog.Printf("close current file error: %s", err)
+			f.reportError(OpRotate, err, nil)
 		}
 
 		// 重命名文件
 		fname := formatFname(f.lastRotateFormat, f.lastSplitNum)
-		oldpath := filepath.Join(f.dir, f.fname)
 		newpath := filepath.Join(f.dir, fname)
-		if err = os.Rename(oldpath, newpath); err != nil {
-			f.stdlog.Printf("rename file %s to %s error: %s", oldpath, newpath, err)
+		if err = os.Rename(f.path, newpath); err != nil {
+			f.reportError(OpRotate, err, nil)
+			// 重新打开当前文件继续写入
+			if f.current, err = newWrapFile(f.path); err != nil {
+				f.reportError(OpOpen, err, nil)
+			}
 			return
 		}
 
-		// 塞入新文件信息
-		f.files.PushBack(rotateItem{fname: fname})
+		// 塞入新文件信息，开启压缩时记录压缩后的文件名，压缩完成前清理时同时删除原文件
+		if method := f.opt.compressMethod(); method != "" {
+			f.files.PushBack(rotateItem{fname: fname + _compressSuffixes[method], modTime: t})
+		} else {
+			f.files.PushBack(rotateItem{fname: fname, modTime: t})
+		}
+
+		// 后台压缩及回调，避免阻塞写入
+		if f.postCh != nil {
+			f.post(newpath)
+		}
 
 		// 若是新的时间，则更改时间，重置编号
 		// 否则增加标号
@@ -380,21 +624,243 @@ func (f *FileWriter) checkRotate(t time.Time) {
 		}
 
 		// 重新创建当前文件
-		f.current, err = newWrapFile(filepath.Join(f.dir, f.fname))
+		f.current, err = newWrapFile(f.path)
 		if err != nil {
-			f.stdlog.Printf("create log file error: %s", err)
+			f.reportError(OpOpen, err, nil)
+		}
+	}
+}
+
+// 检查总大小，超出上限时按时间由旧到新删除分割文件
+func (f *FileWriter) checkTotalSize() {
+	var total int64
+	if f.current != nil {
+		total = f.current.size()
+	}
+	exceeded := false
+	for e := f.files.Back(); e != nil; {
+		prev := e.Prev()
+		rt := e.Value.(rotateItem)
+		if !exceeded {
+			total += f.fileSize(rt)
+			exceeded = total > f.opt.MaxTotalSize
+		}
+		if exceeded {
+			f.files.Remove(e)
+			f.remove(rt)
+		}
+		e = prev
+	}
+}
+
+// 去除压缩文件后缀
+func trimCompressSuffix(fname string) string {
+	for _, suffix := range _compressSuffixes {
+		fname = strings.TrimSuffix(fname, suffix)
+	}
+	return fname
+}
+
+// 分割文件的原文件及压缩后的文件路径
+func (f *FileWriter) rotatePaths(rt rotateItem) []string {
+	fpath := filepath.Join(f.dir, trimCompressSuffix(rt.fname))
+	paths := []string{fpath}
+	for _, suffix := range _compressSuffixes {
+		paths = append(paths, fpath+suffix)
+	}
+	return paths
+}
+
+// 分割文件大小，包含压缩中的原文件及压缩后的文件
+func (f *FileWriter) fileSize(rt rotateItem) int64 {
+	var size int64
+	for _, fpath := range f.rotatePaths(rt) {
+		if fi, err := os.Stat(fpath); err == nil {
+			size += fi.Size()
+		}
+	}
+	return size
+}
+
+// 删除分割文件，压缩中的文件同时删除原文件及压缩结果
+func (f *FileWriter) remove(rt rotateItem) {
+	var (
+		removed bool
+		err     error
+	)
+	for _, fpath := range f.rotatePaths(rt) {
+		rErr := os.Remove(fpath)
+		if rErr == nil {
+			removed = true
+		} else if !os.IsNotExist(rErr) || err == nil {
+			err = rErr
 		}
 	}
+	if !removed && err != nil {
+		f.reportError(OpRemove, err, nil)
+	}
 }
 
-// 写入数据
+// 忽略被外部删除的分割文件，如归档器上传后删除的文件，不再计入MaxFile等清理规则
+//
+//	fpath为分割回调中的文件路径，关闭后调用无效果
+func (f *FileWriter) Forget(fpath string) {
+	select {
+	case f.forgetCh <- fpath:
+	case <-f.exit:
+	}
+}
+
+// 移除分割文件记录，需在写入协程中调用
+func (f *FileWriter) forget(fpath string) {
+	if filepath.Clean(filepath.Dir(fpath)) != filepath.Clean(f.dir) {
+		return
+	}
+	// 原文件及压缩后的文件均可匹配
+	name := trimCompressSuffix(filepath.Base(fpath))
+	for e := f.files.Front(); e != nil; e = e.Next() {
+		if trimCompressSuffix(e.Value.(rotateItem).fname) == name {
+			f.files.Remove(e)
+			return
+		}
+	}
+}
+
+// 加入分割后处理队列，不阻塞写入协程
+func (f *FileWriter) post(fpath string) {
+	f.postMu.Lock()
+	f.postQueue = append(f.postQueue, fpath)
+	f.postMu.Unlock()
+	select {
+	case f.postCh <- struct{}{}:
+	default:
+	}
+}
+
+// 取出分割后处理队列中的所有文件路径
+func (f *FileWriter) takePost() []string {
+	f.postMu.Lock()
+	defer f.postMu.Unlock()
+	queue := f.postQueue
+	f.postQueue = nil
+	return queue
+}
+
+// 分割后处理，按分割顺序压缩文件并调用分割回调
+func (f *FileWriter) postRotate() {
+	defer f.postWg.Done()
+	for range f.postCh {
+		for _, fpath := range f.takePost() {
+			f.handlePost(fpath)
+		}
+	}
+	// 关闭后处理剩余文件
+	for _, fpath := range f.takePost() {
+		f.handlePost(fpath)
+	}
+}
+
+// 压缩文件并调用分割回调
+func (f *FileWriter) handlePost(fpath string) {
+	if method := f.opt.compressMethod(); method != "" {
+		dst, err := compressFile(fpath, method)
+		switch {
+		case err == nil:
+			fpath = dst
+		case os.IsNotExist(err):
+			// 压缩前或压缩期间原文件已被清理，同时删除压缩结果
+			if dst != "" {
+				os.Remove(dst)
+			}
+			return
+		default:
+			f.reportError(OpCompress, err, nil)
+		}
+	}
+	if f.opt.OnRotate != nil {
+		f.opt.OnRotate(fpath)
+	}
+}
+
+// 压缩文件，成功后删除原文件，返回压缩后的文件路径
+func compressFile(src, method string) (dst string, err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	dst = src + _compressSuffixes[method]
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(tmp)
+		}
+	}()
+
+	var cw io.WriteCloser
+	switch method {
+	case CompressZstd:
+		if cw, err = zstd.NewWriter(out); err != nil {
+			return "", err
+		}
+	default:
+		cw = gzip.NewWriter(out)
+	}
+	if _, err = io.Copy(cw, in); err != nil {
+		return "", err
+	}
+	if err = cw.Close(); err != nil {
+		return "", err
+	}
+	if err = out.Close(); err != nil {
+		return "", err
+	}
+	if err = os.Rename(tmp, dst); err != nil {
+		return "", err
+	}
+	return dst, os.Remove(src)
+}
+
+// 写入数据，失败时上报错误并写入备用writer
 func (f *FileWriter) write(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	// 当前文件不可用时尝试重新创建
+	if f.current == nil {
+		current, err := newWrapFile(f.path)
+		if err != nil {
+			f.reportError(OpOpen, err, p)
+			return err
+		}
+		f.current = current
+	}
+	n, err := f.current.write(p)
+	if err != nil {
+		f.reportError(OpWrite, err, p[n:])
+		return err
+	}
+	f.dirty = true
+	return nil
+}
+
+// fsync当前文件
+func (f *FileWriter) sync() error {
 	if f.current == nil {
-		f.stdlog.Printf("can't write log to file, please check stderr log for detail")
-		f.stdlog.Printf("%s", p)
+		return nil
 	}
-	_, err := f.current.write(p)
-	return err
+	f.dirty = false
+	if err := f.current.fp.Sync(); err != nil {
+		f.reportError(OpSync, err, nil)
+		return err
+	}
+	return nil
 }
 
 // 放入对象池，用于重用
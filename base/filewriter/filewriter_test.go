@@ -1,13 +1,21 @@
 package filewriter
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -219,3 +227,386 @@ func BenchmarkFileWriter(b *testing.B) {
 		}
 	}
 }
+
+func TestMaxAge(t *testing.T) {
+	dir := filepath.Join(logdir, "test-maxage")
+	old := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{"info.log.2018-12-01", "info.log.2018-12-02.gz"} {
+		touch(dir, name)
+		os.Chtimes(filepath.Join(dir, name), old, old)
+	}
+	touch(dir, "info.log."+time.Now().Format(RotateDaily)+".001")
+
+	fw, err := New(filepath.Join(dir, "info.log"),
+		MaxAge(24*time.Hour),
+		func(opt *option) { opt.RotateInterval = time.Millisecond },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	fw.Close()
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fnames []string
+	for _, fi := range fis {
+		fnames = append(fnames, fi.Name())
+	}
+	assert.Equal(t, []string{"info.log", "info.log." + time.Now().Format(RotateDaily) + ".001"}, fnames)
+}
+
+func TestCompress(t *testing.T) {
+	dir := filepath.Join(logdir, "test-compress")
+	fw, err := New(filepath.Join(dir, "info.log"),
+		MaxSize(1024),
+		Compress(true),
+		func(opt *option) { opt.RotateInterval = time.Millisecond },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 1024)
+	for i := 0; i < 3; i++ {
+		_, err = fw.Write(data)
+		assert.Nil(t, err)
+		time.Sleep(30 * time.Millisecond)
+	}
+	fw.Close()
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compressed int
+	for _, fi := range fis {
+		if filepath.Ext(fi.Name()) == ".gz" {
+			compressed++
+			continue
+		}
+		assert.Equal(t, "info.log", fi.Name())
+	}
+	assert.True(t, compressed > 0, "expect compressed file")
+	// 分割时记录压缩后的文件名
+	assert.Equal(t, compressed, fw.files.Len())
+	for e := fw.files.Front(); e != nil; e = e.Next() {
+		assert.Equal(t, ".gz", filepath.Ext(e.Value.(rotateItem).fname))
+	}
+
+	// 解压后内容不变
+	fp, err := os.Open(filepath.Join(dir, fis[1].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+	gr, err := gzip.NewReader(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(gr)
+	assert.Nil(t, err)
+	assert.Equal(t, data, b[:len(data)])
+}
+
+func TestRotatePeriod(t *testing.T) {
+	dir := filepath.Join(logdir, "test-rotate-period")
+	fw, err := New(filepath.Join(dir, "info.log"), RotatePeriod(15*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Close()
+	// 未修改RotateFormat时自动使用按分钟的时间格式
+	assert.Equal(t, RotateMinutely, fw.opt.RotateFormat)
+	tm := time.Date(2020, 1, 2, 10, 37, 12, 0, time.Local)
+	assert.Equal(t, "2020-01-02-10-30", fw.rotateKey(tm))
+
+	fw, err = New(filepath.Join(dir, "period.log"),
+		RotateFormat("2006-01-02-15-04-05"),
+		RotatePeriod(time.Second),
+		func(opt *option) { opt.RotateInterval = time.Millisecond },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fw.Write([]byte("first\n"))
+	assert.Nil(t, err)
+	time.Sleep(1100 * time.Millisecond)
+	_, err = fw.Write([]byte("second\n"))
+	assert.Nil(t, err)
+	fw.Close()
+
+	// 未达到文件大小时按周期分割，写入前跨过周期时可能分割出空文件
+	matches, err := filepath.Glob(filepath.Join(dir, "period.log.*"))
+	assert.Nil(t, err)
+	var rotated string
+	for _, match := range matches {
+		b, err := ioutil.ReadFile(match)
+		assert.Nil(t, err)
+		rotated += string(b)
+	}
+	assert.Equal(t, "first\n", rotated)
+}
+
+func TestRemoveCompressing(t *testing.T) {
+	dir := filepath.Join(logdir, "test-remove-compressing")
+	touch(dir, "info.log.2018-12-01")
+
+	var errs []*WriteError
+	fw := &FileWriter{dir: dir, opt: option{OnError: func(err *WriteError) {
+		errs = append(errs, err)
+	}}}
+	// 压缩完成前清理时删除原文件
+	fw.remove(rotateItem{fname: "info.log.2018-12-01.gz"})
+	_, err := os.Stat(filepath.Join(dir, "info.log.2018-12-01"))
+	assert.True(t, os.IsNotExist(err))
+	assert.Empty(t, errs)
+
+	// 原文件已被清理时，不再压缩及调用回调
+	var called bool
+	fw.opt.Compress = true
+	fw.opt.OnRotate = func(path string) {
+		called = true
+	}
+	fw.handlePost(filepath.Join(dir, "info.log.2018-12-01"))
+	assert.False(t, called)
+	assert.Empty(t, errs)
+}
+
+func TestDiscard(t *testing.T) {
+	stderr := new(syncBuffer)
+	fallback := new(syncBuffer)
+	fw := &FileWriter{
+		path:   "discard.log",
+		stdlog: log.New(stderr, "", 0),
+		pool:   &sync.Pool{New: func() interface{} { return new(bytes.Buffer) }},
+		opt:    option{Fallback: fallback},
+	}
+	before := testutil.ToFloat64(ErrorTotal.WithLabelValues("discard.log", OpDiscard))
+	for i := 0; i < 3; i++ {
+		buf := fw.getBuf()
+		buf.WriteString(fmt.Sprintf("%d\n", i))
+		assert.NotNil(t, fw.discard(buf))
+	}
+
+	// 丢弃的数据写入备用writer，标准错误限频输出
+	assert.Equal(t, "0\n1\n2\n", fallback.String())
+	assert.Equal(t, before+3, testutil.ToFloat64(ErrorTotal.WithLabelValues("discard.log", OpDiscard)))
+	assert.Equal(t, 1, strings.Count(stderr.String(), "\n"))
+	assert.Contains(t, stderr.String(), "1 discarded")
+	assert.Equal(t, uint64(2), atomic.LoadUint64(&fw.discarded))
+}
+
+func TestCompressZstd(t *testing.T) {
+	dir := filepath.Join(logdir, "test-compress-zstd")
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	fw, err := New(filepath.Join(dir, "info.log"),
+		MaxSize(1024),
+		CompressMethod(CompressZstd),
+		OnRotate(func(path string) {
+			mu.Lock()
+			paths = append(paths, path)
+			mu.Unlock()
+		}),
+		func(opt *option) { opt.RotateInterval = time.Millisecond },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 1024)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	for i := 0; i < 3; i++ {
+		_, err = fw.Write(data)
+		assert.Nil(t, err)
+		time.Sleep(30 * time.Millisecond)
+	}
+	fw.Close()
+
+	// 压缩完成后回调压缩后的文件路径
+	assert.True(t, len(paths) > 0, "expect rotate callback")
+	for _, path := range paths {
+		assert.Equal(t, ".zst", filepath.Ext(path))
+		fp, err := os.Open(path)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		zr, err := zstd.NewReader(fp)
+		assert.Nil(t, err)
+		b, err := ioutil.ReadAll(zr)
+		assert.Nil(t, err)
+		assert.Equal(t, data, b[:len(data)])
+		zr.Close()
+		fp.Close()
+	}
+
+	assert.Panics(t, func() {
+		CompressMethod("lz4")
+	})
+}
+
+func TestMaxTotalSize(t *testing.T) {
+	dir := filepath.Join(logdir, "test-max-total-size")
+	os.MkdirAll(dir, 0755)
+	data := make([]byte, 1024)
+	files := []string{
+		"info.log.2018-12-01",
+		"info.log.2018-12-02",
+		"info.log.2018-12-02.001",
+		"info.log.2018-12-03",
+	}
+	for _, file := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fw, err := New(filepath.Join(dir, "info.log"),
+		MaxTotalSize(2500),
+		func(opt *option) { opt.RotateInterval = time.Millisecond },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	fw.Close()
+
+	// 保留最新的分割文件
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fnames []string
+	for _, fi := range fis {
+		fnames = append(fnames, fi.Name())
+	}
+	assert.Equal(t, []string{"info.log", "info.log.2018-12-02.001", "info.log.2018-12-03"}, fnames)
+}
+
+func TestDurability(t *testing.T) {
+	dir := filepath.Join(logdir, "test-durability")
+	os.RemoveAll(dir)
+	fw, err := New(filepath.Join(dir, "info.log"), Durability(DurabilityError))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Close()
+
+	_, err = fw.Write([]byte("info\n"))
+	assert.Nil(t, err)
+	n, err := fw.SyncWriter().Write([]byte("error\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 6, n)
+
+	// WriteSync返回时数据已写入，且保持写入顺序
+	b, err := ioutil.ReadFile(filepath.Join(dir, "info.log"))
+	assert.Nil(t, err)
+	assert.Equal(t, "info\nerror\n", string(b))
+
+	assert.Panics(t, func() {
+		Durability("always")
+	})
+}
+
+func TestWriteError(t *testing.T) {
+	// 写入/dev/full返回磁盘已满错误
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("/dev/full not exists")
+	}
+
+	var (
+		mu   sync.Mutex
+		errs []*WriteError
+	)
+	fallback := new(syncBuffer)
+	before := testutil.ToFloat64(ErrorTotal.WithLabelValues("/dev/full", OpWrite))
+	fw, err := New("/dev/full",
+		Durability(DurabilityError),
+		Fallback(fallback),
+		OnError(func(err *WriteError) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fw.WriteSync([]byte("lost\n"))
+	assert.NotNil(t, err)
+	fw.Close()
+
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, OpWrite, errs[0].Op)
+		assert.Equal(t, "/dev/full", errs[0].Path)
+	}
+	assert.Equal(t, "lost\n", fallback.String())
+	assert.Equal(t, before+1, testutil.ToFloat64(ErrorTotal.WithLabelValues("/dev/full", OpWrite)))
+
+	// 关闭后写入备用writer
+	_, err = fw.Write([]byte("closed\n"))
+	assert.NotNil(t, err)
+	assert.Equal(t, "lost\nclosed\n", fallback.String())
+}
+
+func TestForget(t *testing.T) {
+	dir := filepath.Join(logdir, "test-forget")
+	os.MkdirAll(dir, 0755)
+	for _, name := range []string{"info.log.2018-12-01", "info.log.2018-12-02", "info.log.2018-12-03"} {
+		touch(dir, name)
+	}
+
+	var (
+		mu   sync.Mutex
+		errs []*WriteError
+	)
+	fw, err := New(filepath.Join(dir, "info.log"),
+		MaxFile(1),
+		OnError(func(err *WriteError) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 外部删除的文件不再清理，压缩后的文件路径同样生效，其他目录的文件忽略
+	for _, name := range []string{"info.log.2018-12-02", "info.log.2018-12-03.gz"} {
+		os.Remove(filepath.Join(dir, name))
+		fw.Forget(filepath.Join(dir, name))
+	}
+	fw.Forget(filepath.Join(logdir, "info.log.2018-12-01"))
+	fw.Close()
+
+	// 剩余文件数未超过MaxFile，不再删除
+	assert.Equal(t, 1, fw.files.Len())
+	assert.Empty(t, errs)
+	_, err = os.Stat(filepath.Join(dir, "info.log.2018-12-01"))
+	assert.Nil(t, err)
+	// 关闭后调用无效果
+	fw.Forget(filepath.Join(dir, "info.log.2018-12-01"))
+}
+
+// 并发安全的buffer
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -2,13 +2,33 @@ package filewriter
 
 import (
 	"fmt"
+	"io"
 	"strings"
 	"time"
 )
 
 // RotateFormat
 const (
-	RotateDaily = "2006-01-02"
+	RotateDaily  = "2006-01-02"
+	RotateHourly = "2006-01-02-15"
+	// 按分钟分割，用于小于1小时的分割周期
+	RotateMinutely = "2006-01-02-15-04"
+)
+
+// 落盘策略
+const (
+	// 不主动fsync，由操作系统决定落盘时机
+	DurabilityNone = "none"
+	// 按SyncInterval定时fsync
+	DurabilityInterval = "interval"
+	// 通过WriteSync写入的数据(如错误级别日志)写入后立即fsync
+	DurabilityError = "error"
+)
+
+// 压缩方式
+const (
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
 )
 
 // 默认配置
@@ -21,6 +41,10 @@ var defaultOption = option{
 	ChanSize: 1024 * 8,
 	// 默认10s
 	RotateInterval: 10 * time.Second,
+	// 默认不主动fsync
+	Durability: DurabilityNone,
+	// 默认1s
+	SyncInterval: time.Second,
 }
 
 // 配置
@@ -37,6 +61,37 @@ type option struct {
 	RotateInterval time.Duration
 	// 写入超时时间
 	WriteTimeout time.Duration
+	// 分割文件最长保留时间，0为不限制
+	MaxAge time.Duration
+	// 是否gzip压缩分割后的文件
+	Compress bool
+	// 分割周期，按本地时间对齐，0为按RotateFormat分割
+	RotatePeriod time.Duration
+	// 压缩方式，为空且Compress为true时使用gzip
+	CompressMethod string
+	// 所有分割文件及当前文件的总大小上限，超出时删除最旧的分割文件，0为不限制
+	MaxTotalSize int64
+	// 分割回调，参数为分割后的文件路径，开启压缩时为压缩后的文件路径
+	OnRotate func(path string)
+	// 落盘策略
+	Durability string
+	// 定时fsync间隔
+	SyncInterval time.Duration
+	// 错误回调
+	OnError func(err *WriteError)
+	// 文件无法写入时的备用writer
+	Fallback io.Writer
+}
+
+// 实际使用的压缩方式，为空时不压缩
+func (opt *option) compressMethod() string {
+	if opt.CompressMethod != "" {
+		return opt.CompressMethod
+	}
+	if opt.Compress {
+		return CompressGzip
+	}
+	return ""
 }
 
 // 配置的应用函数
@@ -74,3 +129,84 @@ func ChanSize(n int) OptionFunc {
 		opt.ChanSize = n
 	}
 }
+
+// 分割文件最长保留时间，按文件修改时间判断
+func MaxAge(d time.Duration) OptionFunc {
+	return func(opt *option) {
+		opt.MaxAge = d
+	}
+}
+
+// 是否gzip压缩分割后的文件，压缩后的文件以.gz结尾
+func Compress(compress bool) OptionFunc {
+	return func(opt *option) {
+		opt.Compress = compress
+	}
+}
+
+// 分割周期，如time.Hour为每小时分割，按本地时间对齐
+// 未修改RotateFormat且周期小于1天时，自动使用RotateHourly或RotateMinutely
+func RotatePeriod(d time.Duration) OptionFunc {
+	return func(opt *option) {
+		opt.RotatePeriod = d
+	}
+}
+
+// 压缩方式，可选gzip、zstd，压缩后的文件分别以.gz及.zst结尾
+func CompressMethod(method string) OptionFunc {
+	if _, ok := _compressSuffixes[method]; !ok {
+		panic(fmt.Sprintf("invalid compress method: %s", method))
+	}
+	return func(opt *option) {
+		opt.CompressMethod = method
+	}
+}
+
+// 所有分割文件及当前文件的总大小上限，超出时按时间由旧到新删除分割文件
+func MaxTotalSize(n int64) OptionFunc {
+	return func(opt *option) {
+		opt.MaxTotalSize = n
+	}
+}
+
+// 分割回调，在后台协程中按分割顺序调用，可用于触发上传等操作
+// 开启压缩时，在压缩完成后调用，参数为压缩后的文件路径
+func OnRotate(fn func(path string)) OptionFunc {
+	return func(opt *option) {
+		opt.OnRotate = fn
+	}
+}
+
+// 落盘策略，可选none、interval、error
+func Durability(policy string) OptionFunc {
+	switch policy {
+	case DurabilityNone, DurabilityInterval, DurabilityError:
+	default:
+		panic(fmt.Sprintf("invalid durability policy: %s", policy))
+	}
+	return func(opt *option) {
+		opt.Durability = policy
+	}
+}
+
+// 定时fsync间隔，用于interval落盘策略
+func SyncInterval(d time.Duration) OptionFunc {
+	return func(opt *option) {
+		opt.SyncInterval = d
+	}
+}
+
+// 错误回调，包括写入、fsync、分割、压缩失败及缓冲区已满丢弃等
+// 可能在写入方协程及后台协程中并发调用，需保证并发安全
+func OnError(fn func(err *WriteError)) OptionFunc {
+	return func(opt *option) {
+		opt.OnError = fn
+	}
+}
+
+// 文件无法写入时的备用writer，如os.Stderr，写入失败的数据改为写入该writer
+func Fallback(w io.Writer) OptionFunc {
+	return func(opt *option) {
+		opt.Fallback = w
+	}
+}
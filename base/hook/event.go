@@ -0,0 +1,159 @@
+package hook
+
+import (
+	"time"
+
+	render "gitlab.shanhai.int/sre/library/base/logrender"
+)
+
+// 已设置的内置字段
+const (
+	fieldStartTime uint8 = 1 << iota
+	fieldEndTime
+	fieldDuration
+	fieldError
+	fieldSource
+	fieldUUID
+)
+
+// 内置字段对应的参数键
+var _eventFieldKeys = []string{
+	render.StartTimeArgKey, render.EndTimeArgKey, render.DurationArgKey,
+	render.ErrorArgKey, render.SourceArgKey, render.UUIDArgKey,
+}
+
+// 钩子事件
+//
+//	常用参数使用类型化字段存储，避免装箱及类型断言，其他参数存储在扩展字典中
+//	通过AddArg写入时按参数键自动映射，类型不匹配时存入扩展字典
+type Event struct {
+	// 开始时间，对应render.StartTimeArgKey
+	StartTime time.Time
+	// 结束时间，对应render.EndTimeArgKey
+	EndTime time.Time
+	// 持续时间，对应render.DurationArgKey
+	Duration time.Duration
+	// 错误，对应render.ErrorArgKey
+	Error error
+	// 调用源，对应render.SourceArgKey
+	Source string
+	// context中的uuid，对应render.UUIDArgKey
+	UUID string
+
+	// 通过AddArg设置的内置字段，零值字段只有设置后才会出现在参数字典中
+	fields uint8
+	// 扩展参数
+	extra map[string]interface{}
+	// 管理器参数，只读
+	base map[string]interface{}
+}
+
+// 设置参数
+func (e *Event) set(key string, value interface{}) {
+	var field uint8
+	switch key {
+	case render.StartTimeArgKey:
+		if v, ok := value.(time.Time); ok {
+			e.StartTime, field = v, fieldStartTime
+		}
+	case render.EndTimeArgKey:
+		if v, ok := value.(time.Time); ok {
+			e.EndTime, field = v, fieldEndTime
+		}
+	case render.DurationArgKey:
+		if v, ok := value.(time.Duration); ok {
+			e.Duration, field = v, fieldDuration
+		}
+	case render.ErrorArgKey:
+		if value == nil {
+			e.Error, field = nil, fieldError
+		} else if v, ok := value.(error); ok {
+			e.Error, field = v, fieldError
+		}
+	case render.SourceArgKey:
+		if v, ok := value.(string); ok {
+			e.Source, field = v, fieldSource
+		}
+	case render.UUIDArgKey:
+		if v, ok := value.(string); ok {
+			e.UUID, field = v, fieldUUID
+		}
+	}
+
+	if field != 0 {
+		e.fields |= field
+		if e.extra != nil {
+			delete(e.extra, key)
+		}
+		return
+	}
+	if e.extra == nil {
+		e.extra = make(map[string]interface{})
+	}
+	e.extra[key] = value
+}
+
+// 获取内置字段，不存在时返回false
+func (e *Event) field(key string) (interface{}, bool) {
+	switch key {
+	case render.StartTimeArgKey:
+		return e.StartTime, e.has(fieldStartTime, !e.StartTime.IsZero())
+	case render.EndTimeArgKey:
+		return e.EndTime, e.has(fieldEndTime, !e.EndTime.IsZero())
+	case render.DurationArgKey:
+		return e.Duration, e.has(fieldDuration, e.Duration != 0)
+	case render.ErrorArgKey:
+		if e.Error == nil {
+			return nil, e.has(fieldError, false)
+		}
+		return e.Error, true
+	case render.SourceArgKey:
+		return e.Source, e.has(fieldSource, e.Source != "")
+	case render.UUIDArgKey:
+		return e.UUID, e.has(fieldUUID, e.UUID != "")
+	}
+	return nil, false
+}
+
+// 内置字段是否存在，直接修改字段时以非零值判断
+func (e *Event) has(field uint8, nonZero bool) bool {
+	return nonZero || e.fields&field != 0
+}
+
+// 获取参数
+func (e *Event) get(key string) (interface{}, bool) {
+	if v, ok := e.field(key); ok {
+		return v, true
+	}
+	if v, ok := e.extra[key]; ok {
+		return v, true
+	}
+	v, ok := e.base[key]
+	return v, ok
+}
+
+// 转换为参数字典
+func (e *Event) toMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(e.base)+len(e.extra)+len(_eventFieldKeys))
+	for k, v := range e.base {
+		m[k] = v
+	}
+	for k, v := range e.extra {
+		m[k] = v
+	}
+	for _, key := range _eventFieldKeys {
+		if v, ok := e.field(key); ok {
+			m[key] = v
+		}
+	}
+	return m
+}
+
+// 重置，保留扩展字典以便复用
+func (e *Event) reset() {
+	extra := e.extra
+	for k := range extra {
+		delete(extra, k)
+	}
+	*e = Event{extra: extra}
+}
@@ -0,0 +1,128 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	render "gitlab.shanhai.int/sre/library/base/logrender"
+	"gitlab.shanhai.int/sre/library/log"
+)
+
+func TestHook_Event(t *testing.T) {
+	t.Run("typed", func(t *testing.T) {
+		st := time.Now()
+		err := errors.New("error")
+		hk := NewManager().
+			AddArg("dsn", "mongodb://127.0.0.1").
+			CreateHook(context.Background()).
+			AddArg(render.StartTimeArgKey, st).
+			AddArg(render.DurationArgKey, time.Second).
+			AddArg(render.ErrorArgKey, err).
+			AddArg("command_name", "GET")
+
+		event := hk.Event()
+		assert.Equal(t, st, event.StartTime)
+		assert.Equal(t, time.Second, event.Duration)
+		assert.Equal(t, err, event.Error)
+		assert.Equal(t, map[string]interface{}{
+			"dsn":                  "mongodb://127.0.0.1",
+			"command_name":         "GET",
+			render.StartTimeArgKey: st,
+			render.DurationArgKey:  time.Second,
+			render.ErrorArgKey:     err,
+		}, hk.Args())
+		assert.Nil(t, hk.Arg(render.EndTimeArgKey))
+	})
+
+	t.Run("zero value", func(t *testing.T) {
+		hk := NewManager().
+			CreateHook(context.Background()).
+			AddArg(render.DurationArgKey, time.Duration(0)).
+			AddArg(render.ErrorArgKey, nil)
+
+		// 通过AddArg设置的零值保留在参数字典中
+		args := hk.Args()
+		assert.Contains(t, args, render.DurationArgKey)
+		assert.Contains(t, args, render.ErrorArgKey)
+		assert.NotContains(t, args, render.StartTimeArgKey)
+	})
+
+	t.Run("type mismatch", func(t *testing.T) {
+		hk := NewManager().
+			CreateHook(context.Background()).
+			AddArg(render.ErrorArgKey, "error message").
+			AddArg(render.SourceArgKey, 1)
+
+		assert.Nil(t, hk.Event().Error)
+		assert.Equal(t, "error message", hk.Arg(render.ErrorArgKey))
+		assert.Equal(t, 1, hk.Args()[render.SourceArgKey])
+		assert.Equal(t, log.ErrorLevel, hk.Level())
+
+		// 类型匹配后覆盖扩展字典中的值
+		hk.AddArg(render.SourceArgKey, "main.go:1")
+		assert.Equal(t, "main.go:1", hk.Args()[render.SourceArgKey])
+	})
+
+	t.Run("args cache", func(t *testing.T) {
+		hk := NewManager().CreateHook(context.Background()).AddArg("a", 1)
+		args := hk.Args()
+		assert.Equal(t, 1, args["a"])
+
+		hk.AddArg("b", 2)
+		assert.Equal(t, 2, hk.Args()["b"])
+		// 已返回的字典不受影响
+		assert.NotContains(t, args, "b")
+
+		hk.Event().Duration = time.Second
+		assert.Equal(t, time.Second, hk.Args()[render.DurationArgKey])
+	})
+
+	t.Run("manager args", func(t *testing.T) {
+		hkm := NewManager().AddArg("a", 1)
+		hk := hkm.CreateHook(context.Background())
+		hkm.AddArg("a", 2)
+
+		// 已创建的钩子不受影响
+		assert.Equal(t, 1, hk.Arg("a"))
+		assert.Equal(t, 2, hkm.CreateHook(context.Background()).Arg("a"))
+	})
+}
+
+func TestHook_Release(t *testing.T) {
+	hkm := NewManager().AddArg("dsn", "dsn")
+	for i := 0; i < 10; i++ {
+		hk := hkm.CreateHook(context.Background())
+		// 回收后重新获取的钩子不包含之前的参数
+		assert.Equal(t, map[string]interface{}{"dsn": "dsn"}, hk.Args())
+		assert.Nil(t, hk.Err())
+
+		hk.AddArg(render.StartTimeArgKey, time.Now()).
+			AddArg("command_name", "GET").
+			ProcessPreHook().
+			ProcessAfterHook()
+		hk.Release()
+	}
+}
+
+func BenchmarkHook_Release(b *testing.B) {
+	hkm := NewManager().AddArg("endpoint", "127.0.0.1:6379")
+	st := time.Now()
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			hk := hkm.CreateHook(context.Background()).
+				AddArg(render.StartTimeArgKey, st).
+				AddArg("command_name", "GET").
+				ProcessPreHook()
+			hk.AddArg(render.EndTimeArgKey, st).
+				AddArg(render.DurationArgKey, time.Millisecond).
+				AddArg(render.ErrorArgKey, nil).
+				ProcessAfterHook()
+			hk.Release()
+		}
+	})
+}
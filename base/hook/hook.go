@@ -2,44 +2,103 @@ package hook
 
 import (
 	"context"
+	"sync"
 
 	_context "gitlab.shanhai.int/sre/library/base/context"
 	render "gitlab.shanhai.int/sre/library/base/logrender"
+	"gitlab.shanhai.int/sre/library/log"
 )
 
 // 处理方法
 type HandlerFunc func(hk *Hook)
 
+// 转换为前置处理方法
+func (f HandlerFunc) toPre() PreHandlerFunc {
+	if f == nil {
+		return nil
+	}
+	return func(hk *Hook) error {
+		f(hk)
+		return nil
+	}
+}
+
+// 前置处理方法，返回错误时中断操作，可用于策略校验、故障注入等
+type PreHandlerFunc func(hk *Hook) error
+
 // 钩子
 //
 // 注意：不允许多线程操作同一hook
 type Hook struct {
 	// 前置方法链
-	preChain []HandlerFunc
+	preChain []PreHandlerFunc
 	// 后置方法链
 	afterChain []HandlerFunc
 	// 日志记录器
 	logger Logger
-	// 参数字典
+	// 事件
+	event Event
+	// 参数字典缓存，参数变化时失效
 	args map[string]interface{}
+	// 前置钩子返回的错误
+	err error
 	// 上下文
 	ctx context.Context
+	// 所属模块路径
+	module string
+	// 组件名称
+	component string
+}
+
+// 钩子对象池
+var _hookPool = sync.Pool{
+	New: func() interface{} {
+		return new(Hook)
+	},
 }
 
 // 增加参数
 func (h *Hook) AddArg(key string, value interface{}) *Hook {
-	h.args[key] = value
+	h.event.set(key, value)
+	h.args = nil
 	return h
 }
 
 // 获取全部参数
+//
+//	由事件转换的参数字典，参数不变时复用，返回的字典只读
 func (h *Hook) Args() map[string]interface{} {
+	if h.args == nil {
+		h.args = h.event.toMap()
+	}
 	return h.args
 }
 
 // 获取参数
 func (h *Hook) Arg(key string) interface{} {
-	return h.args[key]
+	v, _ := h.event.get(key)
+	return v
+}
+
+// 获取事件，可直接读写类型化字段
+func (h *Hook) Event() *Event {
+	// 调用方可能修改字段，参数字典需重建
+	h.args = nil
+	return &h.event
+}
+
+// 回收钩子
+//
+//	组件在执行后置钩子后调用，之后不能再使用该钩子，处理方法中不能异步持有钩子
+func (h *Hook) Release() {
+	h.event.reset()
+	*h = Hook{event: h.event}
+	_hookPool.Put(h)
+}
+
+// 获取组件名称
+func (h *Hook) Component() string {
+	return h.component
 }
 
 // 获取日志记录器
@@ -47,6 +106,21 @@ func (h *Hook) GetLogger() Logger {
 	return h.logger
 }
 
+// 获取日志级别
+//
+//	存在错误时为ERROR级别，否则为INFO级别
+func (h *Hook) Level() log.Level {
+	if h.Arg(render.ErrorArgKey) != nil {
+		return log.ErrorLevel
+	}
+	return log.InfoLevel
+}
+
+// 是否需要打印日志，由所属模块的日志级别决定
+func (h *Hook) LogEnabled() bool {
+	return log.Enabled(h.module, h.Level())
+}
+
 // 设置上下文
 func (h *Hook) SetContext(ctx context.Context) *Hook {
 	h.ctx = ctx
@@ -64,15 +138,32 @@ func (h *Hook) prepareSystemArgs() *Hook {
 	h.AddArg(render.UUIDArgKey, _context.GetStringOrDefault(h.ctx, _context.ContextUUIDKey, "unknown")).
 		AddArg(render.WebUrlArgKey, _context.GetString(h.ctx, _context.ContextRequestPathKey)).
 		AddArg(render.WebMethodArgKey, _context.GetString(h.ctx, _context.ContextRequestMethodKey))
+	// 上下文日志字段由渲染器自动输出
+	if fields := _context.GetLogFields(h.ctx); len(fields) != 0 {
+		h.AddArg(render.ContextFieldsArgKey, fields)
+	}
 
 	return h
 }
 
+// 获取前置钩子返回的错误
+//
+//	不为空时组件应跳过本次操作，执行后置钩子后将该错误返回给调用方
+func (h *Hook) Err() error {
+	return h.err
+}
+
 // 执行前置钩子
+//
+//	前置方法返回错误时中断后续前置方法，并将错误写入参数
 func (h *Hook) ProcessPreHook() *Hook {
 	h.prepareSystemArgs()
 	for _, f := range h.preChain {
-		f(h)
+		if err := f(h); err != nil {
+			h.err = err
+			h.AddArg(render.ErrorArgKey, err)
+			break
+		}
 	}
 	return h
 }
@@ -87,8 +178,12 @@ func (h *Hook) ProcessAfterHook() *Hook {
 }
 
 // 执行方法
-func (h *Hook) Do(f func()) {
-	h.ProcessPreHook()
-	f()
+//
+//	前置钩子返回错误时不执行方法，执行后置钩子后返回该错误
+func (h *Hook) Do(f func()) error {
+	if h.ProcessPreHook().err == nil {
+		f()
+	}
 	h.ProcessAfterHook()
+	return h.err
 }
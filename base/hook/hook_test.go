@@ -2,11 +2,14 @@ package hook
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	_context "gitlab.shanhai.int/sre/library/base/context"
 	render "gitlab.shanhai.int/sre/library/base/logrender"
+	"gitlab.shanhai.int/sre/library/log"
 )
 
 func TestHook_AddArg(t *testing.T) {
@@ -28,9 +31,9 @@ func TestHook_Do(t *testing.T) {
 
 		NewManager().
 			RegisterHook(func(hk *Hook) {
-				sts = render.PatternStartTime(hk.args).StringValue()
+				sts = render.PatternStartTime(hk.Args()).StringValue()
 			}, func(hk *Hook) {
-				ets = render.PatternEndTime(hk.args).StringValue()
+				ets = render.PatternEndTime(hk.Args()).StringValue()
 			}).
 			CreateHook(context.Background()).
 			AddArg(render.StartTimeArgKey, st).
@@ -41,6 +44,33 @@ func TestHook_Do(t *testing.T) {
 			})
 		assert.Equal(t, et.Format("2006/01/02 15:04:05.000"), ets)
 	})
+
+	t.Run("abort", func(t *testing.T) {
+		abortErr := errors.New("abort")
+		var called, afterCalled, nextCalled bool
+
+		err := NewManager().
+			RegisterHandler(Handler{
+				Name: "policy",
+				Pre: func(hk *Hook) error {
+					return abortErr
+				},
+			}).
+			RegisterHook(func(hk *Hook) {
+				nextCalled = true
+			}, func(hk *Hook) {
+				afterCalled = true
+				assert.Equal(t, abortErr, hk.Arg(render.ErrorArgKey))
+			}).
+			CreateHook(context.Background()).
+			Do(func() {
+				called = true
+			})
+		assert.Equal(t, abortErr, err)
+		assert.False(t, called)
+		assert.False(t, nextCalled)
+		assert.True(t, afterCalled)
+	})
 }
 
 func TestHook_ProcessAfterHook(t *testing.T) {
@@ -50,7 +80,7 @@ func TestHook_ProcessAfterHook(t *testing.T) {
 
 		hk := NewManager().
 			RegisterAfterHook(func(hk *Hook) {
-				ets = render.PatternEndTime(hk.args).StringValue()
+				ets = render.PatternEndTime(hk.Args()).StringValue()
 			}).
 			CreateHook(context.Background()).
 			AddArg(render.EndTimeArgKey, et)
@@ -67,7 +97,7 @@ func TestHook_ProcessPreHook(t *testing.T) {
 
 		hk := NewManager().
 			RegisterPreHook(func(hk *Hook) {
-				sts = render.PatternStartTime(hk.args).StringValue()
+				sts = render.PatternStartTime(hk.Args()).StringValue()
 			}).
 			CreateHook(context.Background()).
 			AddArg(render.StartTimeArgKey, st)
@@ -76,3 +106,46 @@ func TestHook_ProcessPreHook(t *testing.T) {
 		assert.Equal(t, st.Format("2006/01/02 15:04:05.000"), sts)
 	})
 }
+
+func TestHook_LogEnabled(t *testing.T) {
+	defer log.ResetModuleLevels(nil)
+
+	t.Run("normal", func(t *testing.T) {
+		hk := NewManager().CreateHook(context.Background())
+		assert.Equal(t, log.InfoLevel, hk.Level())
+		assert.True(t, hk.LogEnabled())
+
+		hk.AddArg(render.ErrorArgKey, errors.New("error"))
+		assert.Equal(t, log.ErrorLevel, hk.Level())
+	})
+
+	t.Run("module level", func(t *testing.T) {
+		log.SetModuleLevel("base/hook", log.ErrorLevel)
+
+		hk := NewManager().CreateHook(context.Background())
+		assert.False(t, hk.LogEnabled())
+
+		hk.AddArg(render.ErrorArgKey, errors.New("error"))
+		assert.True(t, hk.LogEnabled())
+
+		hk = NewManager().SetModule("other/").CreateHook(context.Background())
+		assert.True(t, hk.LogEnabled())
+	})
+}
+
+func TestHook_ContextFields(t *testing.T) {
+	_context.RegisterLogField("trace_id", "context_trace_id")
+	defer _context.UnregisterLogField("trace_id")
+
+	ctx := context.WithValue(context.Background(), "context_trace_id", "t1")
+	ctx = _context.WithLogField(ctx, "tenant", "shanhai")
+
+	hk := NewManager().CreateHook(ctx).ProcessPreHook()
+	assert.Equal(t, map[string]interface{}{
+		"trace_id": "t1",
+		"tenant":   "shanhai",
+	}, hk.Arg(render.ContextFieldsArgKey))
+
+	hk = NewManager().CreateHook(context.Background()).ProcessPreHook()
+	assert.Nil(t, hk.Arg(render.ContextFieldsArgKey))
+}
@@ -46,7 +46,7 @@ func GetDefaultLogger(conf *render.Config, patternMap map[string]render.PatternF
 	if conf.Stdout {
 		writers = append(writers, SampleLogWriter{
 			Writer: os.Stdout,
-			Render: render.NewPatternRender(patternMap, conf.StdoutPattern),
+			Render: render.NewRender(conf.Format, patternMap, conf.StdoutPattern, conf.Options()...),
 		})
 	}
 	if conf.OutDir != "" {
@@ -56,7 +56,7 @@ func GetDefaultLogger(conf *render.Config, patternMap map[string]render.PatternF
 		)
 		writers = append(writers, SampleLogWriter{
 			Writer: fw,
-			Render: render.NewPatternRender(patternMap, conf.OutPattern),
+			Render: render.NewRender(conf.Format, patternMap, conf.OutPattern, conf.Options()...),
 		})
 	}
 
@@ -2,67 +2,245 @@ package hook
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
+	goRuntime "runtime"
+	"sort"
 	"sync"
 
 	"github.com/opentracing/opentracing-go"
 	render "gitlab.shanhai.int/sre/library/base/logrender"
+	"gitlab.shanhai.int/sre/library/base/redact"
 	"gitlab.shanhai.int/sre/library/net/sentry"
 	"gitlab.shanhai.int/sre/library/net/tracing"
 )
 
+// 钩子优先级，按洋葱模型执行：前置方法数值越小越先执行，后置方法数值越大越先执行
+//
+//	相同优先级的前置方法及后置方法均按注册顺序执行
+const (
+	// 最外层，前置方法最先执行、后置方法最后执行，用于链路跟踪、日志等需要包裹其他钩子的处理器
+	PriorityFirst = -100
+	// 默认优先级
+	PriorityDefault = 0
+	// 最内层，前置方法最后执行、后置方法最先执行
+	PriorityLast = 100
+)
+
+// 内置处理器名称
+const (
+	// 日志钩子
+	LogHookName = "log"
+	// 链路跟踪钩子
+	TracingHookName = "tracing"
+	// sentry面包屑钩子
+	SentryHookName = "sentry"
+)
+
+// 处理器
+type Handler struct {
+	// 名称，为空时无法删除或替换
+	Name string
+	// 优先级，数值越小越外层
+	Priority int
+	// 前置方法，返回错误时中断后续前置方法，由组件跳过本次操作并返回该错误
+	Pre PreHandlerFunc
+	// 后置方法
+	After HandlerFunc
+}
+
 // 钩子管理器
 type Manager struct {
+	// 保护处理器及方法链，注册与创建钩子可并发执行
+	mu *sync.RWMutex
+	// 按执行顺序排列的处理器
+	handlers []Handler
 	// 前置方法链
-	preChain []HandlerFunc
-	// 后置方法链
+	preChain []PreHandlerFunc
+	// 后置方法链，优先级与前置方法链顺序相反
 	afterChain []HandlerFunc
 	// 日志记录器
 	logger Logger
-	// 参数字典
-	args *sync.Map
+	// 参数字典，写时复制，创建钩子时共享
+	args map[string]interface{}
+	// 所属模块路径，用于匹配模块日志级别
+	module string
+	// 组件名称
+	component string
 }
 
 // 新建管理器
 func NewManager() *Manager {
 	manager := &Manager{
-		preChain:   []HandlerFunc{},
+		mu:         new(sync.RWMutex),
+		preChain:   []PreHandlerFunc{},
 		afterChain: []HandlerFunc{},
-		args:       new(sync.Map),
+		args:       make(map[string]interface{}),
+	}
+	// 以调用方所在目录作为所属模块，如 database/redis
+	if _, file, _, ok := goRuntime.Caller(1); ok {
+		manager.module = filepath.Dir(file) + "/"
 	}
 	return manager
 }
 
 // 增加参数
 func (m *Manager) AddArg(key string, value interface{}) *Manager {
-	m.args.Store(key, value)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	args := make(map[string]interface{}, len(m.args)+1)
+	for k, v := range m.args {
+		args[k] = v
+	}
+	args[key] = value
+	m.args = args
+	return m
+}
+
+// 注册处理器
+//
+//	按优先级插入，相同优先级排在已注册处理器之后，名称重复时panic
+func (m *Manager) RegisterHandler(handler Handler) *Manager {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if handler.Name != "" && m.indexOf(handler.Name) >= 0 {
+		panic(fmt.Sprintf("hook handler %s already registered", handler.Name))
+	}
+	m.setHandlers(insertHandler(m.handlers, handler))
+	return m
+}
+
+// 替换同名处理器，不存在时注册
+//
+//	优先级不变时保持原有执行位置
+func (m *Manager) ReplaceHandler(handler Handler) *Manager {
+	if handler.Name == "" {
+		panic("hook handler name can't be empty")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index := m.indexOf(handler.Name)
+	switch {
+	case index < 0:
+		m.setHandlers(insertHandler(m.handlers, handler))
+	case m.handlers[index].Priority == handler.Priority:
+		handlers := make([]Handler, len(m.handlers))
+		copy(handlers, m.handlers)
+		handlers[index] = handler
+		m.setHandlers(handlers)
+	default:
+		m.setHandlers(insertHandler(removeHandler(m.handlers, index), handler))
+	}
 	return m
 }
 
+// 删除同名处理器
+func (m *Manager) RemoveHandler(name string) *Manager {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if index := m.indexOf(name); index >= 0 {
+		m.setHandlers(removeHandler(m.handlers, index))
+	}
+	return m
+}
+
+// 按优先级插入处理器，返回新切片
+func insertHandler(handlers []Handler, handler Handler) []Handler {
+	index := sort.Search(len(handlers), func(i int) bool {
+		return handlers[i].Priority > handler.Priority
+	})
+	result := make([]Handler, 0, len(handlers)+1)
+	result = append(result, handlers[:index]...)
+	result = append(result, handler)
+	return append(result, handlers[index:]...)
+}
+
+// 删除指定下标的处理器，返回新切片
+func removeHandler(handlers []Handler, index int) []Handler {
+	result := make([]Handler, 0, len(handlers)-1)
+	result = append(result, handlers[:index]...)
+	return append(result, handlers[index+1:]...)
+}
+
+// 按执行顺序获取处理器名称，未命名处理器为空字符串
+func (m *Manager) HandlerNames() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, len(m.handlers))
+	for i, handler := range m.handlers {
+		names[i] = handler.Name
+	}
+	return names
+}
+
+// 查找处理器下标，需持有锁
+func (m *Manager) indexOf(name string) int {
+	for i, handler := range m.handlers {
+		if handler.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// 设置处理器并重建方法链，需持有锁
+//
+//	每次均新建切片，已创建的钩子及值拷贝的管理器不受影响
+func (m *Manager) setHandlers(handlers []Handler) {
+	preChain := make([]PreHandlerFunc, 0, len(handlers))
+	afterChain := make([]HandlerFunc, 0, len(handlers))
+	for _, handler := range handlers {
+		if handler.Pre != nil {
+			preChain = append(preChain, handler.Pre)
+		}
+	}
+	// 后置方法按优先级逆序执行，外层处理器包裹内层处理器，相同优先级按注册顺序执行
+	for end := len(handlers); end > 0; {
+		start := end - 1
+		for start > 0 && handlers[start-1].Priority == handlers[end-1].Priority {
+			start--
+		}
+		for _, handler := range handlers[start:end] {
+			if handler.After != nil {
+				afterChain = append(afterChain, handler.After)
+			}
+		}
+		end = start
+	}
+	m.handlers = handlers
+	m.preChain = preChain
+	m.afterChain = afterChain
+}
+
 // 注册前置钩子
 func (m *Manager) RegisterPreHook(preHook HandlerFunc) *Manager {
-	m.preChain = append(m.preChain, preHook)
-	return m
+	return m.RegisterHandler(Handler{Pre: preHook.toPre()})
 }
 
 // 注册后置钩子
 func (m *Manager) RegisterAfterHook(afterHook HandlerFunc) *Manager {
-	m.afterChain = append(m.afterChain, afterHook)
-	return m
+	return m.RegisterHandler(Handler{After: afterHook})
 }
 
 // 注册sentry面包屑钩子
 func (m *Manager) RegisterSentryBreadCrumbHook(getBreadcrumb func(hk *Hook) *sentry.Breadcrumb) *Manager {
-	m.RegisterAfterHook(func(hk *Hook) {
-		hk.SetContext(sentry.AddBreadcrumb(hk.Context(), getBreadcrumb(hk)))
+	return m.RegisterHandler(Handler{
+		Name:     SentryHookName,
+		Priority: PriorityDefault,
+		After: func(hk *Hook) {
+			hk.SetContext(sentry.AddBreadcrumb(hk.Context(), getBreadcrumb(hk)))
+		},
 	})
-	return m
 }
 
 // 注册钩子
 func (m *Manager) RegisterHook(preHook HandlerFunc, afterHook HandlerFunc) *Manager {
-	m.RegisterPreHook(preHook)
-	m.RegisterAfterHook(afterHook)
-	return m
+	return m.RegisterHandler(Handler{Pre: preHook.toPre(), After: afterHook})
 }
 
 // 注册链路跟踪钩子
@@ -71,28 +249,33 @@ func (m *Manager) RegisterTracingHook(
 	preFunc func(hk *Hook, span opentracing.Span),
 	afterFunc func(hk *Hook, span opentracing.Span),
 ) *Manager {
-	m.RegisterHook(func(hk *Hook) {
-		name := getSpanNameFunc(hk)
-		if name == "" {
-			return
-		}
+	return m.RegisterHandler(Handler{
+		Name:     TracingHookName,
+		Priority: PriorityFirst,
+		Pre: func(hk *Hook) error {
+			name := getSpanNameFunc(hk)
+			if name == "" {
+				return nil
+			}
 
-		parentSpan, err := tracing.GetCurrentSpanFromContext(hk.Context())
-		if err != nil {
-			return
-		}
-		span := parentSpan.Tracer().StartSpan(name, opentracing.ChildOf(parentSpan.Context()))
-		preFunc(hk, span)
-		hk.SetContext(tracing.SetCurrentSpanToContext(hk.Context(), span))
-	}, func(hk *Hook) {
-		span, err := tracing.GetCurrentSpanFromContext(hk.Context())
-		if err != nil {
-			return
-		}
-		defer span.Finish()
-		afterFunc(hk, span)
+			parentSpan, err := tracing.GetCurrentSpanFromContext(hk.Context())
+			if err != nil {
+				return nil
+			}
+			span := parentSpan.Tracer().StartSpan(name, opentracing.ChildOf(parentSpan.Context()))
+			preFunc(hk, span)
+			hk.SetContext(tracing.SetCurrentSpanToContext(hk.Context(), span))
+			return nil
+		},
+		After: func(hk *Hook) {
+			span, err := tracing.GetCurrentSpanFromContext(hk.Context())
+			if err != nil {
+				return
+			}
+			defer span.Finish()
+			afterFunc(hk, span)
+		},
 	})
-	return m
 }
 
 // 注册日志钩子
@@ -103,10 +286,38 @@ func (m *Manager) RegisterLogHook(logConfig *render.Config, patternMap map[strin
 	}
 
 	m.SetLogger(GetDefaultLogger(logConfig, patternMap))
-	m.RegisterAfterHook(func(hook *Hook) {
-		hook.logger.Print(hook.args)
+	// 位于最外层，后置方法在其他钩子之后执行，保证打印其他钩子写入的参数
+	return m.RegisterHandler(Handler{
+		Name:     LogHookName,
+		Priority: PriorityFirst,
+		After: func(hook *Hook) {
+			if hook.LogEnabled() {
+				// 脱敏后打印，不影响其他钩子使用的参数
+				hook.logger.Print(redact.Default().Map(hook.Args()))
+			}
+		},
 	})
+}
 
+// 设置所属模块路径
+//
+//	默认为创建管理器的调用方所在目录，用于匹配模块日志级别
+func (m *Manager) SetModule(module string) *Manager {
+	m.module = module
+	return m
+}
+
+// 设置组件名称，并注册该组件的全局处理器
+//
+//	只能设置一次，全局处理器见RegisterGlobalHandler
+func (m *Manager) SetComponent(component string) *Manager {
+	if m.component != "" {
+		panic(fmt.Sprintf("hook component already set to %s", m.component))
+	}
+	m.component = component
+	for _, handler := range GlobalHandlers(component) {
+		m.RegisterHandler(handler)
+	}
 	return m
 }
 
@@ -132,17 +343,17 @@ func (m *Manager) Close() {
 //
 // 注意：不允许多线程操作同一hook
 func (m *Manager) CreateHook(ctx context.Context) *Hook {
-	// 拷贝
-	args := make(map[string]interface{})
-	m.args.Range(func(key, value interface{}) bool {
-		args[key.(string)] = value
-		return true
-	})
-	return &Hook{
-		preChain:   m.preChain,
-		afterChain: m.afterChain,
-		logger:     m.logger,
-		args:       args,
-		ctx:        ctx,
-	}
+	m.mu.RLock()
+	preChain, afterChain, args := m.preChain, m.afterChain, m.args
+	m.mu.RUnlock()
+
+	hk := _hookPool.Get().(*Hook)
+	hk.preChain = preChain
+	hk.afterChain = afterChain
+	hk.logger = m.logger
+	hk.event.base = args
+	hk.ctx = ctx
+	hk.module = m.module
+	hk.component = m.component
+	return hk
 }
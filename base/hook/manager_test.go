@@ -9,6 +9,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	render "gitlab.shanhai.int/sre/library/base/logrender"
+	"gitlab.shanhai.int/sre/library/base/redact"
 )
 
 func TestManager_RegisterLogHook(t *testing.T) {
@@ -51,8 +52,42 @@ func TestManager_RegisterLogHook(t *testing.T) {
 			})
 		})
 	})
+
+	t.Run("redact", func(t *testing.T) {
+		rd, err := redact.New(&redact.Config{
+			Rules: []*redact.Rule{{Key: "password"}},
+		})
+		assert.Nil(t, err)
+		redact.SetDefault(rd)
+		defer redact.SetDefault(nil)
+
+		logger := new(recordLogger)
+		hk := NewManager().
+			RegisterLogHook(&render.Config{Stdout: true}, nil).
+			SetLogger(logger).
+			CreateHook(context.Background()).
+			AddArg("request", map[string]interface{}{"user": "u1", "password": "123"})
+
+		hk.ProcessPreHook()
+		hk.ProcessAfterHook()
+
+		assert.Equal(t, 1, len(logger.logs))
+		assert.Equal(t, map[string]interface{}{"user": "u1", "password": "***"}, logger.logs[0]["request"])
+		// 不影响钩子中的参数
+		assert.Equal(t, "123", hk.Arg("request").(map[string]interface{})["password"])
+	})
+}
+
+type recordLogger struct {
+	logs []map[string]interface{}
 }
 
+func (l *recordLogger) Print(m map[string]interface{}) {
+	l.logs = append(l.logs, m)
+}
+
+func (l *recordLogger) Close() {}
+
 func TestManager_AddArg(t *testing.T) {
 	t.Run("normal", func(t *testing.T) {
 		st := time.Now()
@@ -86,9 +121,9 @@ func TestManager_RegisterHook(t *testing.T) {
 
 		hk := NewManager().
 			RegisterHook(func(hk *Hook) {
-				sts = render.PatternStartTime(hk.args).StringValue()
+				sts = render.PatternStartTime(hk.Args()).StringValue()
 			}, func(hk *Hook) {
-				ets = render.PatternEndTime(hk.args).StringValue()
+				ets = render.PatternEndTime(hk.Args()).StringValue()
 			}).
 			CreateHook(context.Background()).
 			AddArg(render.StartTimeArgKey, st).
@@ -114,9 +149,9 @@ func BenchmarkManager_RegisterHook(b *testing.B) {
 		b.RunParallel(func(pb *testing.PB) {
 			for pb.Next() {
 				hkm.RegisterHook(func(hk *Hook) {
-					render.PatternStartTime(hk.args).StringValue()
+					render.PatternStartTime(hk.Args()).StringValue()
 				}, func(hk *Hook) {
-					render.PatternEndTime(hk.args).StringValue()
+					render.PatternEndTime(hk.Args()).StringValue()
 				}).
 					CreateHook(context.Background())
 			}
@@ -148,3 +183,111 @@ func TestManager_CreateHook(t *testing.T) {
 		}
 	})
 }
+
+func TestManager_RegisterHandler(t *testing.T) {
+	// 记录执行顺序
+	record := func(order *[]string, name string) Handler {
+		return Handler{
+			Name: name,
+			Pre: func(hk *Hook) error {
+				*order = append(*order, "pre:"+name)
+				return nil
+			},
+			After: func(hk *Hook) {
+				*order = append(*order, "after:"+name)
+			},
+		}
+	}
+
+	t.Run("priority", func(t *testing.T) {
+		var order []string
+		logger := new(recordLogger)
+		hkm := NewManager().
+			RegisterLogHook(&render.Config{Stdout: true}, nil).
+			SetLogger(logger).
+			RegisterHandler(record(&order, "a"))
+		h := record(&order, "b")
+		h.Priority = PriorityFirst
+		hkm.RegisterHandler(h).
+			RegisterHandler(record(&order, "c")).
+			RegisterAfterHook(func(hk *Hook) {
+				hk.AddArg("after", true)
+			})
+
+		assert.Equal(t, []string{LogHookName, "b", "a", "c", ""}, hkm.HandlerNames())
+		hk := hkm.CreateHook(context.Background())
+		assert.Nil(t, hk.Do(func() {}))
+		// 后置方法按优先级逆序执行，相同优先级按注册顺序执行
+		assert.Equal(t, []string{"pre:b", "pre:a", "pre:c", "after:a", "after:c", "after:b"}, order)
+		// 日志钩子位于最外层，可获取其他钩子写入的参数
+		assert.Equal(t, 1, len(logger.logs))
+		assert.Equal(t, true, logger.logs[0]["after"])
+	})
+
+	t.Run("registration order", func(t *testing.T) {
+		// 相同优先级的后置钩子按注册顺序执行，可读取先注册的钩子写入的参数
+		var got interface{}
+		NewManager().
+			RegisterAfterHook(func(hk *Hook) {
+				hk.AddArg("first", true)
+			}).
+			RegisterAfterHook(func(hk *Hook) {
+				got = hk.Arg("first")
+			}).
+			CreateHook(context.Background()).
+			ProcessPreHook().
+			ProcessAfterHook()
+		assert.Equal(t, true, got)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		var order []string
+		hkm := NewManager().RegisterHandler(record(&order, "a"))
+		assert.Panics(t, func() {
+			hkm.RegisterHandler(record(&order, "a"))
+		})
+	})
+
+	t.Run("replace and remove", func(t *testing.T) {
+		var order []string
+		hkm := NewManager().
+			RegisterHandler(record(&order, "a")).
+			RegisterHandler(record(&order, "b")).
+			RegisterHandler(record(&order, "c"))
+		// 创建后的钩子不受影响
+		hk := hkm.CreateHook(context.Background())
+
+		// 优先级不变时保持位置
+		hkm.ReplaceHandler(Handler{Name: "b", Pre: func(hk *Hook) error {
+			order = append(order, "pre:b2")
+			return nil
+		}})
+		assert.Equal(t, []string{"a", "b", "c"}, hkm.HandlerNames())
+		// 优先级变化时重新排序
+		hkm.ReplaceHandler(Handler{Name: "a", Priority: PriorityLast})
+		assert.Equal(t, []string{"b", "c", "a"}, hkm.HandlerNames())
+		hkm.RemoveHandler("c").RemoveHandler("unknown")
+		assert.Equal(t, []string{"b", "a"}, hkm.HandlerNames())
+
+		hkm.CreateHook(context.Background()).ProcessPreHook().ProcessAfterHook()
+		assert.Equal(t, []string{"pre:b2"}, order)
+
+		order = nil
+		hk.ProcessPreHook()
+		assert.Equal(t, []string{"pre:a", "pre:b", "pre:c"}, order)
+
+		assert.Panics(t, func() {
+			hkm.ReplaceHandler(Handler{})
+		})
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		var order []string
+		hkm := NewManager().RegisterHandler(record(&order, "a"))
+		// 值拷贝的管理器注册处理器不影响原管理器
+		copied := *hkm
+		copied.RegisterHandler(record(&order, "b"))
+		assert.Equal(t, []string{"a", "b"}, copied.HandlerNames())
+		assert.Equal(t, []string{"a"}, hkm.HandlerNames())
+	})
+}
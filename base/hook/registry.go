@@ -0,0 +1,78 @@
+package hook
+
+import (
+	"fmt"
+	"sync"
+)
+
+// 组件名称
+const (
+	// 全部组件
+	ComponentAll        = "*"
+	ComponentRedis      = "redis"
+	ComponentMongo      = "mongo"
+	ComponentGorm       = "gorm"
+	ComponentHttpClient = "httpclient"
+	ComponentGoroutine  = "goroutine"
+	ComponentQueue      = "queue"
+	ComponentRedlock    = "redlock"
+	ComponentEtcd       = "etcd"
+	ComponentAgollo     = "agollo"
+)
+
+// 全局处理器注册表
+var _registry = &registry{
+	handlers: make(map[string][]Handler),
+}
+
+// 注册表
+type registry struct {
+	mu sync.RWMutex
+	// 组件名称对应的处理器
+	handlers map[string][]Handler
+}
+
+// 注册全局处理器
+//
+//	之后设置了对应组件的管理器都会自动注册该处理器，ComponentAll对全部组件生效
+//	同一组件内名称重复时panic
+func RegisterGlobalHandler(component string, handler Handler) {
+	_registry.mu.Lock()
+	defer _registry.mu.Unlock()
+
+	if handler.Name != "" {
+		for _, h := range _registry.handlers[component] {
+			if h.Name == handler.Name {
+				panic(fmt.Sprintf("global hook handler %s already registered for %s", handler.Name, component))
+			}
+		}
+	}
+	_registry.handlers[component] = append(_registry.handlers[component], handler)
+}
+
+// 删除全局处理器，不影响已创建的管理器
+func RemoveGlobalHandler(component, name string) {
+	_registry.mu.Lock()
+	defer _registry.mu.Unlock()
+
+	handlers := make([]Handler, 0, len(_registry.handlers[component]))
+	for _, h := range _registry.handlers[component] {
+		if h.Name != name {
+			handlers = append(handlers, h)
+		}
+	}
+	_registry.handlers[component] = handlers
+}
+
+// 获取组件的全局处理器，包括对全部组件生效的处理器
+func GlobalHandlers(component string) []Handler {
+	_registry.mu.RLock()
+	defer _registry.mu.RUnlock()
+
+	handlers := make([]Handler, 0)
+	handlers = append(handlers, _registry.handlers[ComponentAll]...)
+	if component != ComponentAll {
+		handlers = append(handlers, _registry.handlers[component]...)
+	}
+	return handlers
+}
@@ -0,0 +1,54 @@
+package hook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterGlobalHandler(t *testing.T) {
+	var components []string
+	RegisterGlobalHandler(ComponentAll, Handler{
+		Name: "audit",
+		After: func(hk *Hook) {
+			components = append(components, hk.Component())
+		},
+	})
+	defer RemoveGlobalHandler(ComponentAll, "audit")
+	RegisterGlobalHandler(ComponentRedis, Handler{
+		Name:     "redis_only",
+		Priority: PriorityFirst,
+	})
+	defer RemoveGlobalHandler(ComponentRedis, "redis_only")
+
+	t.Run("normal", func(t *testing.T) {
+		redisManager := NewManager().SetComponent(ComponentRedis)
+		assert.Equal(t, []string{"redis_only", "audit"}, redisManager.HandlerNames())
+		mongoManager := NewManager().SetComponent(ComponentMongo)
+		assert.Equal(t, []string{"audit"}, mongoManager.HandlerNames())
+		// 未设置组件的管理器不注册全局处理器
+		assert.Equal(t, []string{}, NewManager().HandlerNames())
+
+		redisManager.CreateHook(context.Background()).ProcessAfterHook()
+		mongoManager.CreateHook(context.Background()).ProcessAfterHook()
+		assert.Equal(t, []string{ComponentRedis, ComponentMongo}, components)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		assert.Panics(t, func() {
+			RegisterGlobalHandler(ComponentRedis, Handler{Name: "redis_only"})
+		})
+		assert.Panics(t, func() {
+			NewManager().SetComponent(ComponentRedis).SetComponent(ComponentRedis)
+		})
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		hkm := NewManager().SetComponent(ComponentRedis)
+		RemoveGlobalHandler(ComponentRedis, "redis_only")
+		// 不影响已创建的管理器
+		assert.Equal(t, []string{"redis_only", "audit"}, hkm.HandlerNames())
+		assert.Equal(t, []string{"audit"}, NewManager().SetComponent(ComponentRedis).HandlerNames())
+	})
+}
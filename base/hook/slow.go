@@ -0,0 +1,137 @@
+package hook
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.shanhai.int/sre/library/base/ctime"
+	render "gitlab.shanhai.int/sre/library/base/logrender"
+	"gitlab.shanhai.int/sre/library/log"
+	"gitlab.shanhai.int/sre/library/net/metric"
+	"gitlab.shanhai.int/sre/library/net/sentry"
+	"gitlab.shanhai.int/sre/library/net/tracing"
+)
+
+// 慢操作钩子
+const SlowHookName = "slow"
+
+// 慢操作配置
+type SlowConfig struct {
+	// 默认阈值，为0时只检测Commands中的命令
+	Threshold ctime.Duration `yaml:"threshold"`
+	// 按命令设置的阈值，忽略大小写，优先于默认阈值，小于0时不检测该命令
+	Commands map[string]ctime.Duration `yaml:"commands"`
+	// 是否增加sentry面包屑
+	SentryBreadcrumb bool `yaml:"sentryBreadcrumb"`
+	// 是否上报sentry事件
+	SentryEvent bool `yaml:"sentryEvent"`
+}
+
+// 获取命令的阈值，为0时不检测
+func (c *SlowConfig) threshold(command string) time.Duration {
+	for name, threshold := range c.Commands {
+		if strings.EqualFold(name, command) {
+			if threshold < 0 {
+				return 0
+			}
+			return time.Duration(threshold)
+		}
+	}
+	return time.Duration(c.Threshold)
+}
+
+// 是否需要检测
+func (c *SlowConfig) enabled() bool {
+	if c == nil {
+		return false
+	}
+	if c.Threshold > 0 {
+		return true
+	}
+	for _, threshold := range c.Commands {
+		if threshold > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// 注册慢操作钩子
+//
+//	操作耗时超过阈值时，打印WARN日志、增加slow_operations_total计数、在当前span中记录，并按配置上报sentry
+//	getCommand用于获取匹配阈值的命令名称，配置为空或未设置阈值时不注册
+func (m *Manager) RegisterSlowHook(config *SlowConfig, getCommand func(hk *Hook) string) *Manager {
+	if !config.enabled() {
+		return m
+	}
+
+	return m.RegisterHandler(Handler{
+		Name:     SlowHookName,
+		Priority: PriorityDefault,
+		After: func(hk *Hook) {
+			duration, ok := hk.Arg(render.DurationArgKey).(time.Duration)
+			if !ok {
+				return
+			}
+			command := getCommand(hk)
+			threshold := config.threshold(command)
+			if threshold <= 0 || duration < threshold {
+				return
+			}
+			hk.processSlow(config, command, duration, threshold)
+		},
+	})
+}
+
+// 处理慢操作
+func (h *Hook) processSlow(config *SlowConfig, command string, duration, threshold time.Duration) {
+	ctx := h.Context()
+	source := render.PatternSource(h.args).StringValue()
+
+	// 统一命令名大小写，避免同一命令产生多个标签值
+	metric.SlowOperationsTotal.WithLabelValues(h.component, strings.ToUpper(command)).Inc()
+
+	if log.Enabled(h.module, log.WarnLevel) {
+		log.Warnw(ctx, "slow operation",
+			log.String("component", h.component),
+			log.String("command", command),
+			log.Duration("duration", duration),
+			log.Duration("threshold", threshold),
+			log.String("source", source),
+		)
+	}
+
+	if span, err := tracing.GetCurrentSpanFromContext(ctx); err == nil {
+		span.SetTag("slow", true)
+		span.LogKV(
+			"event", "slow operation",
+			"command", command,
+			"duration", duration.String(),
+			"threshold", threshold.String(),
+		)
+	}
+
+	if !config.SentryBreadcrumb && !config.SentryEvent {
+		return
+	}
+	breadcrumb := &sentry.Breadcrumb{
+		Category: fmt.Sprintf("slow.%s", h.component),
+		Data: map[string]interface{}{
+			"command":   command,
+			"duration":  duration.String(),
+			"threshold": threshold.String(),
+			"source":    source,
+		},
+	}
+	if config.SentryEvent {
+		err := errors.Errorf("slow %s operation %s: %s exceeds %s", h.component, command, duration, threshold)
+		sentry.CaptureWithBreadAndTags(ctx, err, breadcrumb,
+			sentry.Tag{Key: "component", Value: h.component},
+			sentry.Tag{Key: "command", Value: command},
+		)
+		return
+	}
+	h.SetContext(sentry.AddBreadcrumb(ctx, breadcrumb))
+}
@@ -0,0 +1,75 @@
+package hook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"gitlab.shanhai.int/sre/library/base/ctime"
+	render "gitlab.shanhai.int/sre/library/base/logrender"
+	"gitlab.shanhai.int/sre/library/net/metric"
+	"gitlab.shanhai.int/sre/library/net/tracing"
+)
+
+func TestManager_RegisterSlowHook(t *testing.T) {
+	config := &SlowConfig{
+		Threshold: ctime.Duration(100 * time.Millisecond),
+		Commands: map[string]ctime.Duration{
+			"KEYS":  ctime.Duration(10 * time.Millisecond),
+			"BLPOP": -1,
+		},
+	}
+	hkm := NewManager().
+		SetComponent(ComponentRedis).
+		RegisterSlowHook(config, func(hk *Hook) string {
+			return hk.Arg("command_name").(string)
+		})
+	assert.Equal(t, []string{SlowHookName}, hkm.HandlerNames())
+
+	tracer := mocktracer.New()
+	do := func(command string, duration time.Duration) *mocktracer.MockSpan {
+		span := tracer.StartSpan(command).(*mocktracer.MockSpan)
+		ctx := tracing.SetCurrentSpanToContext(context.Background(), span)
+		hkm.CreateHook(ctx).
+			AddArg("command_name", command).
+			AddArg(render.DurationArgKey, duration).
+			ProcessAfterHook()
+		return span
+	}
+	slowTotal := func(command string) float64 {
+		return testutil.ToFloat64(metric.SlowOperationsTotal.WithLabelValues(ComponentRedis, command))
+	}
+
+	t.Run("default threshold", func(t *testing.T) {
+		before := slowTotal("GET")
+		assert.Nil(t, do("GET", 50*time.Millisecond).Tag("slow"))
+		assert.Equal(t, before, slowTotal("GET"))
+
+		span := do("GET", 200*time.Millisecond)
+		assert.Equal(t, true, span.Tag("slow"))
+		assert.Equal(t, 1, len(span.Logs()))
+		assert.Equal(t, before+1, slowTotal("GET"))
+	})
+
+	t.Run("command threshold", func(t *testing.T) {
+		// 标签中的命令名统一为大写
+		before := slowTotal("KEYS")
+		assert.Equal(t, true, do("keys", 20*time.Millisecond).Tag("slow"))
+		assert.Equal(t, before+1, slowTotal("KEYS"))
+
+		// 小于0时不检测
+		before = slowTotal("BLPOP")
+		assert.Nil(t, do("BLPOP", time.Second).Tag("slow"))
+		assert.Equal(t, before, slowTotal("BLPOP"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert.Equal(t, []string{}, NewManager().RegisterSlowHook(nil, nil).HandlerNames())
+		assert.Equal(t, []string{}, NewManager().RegisterSlowHook(&SlowConfig{
+			Commands: map[string]ctime.Duration{"KEYS": -1},
+		}, nil).HandlerNames())
+	})
+}
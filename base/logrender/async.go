@@ -0,0 +1,206 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// 单个writer的待写入数据
+type pendingWriter struct {
+	// 写入writer
+	writer io.Writer
+	// 待写入数据
+	buffer *bytes.Buffer
+}
+
+// 异步写入器
+//
+//	按writer分别缓存待写入数据，单个writer的数据达到刷新大小或到达刷新间隔时写入
+//	同一writer的数据按渲染顺序写入，不同writer之间互不影响
+//	writer类型不可比较(如包含切片的结构体)时直接同步写入
+//	异步写入相对于同步写入大约减少20%CPU运行时间，内存占用大约额外提高30%
+//	具体对比数值要根据实际刷新时间来决定
+type asyncWriter struct {
+	// 配置
+	opt option
+	// 保护closed及管道关闭
+	mu sync.RWMutex
+	// 是否关闭
+	closed bool
+	// 渲染buffer管道
+	bufChan chan *renderBuffer
+	// 渲染buffer缓冲池
+	bufPool sync.Pool
+	// 协程wait group
+	wg sync.WaitGroup
+
+	// 以下字段只在守护协程中使用
+	// 待写入的writer列表，按首次写入顺序排列
+	pending []*pendingWriter
+	// 空闲的待写入数据buffer
+	freeBuffers []*bytes.Buffer
+	// 上次刷新后的第一个写入错误
+	err error
+}
+
+// 新建异步写入器，并启动守护协程
+func newAsyncWriter(opt option) *asyncWriter {
+	a := &asyncWriter{
+		opt:     opt,
+		bufChan: make(chan *renderBuffer, opt.ChanSize),
+		bufPool: sync.Pool{
+			New: func() interface{} {
+				return &renderBuffer{
+					buffer: new(bytes.Buffer),
+				}
+			},
+		},
+	}
+	a.wg.Add(1)
+	go a.daemon()
+	return a
+}
+
+// 写入数据，数据会被复制，调用后可重用
+func (a *asyncWriter) write(w io.Writer, data []byte) error {
+	a.mu.RLock()
+	if a.closed || w == nil || !reflect.TypeOf(w).Comparable() {
+		a.mu.RUnlock()
+		_, err := w.Write(data)
+		return err
+	}
+
+	buf := a.bufPool.Get().(*renderBuffer)
+	buf.buffer.Write(data)
+	buf.writer = w
+	a.bufChan <- buf
+	a.mu.RUnlock()
+	return nil
+}
+
+// 写入所有已渲染的数据，返回上次刷新后的第一个写入错误
+func (a *asyncWriter) flush(ctx context.Context) error {
+	a.mu.RLock()
+	if a.closed {
+		a.mu.RUnlock()
+		return nil
+	}
+
+	// 刷新请求与渲染数据使用同一管道，保证请求前渲染的数据均被写入
+	done := make(chan error, 1)
+	select {
+	case a.bufChan <- &renderBuffer{done: done}:
+		a.mu.RUnlock()
+	case <-ctx.Done():
+		a.mu.RUnlock()
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 关闭，写入剩下的数据
+func (a *asyncWriter) close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.bufChan)
+	a.mu.Unlock()
+
+	a.wg.Wait()
+	return a.err
+}
+
+// 守护方法
+func (a *asyncWriter) daemon() {
+	defer a.wg.Done()
+
+	var tickerChan <-chan time.Time
+	if a.opt.FlushInterval > 0 {
+		ticker := time.NewTicker(a.opt.FlushInterval)
+		defer ticker.Stop()
+		tickerChan = ticker.C
+	}
+
+	for {
+		select {
+		case buf, ok := <-a.bufChan:
+			// 关闭，写入剩下的数据
+			if !ok {
+				a.flushAll()
+				return
+			}
+			// 刷新请求
+			if buf.done != nil {
+				a.flushAll()
+				buf.done <- a.err
+				a.err = nil
+				continue
+			}
+
+			pw := a.pendingWriter(buf.writer)
+			pw.buffer.Write(buf.buffer.Bytes())
+			buf.buffer.Reset()
+			buf.writer = nil
+			a.bufPool.Put(buf)
+
+			if a.opt.FlushSize > 0 && pw.buffer.Len() >= a.opt.FlushSize {
+				a.flushWriter(pw)
+			}
+		case <-tickerChan:
+			a.flushAll()
+		}
+	}
+}
+
+// 获取writer对应的待写入数据，不存在时新建
+func (a *asyncWriter) pendingWriter(w io.Writer) *pendingWriter {
+	for _, pw := range a.pending {
+		if pw.writer == w {
+			return pw
+		}
+	}
+
+	pw := &pendingWriter{writer: w}
+	if n := len(a.freeBuffers); n > 0 {
+		pw.buffer = a.freeBuffers[n-1]
+		a.freeBuffers = a.freeBuffers[:n-1]
+	} else {
+		pw.buffer = new(bytes.Buffer)
+	}
+	a.pending = append(a.pending, pw)
+	return pw
+}
+
+// 写入单个writer的待写入数据
+func (a *asyncWriter) flushWriter(pw *pendingWriter) {
+	if pw.buffer.Len() == 0 {
+		return
+	}
+	if _, err := pw.buffer.WriteTo(pw.writer); err != nil && a.err == nil {
+		a.err = err
+	}
+	pw.buffer.Reset()
+}
+
+// 写入所有writer的待写入数据，并清空writer列表
+func (a *asyncWriter) flushAll() {
+	for i, pw := range a.pending {
+		a.flushWriter(pw)
+		a.freeBuffers = append(a.freeBuffers, pw.buffer)
+		a.pending[i] = nil
+	}
+	a.pending = a.pending[:0]
+}
@@ -0,0 +1,216 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 并发安全的记录writer
+type recordWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	// 写入次数
+	writes int
+	// 写入错误
+	err error
+}
+
+func (w *recordWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.writes++
+	return w.buf.Write(p)
+}
+
+func (w *recordWriter) lines() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := strings.TrimSuffix(w.buf.String(), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+var asyncPatternMap = map[string]PatternFunc{
+	"w": PatternArg("writer"),
+	"g": PatternArg("goroutine"),
+	"s": PatternArg("seq"),
+}
+
+func TestPatternRender_Async(t *testing.T) {
+	t.Run("destination and order", func(t *testing.T) {
+		for _, format := range []string{FormatPattern, FormatLogfmt} {
+			r := NewRender(format, asyncPatternMap, "%w %g %s", FlushInterval(time.Hour), FlushSize(256))
+			writers := []*recordWriter{{}, {}, {}}
+
+			const goroutines, count = 8, 200
+			var wg sync.WaitGroup
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < count; i++ {
+						idx := (g + i) % len(writers)
+						err := r.Render(writers[idx], map[string]interface{}{
+							"writer":    idx,
+							"goroutine": g,
+							"seq":       i,
+						})
+						assert.Nil(t, err)
+					}
+				}(g)
+			}
+			wg.Wait()
+			assert.Nil(t, r.Flush(context.Background()))
+
+			total := 0
+			for idx, w := range writers {
+				last := make(map[string]int)
+				for _, line := range w.lines() {
+					fields := strings.Fields(strings.NewReplacer("writer=", "", "goroutine=", "", "seq=", "").
+						Replace(line))
+					if !assert.Len(t, fields, 3, format) {
+						continue
+					}
+					// 写入正确的writer
+					assert.Equal(t, strconv.Itoa(idx), fields[0], format)
+					// 同一协程的日志按顺序写入
+					seq, err := strconv.Atoi(fields[2])
+					assert.Nil(t, err)
+					if prev, ok := last[fields[1]]; ok {
+						assert.True(t, seq > prev, format)
+					}
+					last[fields[1]] = seq
+					total++
+				}
+			}
+			assert.Equal(t, goroutines*count, total, format)
+			assert.Nil(t, r.Close())
+		}
+	})
+
+	t.Run("flush size", func(t *testing.T) {
+		r := NewPatternRender(asyncPatternMap, "%s", FlushInterval(time.Hour), FlushSize(10))
+		w := &recordWriter{}
+		for i := 0; i < 5; i++ {
+			assert.Nil(t, r.Render(w, map[string]interface{}{"seq": i}))
+		}
+		// 达到刷新大小后自动写入，无需等待刷新间隔
+		assert.Eventually(t, func() bool {
+			return len(w.lines()) >= 4
+		}, time.Second, time.Millisecond)
+		assert.Nil(t, r.Close())
+		assert.Equal(t, []string{"0", "1", "2", "3", "4"}, w.lines())
+	})
+
+	t.Run("flush size only", func(t *testing.T) {
+		// 只设置刷新大小时同样开启异步写入
+		r := NewPatternRender(asyncPatternMap, "%s", FlushSize(4))
+		w := &recordWriter{}
+		assert.Nil(t, r.Render(w, map[string]interface{}{"seq": 1}))
+		assert.Nil(t, w.lines())
+		assert.Nil(t, r.Render(w, map[string]interface{}{"seq": 2}))
+		assert.Eventually(t, func() bool {
+			return len(w.lines()) == 2
+		}, time.Second, time.Millisecond)
+		assert.Nil(t, r.Close())
+
+		// 刷新大小不大于0且未设置刷新间隔时同步写入
+		r = NewPatternRender(asyncPatternMap, "%s", FlushSize(0))
+		assert.Nil(t, r.Render(w, map[string]interface{}{"seq": 3}))
+		assert.Equal(t, []string{"1", "2", "3"}, w.lines())
+		assert.Nil(t, r.Close())
+	})
+
+	t.Run("flush interval", func(t *testing.T) {
+		r := NewPatternRender(asyncPatternMap, "%s", FlushInterval(10*time.Millisecond), FlushSize(0))
+		w := &recordWriter{}
+		for i := 0; i < 3; i++ {
+			assert.Nil(t, r.Render(w, map[string]interface{}{"seq": i}))
+		}
+		assert.Eventually(t, func() bool {
+			return len(w.lines()) == 3
+		}, time.Second, time.Millisecond)
+		// 同一刷新周期内的数据合并写入
+		w.mu.Lock()
+		assert.True(t, w.writes < 3)
+		w.mu.Unlock()
+		assert.Nil(t, r.Close())
+	})
+
+	t.Run("flush error", func(t *testing.T) {
+		r := NewPatternRender(asyncPatternMap, "%s", FlushInterval(time.Hour))
+		w := &recordWriter{err: errors.New("write failed")}
+		assert.Nil(t, r.Render(w, map[string]interface{}{"seq": 1}))
+		assert.EqualError(t, r.Flush(context.Background()), "write failed")
+		// 错误只返回一次
+		assert.Nil(t, r.Flush(context.Background()))
+		assert.Nil(t, r.Close())
+	})
+
+	t.Run("flush canceled", func(t *testing.T) {
+		r := NewPatternRender(asyncPatternMap, "%s", FlushInterval(time.Hour))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := r.Flush(ctx)
+		if err != nil {
+			assert.Equal(t, context.Canceled, err)
+		}
+		assert.Nil(t, r.Close())
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		r := NewPatternRender(asyncPatternMap, "%s", FlushInterval(time.Hour))
+		w := &recordWriter{}
+		assert.Nil(t, r.Render(w, map[string]interface{}{"seq": 1}))
+		assert.Nil(t, r.Close())
+		assert.Equal(t, []string{"1"}, w.lines())
+
+		// 关闭后同步写入
+		assert.Nil(t, r.Render(w, map[string]interface{}{"seq": 2}))
+		assert.Equal(t, []string{"1", "2"}, w.lines())
+		assert.Nil(t, r.Flush(context.Background()))
+		assert.Nil(t, r.Close())
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		r := NewPatternRender(asyncPatternMap, "%s")
+		w := &recordWriter{}
+		assert.Nil(t, r.Render(w, map[string]interface{}{"seq": 1}))
+		assert.Equal(t, []string{"1"}, w.lines())
+		assert.Nil(t, r.Flush(context.Background()))
+		assert.Nil(t, r.Close())
+	})
+}
+
+func ExampleFlushInterval() {
+	buf := new(bytes.Buffer)
+	r := NewPatternRender(asyncPatternMap, "seq:%s", FlushInterval(time.Second))
+	for i := 0; i < 3; i++ {
+		_ = r.Render(buf, map[string]interface{}{"seq": i})
+	}
+	// 异步写入时需调用Flush或Close后才能确保数据已写入
+	if err := r.Flush(context.Background()); err != nil {
+		return
+	}
+	fmt.Print(buf.String())
+	_ = r.Close()
+
+	// Output:
+	// seq:0
+	// seq:1
+	// seq:2
+}
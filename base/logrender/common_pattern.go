@@ -16,6 +16,8 @@ const (
 	EndTimeArgKey   = "end_time"
 	DurationArgKey  = "duration"
 	ErrorArgKey     = "error"
+	// 上下文日志字段，值为map[string]interface{}，渲染时自动输出，无需格式化字符
+	ContextFieldsArgKey = "context_fields"
 )
 
 // web url
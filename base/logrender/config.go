@@ -1,6 +1,14 @@
 package render
 
+import (
+	"time"
+
+	"gitlab.shanhai.int/sre/library/base/ctime"
+)
+
 type Config struct {
+	// 渲染格式，可选pattern(默认)、logfmt、otel
+	Format string `yaml:"format"`
 	// 控制台日志是否输出
 	Stdout bool `yaml:"stdout"`
 	// 控制台日志渲染模版
@@ -17,4 +25,20 @@ type Config struct {
 	MaxLogFile int `yaml:"maxLogFile"`
 	// 日志文件切割大小
 	RotateSize int64 `yaml:"rotateSize"`
+	// 异步写入的定时刷新间隔，与FlushSize均为空时同步写入
+	FlushInterval ctime.Duration `yaml:"flushInterval"`
+	// 异步写入时单个writer待写入数据的刷新大小，默认32k，不为空时同样开启异步写入
+	FlushSize int `yaml:"flushSize"`
+}
+
+// 渲染配置
+func (c *Config) Options() []OptionFunc {
+	var options []OptionFunc
+	if c.FlushInterval > 0 {
+		options = append(options, FlushInterval(time.Duration(c.FlushInterval)))
+	}
+	if c.FlushSize > 0 {
+		options = append(options, FlushSize(c.FlushSize))
+	}
+	return options
 }
@@ -0,0 +1,69 @@
+package render
+
+import (
+	"time"
+)
+
+// 默认配置
+var defaultOption = option{
+	// 默认32k
+	FlushSize: 32 * 1024,
+	// 默认1024
+	ChanSize: 1024,
+}
+
+// 配置
+type option struct {
+	// 异步写入的定时刷新间隔，0为同步写入
+	FlushInterval time.Duration
+	// 单个writer待写入数据的刷新大小
+	FlushSize int
+	// 是否设置了FlushSize
+	flushSizeSet bool
+	// 异步写入缓冲区管道大小
+	ChanSize int
+}
+
+// 是否开启异步写入，FlushInterval或设置的FlushSize大于0时开启
+func (o option) async() bool {
+	return o.FlushInterval > 0 || (o.flushSizeSet && o.FlushSize > 0)
+}
+
+// 配置的应用函数
+type OptionFunc func(opt *option)
+
+// 异步写入的定时刷新间隔，大于0时开启异步写入
+// 刷新间隔应在1s以上，否则会因为频繁写入，更加影响打印时间
+func FlushInterval(d time.Duration) OptionFunc {
+	return func(opt *option) {
+		opt.FlushInterval = d
+	}
+}
+
+// 单个writer待写入数据达到该大小时立即写入，大于0时开启异步写入
+// 小于等于0时只定时写入，只设置FlushSize时仅在达到该大小、Flush及Close时写入
+func FlushSize(n int) OptionFunc {
+	return func(opt *option) {
+		opt.FlushSize = n
+		opt.flushSizeSet = true
+	}
+}
+
+// 异步写入缓冲区管道大小，应根据服务请求量配置，以防止阻塞
+func ChanSize(n int) OptionFunc {
+	return func(opt *option) {
+		opt.ChanSize = n
+	}
+}
+
+// 应用配置
+func applyOptions(options []OptionFunc) option {
+	opt := defaultOption
+	for _, f := range options {
+		f(&opt)
+	}
+	if opt.ChanSize <= 0 {
+		opt.ChanSize = defaultOption.ChanSize
+	}
+	return opt
+}
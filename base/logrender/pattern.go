@@ -2,10 +2,10 @@ package render
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
@@ -154,72 +154,10 @@ type patternRender struct {
 	funcs []func(args PatternArgs, buf *bytes.Buffer) (isRender bool, key string, value interface{})
 	// 渲染buffer缓冲池
 	renderBufPool sync.Pool
-	// 协程wait group
-	wg sync.WaitGroup
-	// 渲染buffer管道
-	renderBufChan chan *renderBuffer
-	// 是否关闭
-	closed int32
-	// 定时异步写入时间
-	flushTime time.Duration
-}
-
-// 定时异步写入守护方法
-// 	异步写入相对于同步写入大约减少20%CPU运行时间，内存占用大约额外提高30%
-// 	具体对比数值要根据实际刷新时间来决定
-//	目前暂不建议开启异步写入，cpu提升不明显，且占用更高内存
-//	绝大部分日志库也不带异步写入功能，所以开启后，也较难注入进其他依赖库中
-func (p *patternRender) daemon() {
-	// 待写入buffer区
-	sumBuf := renderBuffer{
-		buffer: new(bytes.Buffer),
-	}
-	// 写入定时器
-	writeTicker := time.NewTicker(p.flushTime)
-	var err error
-	for {
-		select {
-		// 从缓冲区读取数据塞入待写入区中，并重用buf
-		case buf, ok := <-p.renderBufChan:
-			if ok {
-				sumBuf.writer = buf.writer
-				sumBuf.buffer.Write(buf.buffer.Bytes())
-
-				buf.buffer.Reset()
-				buf.writer = nil
-				p.renderBufPool.Put(buf)
-			}
-		// 从待写入区读取并写入，并重置待写入区
-		case <-writeTicker.C:
-			if sumBuf.buffer.Len() > 0 {
-				if _, err = sumBuf.buffer.WriteTo(sumBuf.writer); err != nil {
-					fmt.Printf("%#v\n", err)
-				}
-
-				sumBuf.buffer.Reset()
-				sumBuf.writer = nil
-			}
-		}
-		// 检查是否关闭
-		if atomic.LoadInt32(&p.closed) != 1 {
-			continue
-		}
-		// 关闭，写入剩下的数据
-		if _, err = sumBuf.buffer.WriteTo(sumBuf.writer); err != nil {
-			fmt.Printf("%#v\n", err)
-		}
-		for buf := range p.renderBufChan {
-			if _, err = buf.buffer.WriteTo(buf.writer); err != nil {
-				fmt.Printf("%#v\n", err)
-			}
-
-			buf.buffer.Reset()
-			buf.writer = nil
-			p.renderBufPool.Put(buf)
-		}
-		break
-	}
-	p.wg.Done()
+	// 异步写入器，为空时同步写入
+	//	目前暂不建议开启异步写入，cpu提升不明显，且占用更高内存
+	//	绝大部分日志库也不带异步写入功能，所以开启后，也较难注入进其他依赖库中
+	async *asyncWriter
 }
 
 // 渲染写入writer中
@@ -233,21 +171,19 @@ func (p *patternRender) Render(w io.Writer, d map[string]interface{}) error {
 		}
 	}
 	buf.buffer.WriteString("\n")
-	buf.writer = w
 
 	var err error
-
-	if p.flushTime > 0 {
-		// 定时异步写入
-		p.renderBufChan <- buf
+	if p.async != nil {
+		// 异步写入
+		err = p.async.write(w, buf.buffer.Bytes())
 	} else {
 		// 立即写入
-		_, err = buf.buffer.WriteTo(w)
-
-		buf.buffer.Reset()
-		p.renderBufPool.Put(buf)
+		_, err = w.Write(buf.buffer.Bytes())
 	}
 
+	buf.buffer.Reset()
+	p.renderBufPool.Put(buf)
+
 	return err
 }
 
@@ -266,10 +202,18 @@ func (p *patternRender) RenderString(d map[string]interface{}) string {
 	return buf.buffer.String()
 }
 
+// 写入所有已渲染的数据，异步写入时返回上次刷新后的第一个写入错误
+func (p *patternRender) Flush(ctx context.Context) error {
+	if p.async == nil {
+		return nil
+	}
+	return p.async.flush(ctx)
+}
+
 // 关闭渲染器，异步定时写入时必须
 func (p *patternRender) Close() error {
-	atomic.StoreInt32(&p.closed, 1)
-	close(p.renderBufChan)
-	p.wg.Wait()
-	return nil
+	if p.async == nil {
+		return nil
+	}
+	return p.async.close()
 }
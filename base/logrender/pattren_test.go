@@ -2,11 +2,175 @@ package render
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
+func TestPatternRender_ContextFields(t *testing.T) {
+	tm, err := time.Parse("2006/01/02 15:04:05", "2019/01/02 11:36:28")
+	assert.Nil(t, err)
+	args := map[string]interface{}{
+		"time": tm,
+		ContextFieldsArgKey: map[string]interface{}{
+			"tenant": "t1",
+			"title":  "ignored",
+		},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		r := NewPatternRender(patternMap, "%J{Tt}")
+		m := make(map[string]interface{})
+		err := json.Unmarshal([]byte(r.RenderString(args)), &m)
+		assert.Nil(t, err)
+		assert.Equal(t, "t1", m["tenant"])
+		// 不覆盖渲染函数的结果
+		assert.Equal(t, "RENDER", m["title"])
+	})
+
+	t.Run("text", func(t *testing.T) {
+		r := NewPatternRender(patternMap, "%t %J{T}")
+		assert.Equal(t, `RENDER {"time":"2019/01/02 11:36:28.000"} tenant=t1 title=ignored`+"\n", r.RenderString(args))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		r := NewPatternRender(patternMap, "%t")
+		assert.Equal(t, "RENDER\n", r.RenderString(map[string]interface{}{}))
+	})
+}
+
+func TestNewRender(t *testing.T) {
+	tm := time.Date(2019, 1, 2, 11, 36, 28, 0, time.UTC)
+	funcs := map[string]PatternFunc{
+		"t": title,
+		"L": func(args PatternArgs) PatternResult {
+			return NewPatternResult("level", args["level"])
+		},
+		"m": func(args PatternArgs) PatternResult {
+			return NewPatternResult("message", map[string]interface{}{
+				"log":  args["log"],
+				"user": "u 1",
+				"req":  map[string]interface{}{"id": 1},
+			})
+		},
+		"E": PatternError,
+	}
+	args := map[string]interface{}{
+		"time":  tm,
+		"level": "WARN",
+		"log":   "hello",
+		"error": errors.New("bad"),
+		ContextFieldsArgKey: map[string]interface{}{
+			"trace_id": "abc",
+			"title":    "ignored",
+		},
+	}
+
+	t.Run("pattern", func(t *testing.T) {
+		r := NewRender("", funcs, "%t")
+		assert.Equal(t, "RENDER title=ignored trace_id=abc\n", r.RenderString(args))
+	})
+
+	t.Run("logfmt", func(t *testing.T) {
+		r := NewRender(FormatLogfmt, funcs, "[%t] %J{LmE} %t")
+		assert.Equal(t, `title=RENDER level=WARN log=hello req.id=1 user="u 1" error=bad trace_id=abc`+"\n",
+			r.RenderString(args))
+	})
+
+	t.Run("otel", func(t *testing.T) {
+		r := NewRender(FormatOTel, funcs, "%J{tLmE}")
+		m := make(map[string]interface{})
+		err := json.Unmarshal([]byte(r.RenderString(args)), &m)
+		assert.Nil(t, err)
+		assert.Equal(t, "2019-01-02T11:36:28Z", m["timestamp"])
+		assert.Equal(t, "WARN", m["severity_text"])
+		assert.Equal(t, float64(13), m["severity_number"])
+		assert.Equal(t, "hello", m["body"])
+		assert.Equal(t, "abc", m["trace_id"])
+		assert.Equal(t, map[string]interface{}{
+			"title": "RENDER",
+			"user":  "u 1",
+			"req":   map[string]interface{}{"id": float64(1)},
+			"error": "bad",
+		}, m["attributes"])
+	})
+
+	t.Run("otel without message", func(t *testing.T) {
+		r := NewRender(FormatOTel, funcs, "%t%E")
+		m := make(map[string]interface{})
+		err := json.Unmarshal([]byte(r.RenderString(map[string]interface{}{
+			"error":       errors.New("bad"),
+			EndTimeArgKey: tm,
+		})), &m)
+		assert.Nil(t, err)
+		assert.Equal(t, "2019-01-02T11:36:28Z", m["timestamp"])
+		assert.Equal(t, "ERROR", m["severity_text"])
+		assert.Equal(t, float64(17), m["severity_number"])
+		assert.Equal(t, "RENDER", m["body"])
+		assert.Equal(t, map[string]interface{}{"error": "bad"}, m["attributes"])
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewRender("xml", funcs, "%t")
+		})
+	})
+}
+
+func TestPatternRender_Template(t *testing.T) {
+	tm := time.Date(2019, 1, 2, 11, 36, 28, 0, time.UTC)
+	funcs := map[string]PatternFunc{
+		"t": title,
+		"L": func(args PatternArgs) PatternResult {
+			return NewPatternResult("level", args["level"])
+		},
+	}
+	args := map[string]interface{}{
+		"time":          tm,
+		"level":         "INFO",
+		"user":          "u1",
+		DurationArgKey:  1500 * time.Millisecond,
+		StartTimeArgKey: tm,
+	}
+
+	cases := []struct {
+		name    string
+		pattern string
+		expect  string
+	}{
+		{"single letter", "[%t] %L 100%", "[RENDER] INFO 100%"},
+		{"unknown verb", "%x %", "%x %"},
+		{"time layout", "%{time:RFC3339}", "2019-01-02T11:36:28Z"},
+		{"time default", "%{start_time}", "2019/01/02 11:36:28.000"},
+		{"time unix", "%{time:unix}", "1546428988"},
+		{"duration unit", "%{duration:ms}", "1500"},
+		{"duration string", "%{duration:string}", "1.5s"},
+		{"args fallback", "user=%{user}", "user=u1"},
+		{"sprintf param", "%{user:%q}", `"u1"`},
+		{"left align", "[%-6L]", "[INFO  ]"},
+		{"right align", "[%6{level}]", "[  INFO]"},
+		{"truncate", "[%.3t]", "[REN]"},
+		{"conditional", "%L%?{ user=%{user}}", "INFO user=u1"},
+		{"conditional skip", "%L%?{ error=%{error}}", "INFO"},
+		{"nested conditional", "%?{%t%?{ %{missing}}}", "RENDER"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := NewPatternRender(funcs, c.pattern)
+			assert.Equal(t, c.expect+"\n", r.RenderString(args))
+		})
+	}
+
+	t.Run("structured", func(t *testing.T) {
+		r := NewRender(FormatLogfmt, funcs, "%L %{user} %{duration:ms}")
+		assert.Equal(t, "level=INFO user=u1 duration=1.5s\n", r.RenderString(args))
+	})
+}
+
 func BenchmarkPattern_Render(b *testing.B) {
 	t, err := time.Parse("2006/01/02 15:04:05", "2019/01/02 11:36:28")
 	if err != nil {
@@ -2,10 +2,12 @@ package render
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"sort"
 	"sync"
-	"time"
 )
 
 // 渲染接口
@@ -14,6 +16,8 @@ type Render interface {
 	Render(io.Writer, map[string]interface{}) error
 	// 获取渲染字符串
 	RenderString(map[string]interface{}) string
+	// 写入所有已渲染的数据，同步写入时直接返回
+	Flush(ctx context.Context) error
 	// 关闭渲染器，异步定时写入时必须
 	Close() error
 }
@@ -24,12 +28,15 @@ type renderBuffer struct {
 	buffer *bytes.Buffer
 	// 写入writer
 	writer io.Writer
+	// 刷新请求的结果管道
+	done chan error
 }
 
 // 新建渲染器
 // 	patternMap为用于渲染的函数字典，key为用于渲染的格式化字符，值为渲染函数
 //	format为渲染的模版
-func NewPatternRender(patternMap map[string]PatternFunc, format string) Render {
+//	options为渲染配置，设置FlushInterval或FlushSize时开启异步写入
+func NewPatternRender(patternMap map[string]PatternFunc, format string, options ...OptionFunc) Render {
 	// J为保留格式化字符，不可使用
 	if _, ok := patternMap["J"]; ok {
 		panic("pattern map shouldn't use 'J'")
@@ -43,62 +50,24 @@ func NewPatternRender(patternMap map[string]PatternFunc, format string) Render {
 				}
 			},
 		},
-		// 缓冲区管道大小应根据服务请求量配置，以防止阻塞
-		renderBufChan: make(chan *renderBuffer, 1024),
-		// 如开启异步写入，刷新时间应在1s以上，否则会因为频繁写入，更加影响打印时间
-		flushTime: 0 * time.Millisecond,
 	}
 
-	b := make([]byte, 0, len(format))
-	for i := 0; i < len(format); i++ {
-		if format[i] != '%' {
-			b = append(b, format[i])
-			continue
-		}
-		if i+1 >= len(format) {
-			b = append(b, format[i])
-			continue
-		}
-
-		var curFunc func(PatternArgs, *bytes.Buffer) (bool, string, interface{})
-		// 渲染json
-		if format[i+1] == 'J' && i+2 < len(format) && format[i+2] == '{' {
-			jsonFuncArray := make([]PatternFunc, 0)
-			for i = i + 2; i+1 < len(format) && format[i+1] != '}'; i++ {
-				curFormat := string(format[i+1])
-				f, ok := patternMap[curFormat]
-				if !ok {
-					continue
-				}
-				jsonFuncArray = append(jsonFuncArray, f)
-			}
-			curFunc = jsonFormatFactory(jsonFuncArray)
-		} else { // 普通渲染
-			f, ok := patternMap[string(format[i+1])]
-			if !ok {
-				b = append(b, format[i])
-				continue
-			}
-			curFunc = convertFunc(f)
-		}
-		// 非格式化字符，使用普通文本渲染
-		if len(b) != 0 {
-			p.funcs = append(p.funcs, textFactory(string(b)))
-			b = b[:0]
-		}
-
-		p.funcs = append(p.funcs, curFunc)
-		i++
+	// 编译渲染计划，jsonFuncArray为最后一个json渲染的函数列表
+	steps, jsonFuncArray := compilePattern(patternMap, format)
+	for _, step := range steps {
+		p.funcs = append(p.funcs, step.fn)
 	}
 
-	if len(b) != 0 {
-		p.funcs = append(p.funcs, textFactory(string(b)))
+	// 上下文日志字段，模版仅为单个json渲染时合并至json中，否则以key=value形式追加至行尾
+	if len(p.funcs) == 1 && jsonFuncArray != nil {
+		p.funcs[0] = jsonFormatFactory(jsonFuncArray, true)
+	} else if len(p.funcs) != 0 {
+		p.funcs = append(p.funcs, contextFieldsText)
 	}
 
-	// 若为定时异步写入，启动守护协程
-	if p.flushTime > 0 {
-		p.wg.Add(1)
-		go p.daemon()
+	// 若为定时异步写入，启动异步写入器
+	if opt := applyOptions(options); opt.async() {
+		p.async = newAsyncWriter(opt)
 	}
 
 	return p
@@ -136,8 +105,31 @@ var jsonFormatBufferPool = sync.Pool{
 	},
 }
 
+// 以key=value形式渲染上下文日志字段
+func contextFieldsText(args PatternArgs, buf *bytes.Buffer) (bool, string, interface{}) {
+	fields, ok := args[ContextFieldsArgKey].(map[string]interface{})
+	if !ok || len(fields) == 0 {
+		return true, "", nil
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf.WriteString(" ")
+		buf.WriteString(k)
+		buf.WriteString("=")
+		buf.WriteString(fmt.Sprint(fields[k]))
+	}
+	return true, "", nil
+}
+
 // json渲染
-func jsonFormatFactory(funArray []PatternFunc) func(PatternArgs, *bytes.Buffer) (bool, string, interface{}) {
+//
+//	withContext为true时，合并上下文日志字段，不覆盖渲染函数的结果
+func jsonFormatFactory(funArray []PatternFunc, withContext bool) func(PatternArgs, *bytes.Buffer) (bool, string, interface{}) {
 	return func(args PatternArgs, buf *bytes.Buffer) (bool, string, interface{}) {
 		jsonMap := jsonFormatMapPool.Get().(map[string]interface{})
 		for k := range jsonMap {
@@ -153,6 +145,14 @@ func jsonFormatFactory(funArray []PatternFunc) func(PatternArgs, *bytes.Buffer)
 				jsonMap[res.Key] = res.Value
 			}
 		}
+		if withContext {
+			fields, _ := args[ContextFieldsArgKey].(map[string]interface{})
+			for k, v := range fields {
+				if _, ok := jsonMap[k]; !ok {
+					jsonMap[k] = v
+				}
+			}
+		}
 		err := json.NewEncoder(jsonBuf).Encode(jsonMap)
 		if err != nil {
 			return false, "json", jsonMap
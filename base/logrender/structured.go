@@ -0,0 +1,457 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 渲染格式
+const (
+	// 模版渲染，支持普通文本及%J{}json渲染
+	FormatPattern = "pattern"
+	// logfmt渲染，key=value形式
+	FormatLogfmt = "logfmt"
+	// OpenTelemetry日志数据模型json渲染
+	FormatOTel = "otel"
+)
+
+// 结构化渲染中的特殊参数及结果键
+const (
+	// 消息结果键，值为字典时合并至顶层
+	messageResultKey = "message"
+	// 日志消息键
+	logArgKey = "log"
+	// 日志级别键
+	levelArgKey = "level"
+	// 日志时间键
+	timeArgKey = "time"
+	// 标题结果键
+	titleResultKey = "title"
+	// 应用ID参数键
+	appIDArgKey = "app_id"
+	// 链路追踪ID键
+	traceIDKey = "trace_id"
+	// span ID键
+	spanIDKey = "span_id"
+)
+
+// OpenTelemetry日志级别对应的SeverityNumber
+var otelSeverityNumbers = map[string]int{
+	"TRACE": 1,
+	"DEBUG": 5,
+	"INFO":  9,
+	"WARN":  13,
+	"ERROR": 17,
+	"FATAL": 21,
+}
+
+// 按渲染格式新建渲染器
+//
+//	format为空或pattern时与NewPatternRender一致
+//	logfmt及otel格式使用模版中出现的格式化字符(包括%J{}中的及%{name}命名格式化字符)对应的渲染函数
+//	忽略模版中的普通文本、宽度及格式化参数
+//	options为渲染配置，设置FlushInterval或FlushSize时开启异步写入
+func NewRender(format string, patternMap map[string]PatternFunc, pattern string, options ...OptionFunc) Render {
+	switch strings.ToLower(format) {
+	case "", FormatPattern:
+		return NewPatternRender(patternMap, pattern, options...)
+	case FormatLogfmt:
+		return newStructuredRender(patternMap, pattern, encodeLogfmt, options)
+	case FormatOTel:
+		return newStructuredRender(patternMap, pattern, encodeOTel, options)
+	}
+	panic(fmt.Sprintf("invalid render format: %s", format))
+}
+
+// 渲染结果
+type structuredField struct {
+	key   string
+	value interface{}
+}
+
+// 结构化渲染器
+type structuredRender struct {
+	// 渲染函数
+	funcs []PatternFunc
+	// 编码方法
+	encode func(buf *bytes.Buffer, args PatternArgs, fields []structuredField)
+	// buffer对象池
+	pool sync.Pool
+	// 异步写入器，为空时同步写入
+	async *asyncWriter
+}
+
+// 新建结构化渲染器
+func newStructuredRender(patternMap map[string]PatternFunc, pattern string,
+	encode func(buf *bytes.Buffer, args PatternArgs, fields []structuredField), options []OptionFunc) *structuredRender {
+	r := &structuredRender{
+		encode: encode,
+		pool: sync.Pool{
+			New: func() interface{} {
+				return new(bytes.Buffer)
+			},
+		},
+	}
+
+	if opt := applyOptions(options); opt.async() {
+		r.async = newAsyncWriter(opt)
+	}
+
+	used := make(map[byte]struct{})
+	usedNames := make(map[string]struct{})
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 >= len(pattern) {
+			continue
+		}
+		// json渲染中的格式化字符
+		if pattern[i+1] == 'J' && i+2 < len(pattern) && pattern[i+2] == '{' {
+			for i = i + 3; i < len(pattern) && pattern[i] != '}'; i++ {
+				r.addFunc(patternMap, pattern[i], used)
+			}
+			continue
+		}
+		// 命名格式化字符，忽略格式化参数
+		if pattern[i+1] == '{' {
+			end := strings.IndexByte(pattern[i:], '}')
+			if end < 0 {
+				continue
+			}
+			name := pattern[i+2 : i+end]
+			if idx := strings.IndexByte(name, ':'); idx >= 0 {
+				name = name[:idx]
+			}
+			if _, ok := usedNames[name]; !ok {
+				usedNames[name] = struct{}{}
+				f, _ := resolveNamedPattern(patternMap, name, "")
+				r.funcs = append(r.funcs, f)
+			}
+			i += end
+			continue
+		}
+		i++
+		r.addFunc(patternMap, pattern[i], used)
+	}
+	return r
+}
+
+// 增加格式化字符对应的渲染函数，重复的只增加一次
+func (r *structuredRender) addFunc(patternMap map[string]PatternFunc, c byte, used map[byte]struct{}) {
+	if _, ok := used[c]; ok {
+		return
+	}
+	f, ok := patternMap[string(c)]
+	if !ok {
+		return
+	}
+	used[c] = struct{}{}
+	r.funcs = append(r.funcs, f)
+}
+
+// 执行渲染函数，消息结果为字典时合并至顶层
+func (r *structuredRender) fields(args PatternArgs) []structuredField {
+	fields := make([]structuredField, 0, len(r.funcs)+8)
+	for _, f := range r.funcs {
+		res := f(args)
+		if res.Key == "" || res.IsSkip() {
+			continue
+		}
+		if res.Key == messageResultKey {
+			if m, ok := normalizeValue(res.Value).(map[string]interface{}); ok {
+				fields = appendSortedMap(fields, "", m)
+				continue
+			}
+		}
+		fields = append(fields, structuredField{key: res.Key, value: res.Value})
+	}
+
+	// 上下文日志字段，不覆盖渲染函数的结果
+	if contextFields, ok := args[ContextFieldsArgKey].(map[string]interface{}); ok && len(contextFields) != 0 {
+		exists := make(map[string]struct{}, len(fields))
+		for _, f := range fields {
+			exists[f.key] = struct{}{}
+		}
+		keys := sortedKeys(contextFields)
+		for _, k := range keys {
+			if _, ok := exists[k]; !ok {
+				fields = append(fields, structuredField{key: k, value: contextFields[k]})
+			}
+		}
+	}
+	return fields
+}
+
+func (r *structuredRender) Render(w io.Writer, d map[string]interface{}) error {
+	buf := r.pool.Get().(*bytes.Buffer)
+	buf.Reset()
+	r.encode(buf, d, r.fields(d))
+	buf.WriteByte('\n')
+
+	var err error
+	if r.async != nil {
+		err = r.async.write(w, buf.Bytes())
+	} else {
+		_, err = w.Write(buf.Bytes())
+	}
+	r.pool.Put(buf)
+	return err
+}
+
+func (r *structuredRender) RenderString(d map[string]interface{}) string {
+	buf := new(bytes.Buffer)
+	r.encode(buf, d, r.fields(d))
+	buf.WriteByte('\n')
+	return buf.String()
+}
+
+func (r *structuredRender) Flush(ctx context.Context) error {
+	if r.async == nil {
+		return nil
+	}
+	return r.async.flush(ctx)
+}
+
+func (r *structuredRender) Close() error {
+	if r.async == nil {
+		return nil
+	}
+	return r.async.close()
+}
+
+// 统一值类型，实现json序列化接口的值(如log包的类型字段消息)转换为字典等基础类型
+func normalizeValue(v interface{}) interface{} {
+	switch v.(type) {
+	case map[string]interface{}, string, error, fmt.Stringer, time.Time, nil:
+		return v
+	case json.Marshaler:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return v
+		}
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.UseNumber()
+		var nv interface{}
+		if err := decoder.Decode(&nv); err != nil {
+			return v
+		}
+		return nv
+	}
+	return v
+}
+
+// 按键名顺序追加字典，嵌套字典使用.连接键名
+func appendSortedMap(fields []structuredField, prefix string, m map[string]interface{}) []structuredField {
+	for _, k := range sortedKeys(m) {
+		fields = append(fields, structuredField{key: prefix + k, value: m[k]})
+	}
+	return fields
+}
+
+// 排序后的键名
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// logfmt编码，嵌套字典展开为a.b=c形式
+func encodeLogfmt(buf *bytes.Buffer, args PatternArgs, fields []structuredField) {
+	for _, f := range fields {
+		appendLogfmtField(buf, f.key, f.value)
+	}
+}
+
+// 追加logfmt键值对
+func appendLogfmtField(buf *bytes.Buffer, key string, value interface{}) {
+	if m, ok := normalizeValue(value).(map[string]interface{}); ok {
+		for _, k := range sortedKeys(m) {
+			appendLogfmtField(buf, key+"."+k, m[k])
+		}
+		return
+	}
+
+	if buf.Len() != 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(logfmtKey(key))
+	buf.WriteByte('=')
+
+	var s string
+	switch v := value.(type) {
+	case nil:
+		return
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	case time.Time:
+		s = v.Format(time.RFC3339Nano)
+	case error:
+		s = v.Error()
+	case fmt.Stringer:
+		s = v.String()
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		s = fmt.Sprint(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			s = fmt.Sprint(v)
+		} else {
+			s = string(b)
+		}
+	}
+	buf.WriteString(logfmtValue(s))
+}
+
+// logfmt键名，替换空格、=及引号
+func logfmtKey(k string) string {
+	if k == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' {
+			return '_'
+		}
+		return r
+	}, k)
+}
+
+// logfmt值，包含空格、=、引号或控制字符时加引号
+func logfmtValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// OpenTelemetry日志记录
+type otelRecord struct {
+	// 日志时间
+	Timestamp string `json:"timestamp"`
+	// 采集时间
+	ObservedTimestamp string `json:"observed_timestamp"`
+	// 日志级别名
+	SeverityText string `json:"severity_text"`
+	// 日志级别值
+	SeverityNumber int `json:"severity_number"`
+	// 日志主体
+	Body interface{} `json:"body,omitempty"`
+	// 链路追踪ID
+	TraceID string `json:"trace_id,omitempty"`
+	// span ID
+	SpanID string `json:"span_id,omitempty"`
+	// 资源
+	Resource map[string]interface{} `json:"resource,omitempty"`
+	// 属性
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// OpenTelemetry日志数据模型编码
+//
+//	timestamp：参数中的time、end_time或start_time，均不存在时为当前时间
+//	severity：参数或渲染结果中的level，不存在时有error参数为ERROR，否则为INFO
+//	body：参数中的log，不存在时为渲染结果中的title
+//	trace_id/span_id：渲染结果或上下文日志字段中的同名字段
+//	resource：参数中的app_id作为service.name
+//	attributes：其余渲染结果
+func encodeOTel(buf *bytes.Buffer, args PatternArgs, fields []structuredField) {
+	now := time.Now()
+	record := otelRecord{
+		Timestamp:         otelTimestamp(args, now).Format(time.RFC3339Nano),
+		ObservedTimestamp: now.Format(time.RFC3339Nano),
+		Attributes:        make(map[string]interface{}, len(fields)),
+	}
+
+	level, _ := args[levelArgKey].(string)
+	var title interface{}
+	for _, f := range fields {
+		switch f.key {
+		case logArgKey:
+			record.Body = f.value
+			continue
+		case timeArgKey:
+			continue
+		case levelArgKey:
+			if level == "" {
+				level = fmt.Sprint(f.value)
+			}
+			continue
+		case traceIDKey:
+			record.TraceID = fmt.Sprint(f.value)
+			continue
+		case spanIDKey:
+			record.SpanID = fmt.Sprint(f.value)
+			continue
+		case titleResultKey:
+			title = f.value
+		}
+		record.Attributes[f.key] = otelValue(f.value)
+	}
+	if record.Body == nil {
+		if msg, ok := args[logArgKey]; ok {
+			record.Body = msg
+		} else if title != nil {
+			record.Body = title
+			delete(record.Attributes, titleResultKey)
+		}
+	}
+	if len(record.Attributes) == 0 {
+		record.Attributes = nil
+	}
+
+	record.SeverityText = strings.ToUpper(level)
+	if record.SeverityText == "" {
+		record.SeverityText = "INFO"
+		if args[ErrorArgKey] != nil {
+			record.SeverityText = "ERROR"
+		}
+	}
+	record.SeverityNumber = otelSeverityNumbers[record.SeverityText]
+
+	if appID, ok := args[appIDArgKey].(string); ok && appID != "" {
+		record.Resource = map[string]interface{}{"service.name": appID}
+	}
+
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(&record); err != nil {
+		fmt.Fprintf(buf, `{"body":%q}`, err.Error())
+		return
+	}
+	// 去掉encoder追加的换行符
+	buf.Truncate(buf.Len() - 1)
+}
+
+// 日志时间
+func otelTimestamp(args PatternArgs, now time.Time) time.Time {
+	for _, k := range []string{timeArgKey, EndTimeArgKey, StartTimeArgKey} {
+		if t, ok := args[k].(time.Time); ok && !t.IsZero() {
+			return t
+		}
+	}
+	return now
+}
+
+// 属性值，错误转换为字符串
+func otelValue(v interface{}) interface{} {
+	if err, ok := v.(error); ok {
+		return err.Error()
+	}
+	return v
+}
@@ -0,0 +1,357 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 默认时间格式
+const _defaultTimeLayout = "2006/01/02 15:04:05.000"
+
+// 渲染函数，isRender为true时表示已写入buffer
+type renderFunc func(args PatternArgs, buf *bytes.Buffer) (isRender bool, key string, value interface{})
+
+// 渲染计划中的单个步骤
+type planStep struct {
+	// 渲染函数
+	fn renderFunc
+	// 是否为格式化字符，条件块中所有格式化字符均有输出时才渲染
+	verb bool
+}
+
+// 命名格式化字符
+type namedPattern struct {
+	// 渲染函数
+	fn PatternFunc
+	// 默认格式化参数
+	param string
+}
+
+// 内置命名格式化字符，通过%{name}或%{name:param}使用
+var namedPatterns = map[string]namedPattern{
+	"time":       {fn: argTimeFactory("time", "time", true), param: _defaultTimeLayout},
+	"start_time": {fn: argTimeFactory("start_time", StartTimeArgKey, false), param: _defaultTimeLayout},
+	"end_time":   {fn: argTimeFactory("time", EndTimeArgKey, false), param: _defaultTimeLayout},
+	"duration":   {fn: PatternArg(DurationArgKey)},
+	"source":     {fn: PatternSource},
+	"uuid":       {fn: PatternUUID},
+	"error":      {fn: PatternError},
+	"web_url":    {fn: PatternWebUrl},
+	"web_method": {fn: PatternWebMethod},
+}
+
+// 命名时间格式
+var timeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"RFC822":      time.RFC822,
+	"RFC1123":     time.RFC1123,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"Stamp":       time.Stamp,
+	"StampMilli":  time.StampMilli,
+	"StampMicro":  time.StampMicro,
+	"DateTime":    "2006-01-02 15:04:05",
+	"DateOnly":    "2006-01-02",
+	"TimeOnly":    "15:04:05",
+}
+
+// 时长单位
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// 指定参数的模版函数，结果键为参数键，参数不存在时跳过
+func PatternArg(key string) PatternFunc {
+	return func(args PatternArgs) PatternResult {
+		v, ok := args[key]
+		if !ok {
+			return DefaultPatternResult()
+		}
+		return NewPatternResult(key, v)
+	}
+}
+
+// 固定标题的模版函数
+func PatternTitle(title string) PatternFunc {
+	return func(args PatternArgs) PatternResult {
+		return NewPatternResult("title", title)
+	}
+}
+
+// 时间参数的模版函数，值为time.Time
+func argTimeFactory(resultKey, argKey string, defaultNow bool) PatternFunc {
+	return func(args PatternArgs) PatternResult {
+		if t, ok := args[argKey].(time.Time); ok {
+			return NewPatternResult(resultKey, t)
+		}
+		if defaultNow {
+			return NewPatternResult(resultKey, time.Now())
+		}
+		return DefaultPatternResult()
+	}
+}
+
+// 编译模版为渲染计划
+//
+//	%X：单个格式化字符，X为patternMap中的键
+//	%J{XY}：json渲染
+//	%{name}、%{name:param}：命名格式化字符，依次查找patternMap、内置命名格式化字符及参数
+//	%-10X、%10.20{name}：左对齐/右对齐宽度及最大宽度
+//	%?{...}：条件块，块中所有格式化字符均有输出时才渲染
+//	返回渲染计划及最后一个json渲染的函数列表
+func compilePattern(patternMap map[string]PatternFunc, format string) ([]planStep, []PatternFunc) {
+	var (
+		steps         []planStep
+		jsonFuncArray []PatternFunc
+	)
+	b := make([]byte, 0, len(format))
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 >= len(format) {
+			b = append(b, format[i])
+			continue
+		}
+
+		var (
+			curFunc renderFunc
+			end     int
+			verb    = true
+		)
+		switch {
+		// 条件块，嵌套的条件块不影响外层块是否渲染
+		case format[i+1] == '?' && i+2 < len(format) && format[i+2] == '{':
+			if closeIdx := matchBrace(format, i+2); closeIdx > 0 {
+				inner, _ := compilePattern(patternMap, format[i+3:closeIdx])
+				curFunc = conditionalFunc(inner)
+				end = closeIdx
+				verb = false
+			}
+		// 渲染json
+		case format[i+1] == 'J' && i+2 < len(format) && format[i+2] == '{':
+			jsonFuncArray = make([]PatternFunc, 0)
+			for end = i + 2; end+1 < len(format) && format[end+1] != '}'; end++ {
+				f, ok := patternMap[string(format[end+1])]
+				if !ok {
+					continue
+				}
+				jsonFuncArray = append(jsonFuncArray, f)
+			}
+			end++
+			curFunc = jsonFormatFactory(jsonFuncArray, false)
+		default:
+			curFunc, end = compileVerb(patternMap, format, i)
+		}
+		if curFunc == nil {
+			b = append(b, format[i])
+			continue
+		}
+
+		// 非格式化字符，使用普通文本渲染
+		if len(b) != 0 {
+			steps = append(steps, planStep{fn: textFactory(string(b))})
+			b = b[:0]
+		}
+		steps = append(steps, planStep{fn: curFunc, verb: verb})
+		i = end
+	}
+
+	if len(b) != 0 {
+		steps = append(steps, planStep{fn: textFactory(string(b))})
+	}
+	return steps, jsonFuncArray
+}
+
+// 编译单个格式化字符，start为%的位置，返回渲染函数及最后一个字符的位置，无法识别时返回nil
+func compileVerb(patternMap map[string]PatternFunc, format string, start int) (renderFunc, int) {
+	i := start + 1
+
+	// 宽度
+	leftAlign := false
+	if format[i] == '-' {
+		leftAlign = true
+		i++
+	}
+	width, i := parseNumber(format, i)
+	precision := -1
+	if i < len(format) && format[i] == '.' {
+		precision, i = parseNumber(format, i+1)
+	}
+	if i >= len(format) {
+		return nil, 0
+	}
+
+	var (
+		f     PatternFunc
+		param string
+		end   int
+	)
+	if format[i] == '{' {
+		closeIdx := strings.IndexByte(format[i:], '}')
+		if closeIdx < 0 {
+			return nil, 0
+		}
+		end = i + closeIdx
+		name := format[i+1 : end]
+		if idx := strings.IndexByte(name, ':'); idx >= 0 {
+			name, param = name[:idx], name[idx+1:]
+		}
+		f, param = resolveNamedPattern(patternMap, name, param)
+	} else {
+		var ok bool
+		if f, ok = patternMap[string(format[i])]; !ok {
+			return nil, 0
+		}
+		end = i
+	}
+
+	// 与原有单字符格式化字符保持一致
+	if param == "" && width == 0 && precision < 0 {
+		return convertFunc(f), end
+	}
+	return formatFunc(f, param, width, precision, leftAlign), end
+}
+
+// 查找命名格式化字符，依次查找patternMap、内置命名格式化字符及参数
+func resolveNamedPattern(patternMap map[string]PatternFunc, name, param string) (PatternFunc, string) {
+	if f, ok := patternMap[name]; ok {
+		return f, param
+	}
+	if np, ok := namedPatterns[name]; ok {
+		if param == "" {
+			param = np.param
+		}
+		return np.fn, param
+	}
+	return PatternArg(name), param
+}
+
+// 解析数字
+func parseNumber(s string, i int) (int, int) {
+	n := 0
+	for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		n = n*10 + int(s[i]-'0')
+	}
+	return n, i
+}
+
+// 查找匹配的右大括号，open为左大括号的位置
+func matchBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// 带格式化参数及宽度的渲染
+func formatFunc(f PatternFunc, param string, width, precision int, leftAlign bool) renderFunc {
+	return func(args PatternArgs, buf *bytes.Buffer) (bool, string, interface{}) {
+		res := f(args)
+		if res.IsSkip() {
+			return true, res.Key, nil
+		}
+		s := fmt.Sprint(formatValue(res.Value, param))
+		if s == "" {
+			return true, res.Key, nil
+		}
+
+		// 最大宽度
+		if precision >= 0 && utf8.RuneCountInString(s) > precision {
+			s = string([]rune(s)[:precision])
+		}
+		// 填充宽度
+		pad := width - utf8.RuneCountInString(s)
+		if pad > 0 && !leftAlign {
+			buf.WriteString(strings.Repeat(" ", pad))
+		}
+		buf.WriteString(s)
+		if pad > 0 && leftAlign {
+			buf.WriteString(strings.Repeat(" ", pad))
+		}
+		return true, res.Key, nil
+	}
+}
+
+// 按格式化参数格式化值
+//
+//	time.Time：时间格式，支持RFC3339等命名格式、unix/unixmilli/unixnano及自定义格式
+//	time.Duration：单位，支持ns、us、ms、s、m、h及string
+//	其他：以%开头时使用fmt.Sprintf格式化
+func formatValue(v interface{}, param string) interface{} {
+	if param == "" {
+		return v
+	}
+	switch val := v.(type) {
+	case time.Time:
+		switch param {
+		case "unix":
+			return val.Unix()
+		case "unixmilli":
+			return val.UnixNano() / int64(time.Millisecond)
+		case "unixnano":
+			return val.UnixNano()
+		}
+		if layout, ok := timeLayouts[param]; ok {
+			return val.Format(layout)
+		}
+		return val.Format(param)
+	case time.Duration:
+		if param == "string" {
+			return val.String()
+		}
+		if unit, ok := durationUnits[param]; ok {
+			return strconv.FormatFloat(float64(val)/float64(unit), 'f', -1, 64)
+		}
+	}
+	if strings.HasPrefix(param, "%") {
+		return fmt.Sprintf(param, v)
+	}
+	return v
+}
+
+// 条件块buffer对象池
+var conditionalBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// 条件块渲染，块中所有格式化字符均有输出时才渲染
+func conditionalFunc(steps []planStep) renderFunc {
+	return func(args PatternArgs, buf *bytes.Buffer) (bool, string, interface{}) {
+		tmp := conditionalBufferPool.Get().(*bytes.Buffer)
+		tmp.Reset()
+		defer conditionalBufferPool.Put(tmp)
+
+		for _, step := range steps {
+			before := tmp.Len()
+			isRender, _, v := step.fn(args, tmp)
+			if !isRender {
+				tmp.WriteString(fmt.Sprint(v))
+			}
+			if step.verb && tmp.Len() == before {
+				return true, "", nil
+			}
+		}
+		buf.Write(tmp.Bytes())
+		return true, "", nil
+	}
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// 令牌桶
+type TokenBucket struct {
+	mu sync.Mutex
+	// 每秒生成的令牌数
+	rate float64
+	// 容量
+	burst float64
+	// 当前令牌数
+	tokens float64
+	// 上次更新时间
+	last time.Time
+}
+
+// 新建令牌桶
+//
+//	rate为每秒生成的令牌数，burst为容量，小于等于0时与rate相同，最小为1
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	b := float64(burst)
+	if b <= 0 {
+		b = rate
+	}
+	if b < 1 {
+		b = 1
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  b,
+		tokens: b,
+	}
+}
+
+// 尝试获取令牌
+func (b *TokenBucket) Allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
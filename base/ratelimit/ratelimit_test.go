@@ -0,0 +1,25 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := NewTokenBucket(10, 1)
+
+	assert.True(t, b.Allow(now))
+	assert.False(t, b.Allow(now))
+	assert.False(t, b.Allow(now.Add(50*time.Millisecond)))
+	assert.True(t, b.Allow(now.Add(150*time.Millisecond)))
+
+	// burst为0时与rate相同
+	b = NewTokenBucket(3, 0)
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.Allow(now))
+	}
+	assert.False(t, b.Allow(now))
+}
@@ -0,0 +1,29 @@
+package redact_test
+
+import (
+	"fmt"
+
+	"gitlab.shanhai.int/sre/library/base/redact"
+)
+
+func ExampleNew() {
+	r, err := redact.New(&redact.Config{
+		Rules: []*redact.Rule{
+			{Key: "password"},
+			{Path: "body.user.phone", KeepPrefix: 3, KeepSuffix: 4},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	m := r.Map(map[string]interface{}{
+		"password": "123456",
+		"body":     `{"user":{"phone":"13812345678"}}`,
+	})
+	fmt.Println(m["password"])
+	fmt.Println(m["body"])
+	// Output:
+	// ***
+	// {"user":{"phone":"138***5678"}}
+}
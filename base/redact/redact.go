@@ -0,0 +1,449 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// 默认掩码
+const DefaultMask = "***"
+
+// 脱敏规则
+//
+//	Key、Path、Regexp三者选其一
+//	KeepPrefix及KeepSuffix用于部分脱敏，如手机号保留前3位及后4位
+type Rule struct {
+	// 键名，不区分大小写，在任意嵌套层级生效，如 password、Authorization
+	Key string `yaml:"key"`
+	// 路径，以.分隔，*匹配任意键，数组不占层级，如 headers.Cookie、request_body.user.phone
+	Path string `yaml:"path"`
+	// 正则，对所有字符串值中匹配的内容生效，如手机号 1[3-9]\d{9}
+	Regexp string `yaml:"regexp"`
+	// 保留前N个字符
+	KeepPrefix int `yaml:"keepPrefix"`
+	// 保留后N个字符
+	KeepSuffix int `yaml:"keepSuffix"`
+}
+
+// 脱敏配置
+type Config struct {
+	// 脱敏规则
+	Rules []*Rule `yaml:"rules"`
+	// 掩码，默认为***
+	Mask string `yaml:"mask"`
+}
+
+// 路径规则
+type pathRule struct {
+	// 路径分段，小写
+	segments []string
+	// 规则
+	rule *Rule
+}
+
+// 正则规则
+type regexpRule struct {
+	// 正则
+	re *regexp.Regexp
+	// 规则
+	rule *Rule
+}
+
+// 脱敏器
+//
+//	所有方法均不修改传入的值，存在需要脱敏的内容时返回拷贝
+//	空指针可直接使用，不做任何处理
+type Redactor struct {
+	// 键名规则，键名为小写
+	keys map[string]*Rule
+	// 路径规则
+	paths []pathRule
+	// 正则规则
+	regexps []regexpRule
+	// 掩码
+	mask string
+}
+
+// 新建脱敏器
+func New(config *Config) (*Redactor, error) {
+	r := &Redactor{
+		keys: make(map[string]*Rule),
+		mask: DefaultMask,
+	}
+	if config == nil {
+		return r, nil
+	}
+	if config.Mask != "" {
+		r.mask = config.Mask
+	}
+
+	for _, rule := range config.Rules {
+		if rule == nil {
+			continue
+		}
+		switch {
+		case rule.Key != "":
+			r.keys[strings.ToLower(rule.Key)] = rule
+		case rule.Path != "":
+			r.paths = append(r.paths, pathRule{
+				segments: strings.Split(strings.ToLower(rule.Path), "."),
+				rule:     rule,
+			})
+		case rule.Regexp != "":
+			re, err := regexp.Compile(rule.Regexp)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid redact regexp: %s", rule.Regexp)
+			}
+			r.regexps = append(r.regexps, regexpRule{re: re, rule: rule})
+		default:
+			return nil, errors.New("redact rule should have key, path or regexp")
+		}
+	}
+
+	return r, nil
+}
+
+// 是否没有任何规则
+func (r *Redactor) isEmpty() bool {
+	return r == nil || (len(r.keys) == 0 && len(r.paths) == 0 && len(r.regexps) == 0)
+}
+
+// 脱敏字典，没有需要脱敏的内容时返回原字典
+func (r *Redactor) Map(m map[string]interface{}) map[string]interface{} {
+	if r.isEmpty() || len(m) == 0 {
+		return m
+	}
+	v, changed := r.redactMap(nil, m)
+	if !changed {
+		return m
+	}
+	return v
+}
+
+// 脱敏指定键的值，没有需要脱敏的内容时返回原值
+func (r *Redactor) Value(key string, v interface{}) interface{} {
+	nv, _ := r.Redact(key, v)
+	return nv
+}
+
+// 脱敏指定键的值，并返回是否有修改
+func (r *Redactor) Redact(key string, v interface{}) (interface{}, bool) {
+	if r.isEmpty() {
+		return v, false
+	}
+	nv, changed := r.redact([]string{strings.ToLower(key)}, v)
+	if !changed {
+		return v, false
+	}
+	return nv, true
+}
+
+// 使用正则规则脱敏字符串
+func (r *Redactor) String(s string) string {
+	if r.isEmpty() {
+		return s
+	}
+	return r.redactRegexp(s)
+}
+
+// 查找路径对应的键名或路径规则
+func (r *Redactor) match(path []string) *Rule {
+	if len(path) == 0 {
+		return nil
+	}
+	if rule, ok := r.keys[path[len(path)-1]]; ok {
+		return rule
+	}
+	for _, p := range r.paths {
+		if len(p.segments) != len(path) {
+			continue
+		}
+		matched := true
+		for i, seg := range p.segments {
+			if seg != "*" && seg != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return p.rule
+		}
+	}
+	return nil
+}
+
+// 追加路径
+func appendPath(path []string, key string) []string {
+	p := make([]string, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, strings.ToLower(key))
+}
+
+// 脱敏任意值，返回新值及是否有修改
+func (r *Redactor) redact(path []string, v interface{}) (interface{}, bool) {
+	if v == nil {
+		return nil, false
+	}
+	// 键值对结构体的值，以Key字段替换路径中的Value
+	if kv, ok := v.(*keyedValue); ok {
+		kvPath := appendPath(path[:len(path)-1], kv.key)
+		nv, changed := r.redact(kvPath, kv.value)
+		if !changed {
+			return v, false
+		}
+		return &keyedValue{key: kv.key, value: nv}, true
+	}
+	if rule := r.match(path); rule != nil {
+		return r.maskValue(v, rule), true
+	}
+
+	switch val := v.(type) {
+	case string:
+		s := r.redactString(path, val)
+		return s, s != val
+	case []byte:
+		s := r.redactString(path, string(val))
+		if s == string(val) {
+			return v, false
+		}
+		return []byte(s), true
+	case map[string]interface{}:
+		return r.redactMap(path, val)
+	case []interface{}:
+		return r.redactSlice(path, val)
+	case []string:
+		var out []string
+		for i, s := range val {
+			ns := r.redactString(path, s)
+			if ns == s {
+				continue
+			}
+			if out == nil {
+				out = make([]string, len(val))
+				copy(out, val)
+			}
+			out[i] = ns
+		}
+		if out == nil {
+			return v, false
+		}
+		return out, true
+	case error:
+		s := r.redactRegexp(val.Error())
+		if s == val.Error() {
+			return v, false
+		}
+		return errors.New(s), true
+	case fmt.Stringer, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v, false
+	}
+
+	return r.redactReflect(path, v)
+}
+
+// 脱敏字典
+func (r *Redactor) redactMap(path []string, m map[string]interface{}) (map[string]interface{}, bool) {
+	var out map[string]interface{}
+	for k, v := range m {
+		nv, changed := r.redact(appendPath(path, k), v)
+		if !changed {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]interface{}, len(m))
+			for k2, v2 := range m {
+				out[k2] = v2
+			}
+		}
+		out[k] = nv
+	}
+	if out == nil {
+		return m, false
+	}
+	return out, true
+}
+
+// 脱敏切片，数组不占路径层级
+func (r *Redactor) redactSlice(path []string, s []interface{}) ([]interface{}, bool) {
+	var out []interface{}
+	for i, v := range s {
+		nv, changed := r.redact(path, v)
+		if !changed {
+			continue
+		}
+		if out == nil {
+			out = make([]interface{}, len(s))
+			copy(out, s)
+		}
+		out[i] = nv
+	}
+	if out == nil {
+		return s, false
+	}
+	return out, true
+}
+
+// 通过反射脱敏其他类型的字典及切片，如http.Header、bson.M、bson.D
+//
+//	有修改时转换为map[string]interface{}及[]interface{}，json序列化结果保持一致
+func (r *Redactor) redactReflect(path []string, v interface{}) (interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v, false
+		}
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = iter.Value().Interface()
+		}
+		nm, changed := r.redactMap(path, m)
+		if !changed {
+			return v, false
+		}
+		return nm, true
+	case reflect.Slice, reflect.Array:
+		s := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			s[i] = structToMap(rv.Index(i))
+		}
+		ns, changed := r.redactSlice(path, s)
+		if !changed {
+			return v, false
+		}
+		return ns, true
+	}
+	return v, false
+}
+
+// 将包含Key及Value字段的结构体(如bson.E)转换为字典，其他值保持不变
+func structToMap(v reflect.Value) interface{} {
+	if v.Kind() != reflect.Struct {
+		return v.Interface()
+	}
+	key := v.FieldByName("Key")
+	value := v.FieldByName("Value")
+	if !key.IsValid() || key.Kind() != reflect.String || !value.IsValid() || !value.CanInterface() {
+		return v.Interface()
+	}
+	return map[string]interface{}{
+		"Key":   key.String(),
+		"Value": &keyedValue{key: key.String(), value: value.Interface()},
+	}
+}
+
+// 以Key字段作为键名的值，用于键值对结构体的脱敏
+type keyedValue struct {
+	key   string
+	value interface{}
+}
+
+func (kv *keyedValue) String() string {
+	return fmt.Sprint(kv.value)
+}
+
+func (kv *keyedValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(kv.value)
+}
+
+// 脱敏字符串
+//
+//	json字符串(如http请求体)解析后按键名及路径脱敏，其他字符串使用正则脱敏
+func (r *Redactor) redactString(path []string, s string) string {
+	trimmed := strings.TrimSpace(s)
+	if (len(r.keys) != 0 || len(r.paths) != 0) && len(trimmed) > 1 &&
+		(trimmed[0] == '{' || trimmed[0] == '[') {
+		decoder := json.NewDecoder(strings.NewReader(trimmed))
+		decoder.UseNumber()
+		var v interface{}
+		if err := decoder.Decode(&v); err == nil {
+			nv, changed := r.redact(path, v)
+			if !changed {
+				return s
+			}
+			buf := new(bytes.Buffer)
+			encoder := json.NewEncoder(buf)
+			encoder.SetEscapeHTML(false)
+			if err := encoder.Encode(nv); err == nil {
+				return strings.TrimRight(buf.String(), "\n")
+			}
+		}
+	}
+	return r.redactRegexp(s)
+}
+
+// 正则脱敏
+func (r *Redactor) redactRegexp(s string) string {
+	for _, item := range r.regexps {
+		rule := item.rule
+		s = item.re.ReplaceAllStringFunc(s, func(match string) string {
+			return r.maskString(match, rule)
+		})
+	}
+	return s
+}
+
+// 按规则掩码任意值
+func (r *Redactor) maskValue(v interface{}, rule *Rule) interface{} {
+	switch val := v.(type) {
+	case string:
+		return r.maskString(val, rule)
+	case []byte:
+		return r.maskString(string(val), rule)
+	case []string:
+		out := make([]string, len(val))
+		for i, s := range val {
+			out[i] = r.maskString(s, rule)
+		}
+		return out
+	case *keyedValue:
+		return r.maskValue(val.value, rule)
+	}
+
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct, reflect.Ptr:
+		return r.mask
+	}
+	return r.maskString(fmt.Sprint(v), rule)
+}
+
+// 按规则掩码字符串，保留部分前后缀
+func (r *Redactor) maskString(s string, rule *Rule) string {
+	if rule.KeepPrefix <= 0 && rule.KeepSuffix <= 0 {
+		return r.mask
+	}
+	runes := []rune(s)
+	prefix, suffix := rule.KeepPrefix, rule.KeepSuffix
+	if prefix < 0 {
+		prefix = 0
+	}
+	if suffix < 0 {
+		suffix = 0
+	}
+	if prefix+suffix >= len(runes) {
+		return r.mask
+	}
+	return string(runes[:prefix]) + r.mask + string(runes[len(runes)-suffix:])
+}
+
+// 全局默认脱敏器
+var _default atomic.Value
+
+// 设置全局默认脱敏器，log包及基于hook的组件日志均使用该脱敏器
+func SetDefault(r *Redactor) {
+	_default.Store(r)
+}
+
+// 获取全局默认脱敏器，未设置时为空指针，可直接使用
+func Default() *Redactor {
+	r, _ := _default.Load().(*Redactor)
+	return r
+}
@@ -0,0 +1,134 @@
+package redact
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 模拟bson.E
+type testElem struct {
+	Key   string
+	Value interface{}
+}
+
+func newTestRedactor(t *testing.T) *Redactor {
+	r, err := New(&Config{
+		Rules: []*Rule{
+			{Key: "password"},
+			{Key: "Authorization"},
+			{Key: "token", KeepPrefix: 2, KeepSuffix: 2},
+			{Path: "request_body.user.id_card"},
+			{Path: "*.cookie"},
+			{Regexp: `1[3-9]\d{9}`, KeepPrefix: 3, KeepSuffix: 4},
+		},
+	})
+	assert.Nil(t, err)
+	return r
+}
+
+func TestRedactor_Map(t *testing.T) {
+	r := newTestRedactor(t)
+
+	t.Run("nested", func(t *testing.T) {
+		origin := map[string]interface{}{
+			"password": "123456",
+			"user": map[string]interface{}{
+				"Password": 123456,
+				"name":     "test",
+				"phones":   []interface{}{"13812345678", "110"},
+			},
+			"token": "abcdefgh",
+			"count": 1,
+		}
+		m := r.Map(origin)
+		assert.Equal(t, map[string]interface{}{
+			"password": "***",
+			"user": map[string]interface{}{
+				"Password": "***",
+				"name":     "test",
+				"phones":   []interface{}{"138***5678", "110"},
+			},
+			"token": "ab***gh",
+			"count": 1,
+		}, m)
+		// 不修改原字典
+		assert.Equal(t, "123456", origin["password"])
+		assert.Equal(t, 123456, origin["user"].(map[string]interface{})["Password"])
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		origin := map[string]interface{}{"name": "test"}
+		m := r.Map(origin)
+		m["x"] = 1
+		assert.Equal(t, 1, origin["x"])
+	})
+
+	t.Run("headers", func(t *testing.T) {
+		headers := http.Header{}
+		headers.Set("Authorization", "Bearer xxx")
+		headers.Set("Cookie", "session=1")
+		headers.Set("Accept", "*/*")
+		m := r.Map(map[string]interface{}{"headers": headers})
+		assert.Equal(t, map[string]interface{}{
+			"Authorization": []string{"***"},
+			"Cookie":        []string{"***"},
+			"Accept":        []string{"*/*"},
+		}, m["headers"])
+		assert.Equal(t, "Bearer xxx", headers.Get("Authorization"))
+	})
+
+	t.Run("json body", func(t *testing.T) {
+		m := r.Map(map[string]interface{}{
+			"request_body":  `{"user":{"id_card":"440000200001010000","age":18},"password":"1"}`,
+			"response_body": `{"id_card":"440000200001010000"}`,
+			"text_body":     `call 13812345678`,
+		})
+		assert.Equal(t, `{"password":"***","user":{"age":18,"id_card":"***"}}`, m["request_body"])
+		assert.Equal(t, `{"id_card":"440000200001010000"}`, m["response_body"])
+		assert.Equal(t, `call 138***5678`, m["text_body"])
+	})
+
+	t.Run("command args", func(t *testing.T) {
+		args := []interface{}{"user:1", "13812345678", 1}
+		m := r.Map(map[string]interface{}{"command_args": args})
+		assert.Equal(t, []interface{}{"user:1", "138***5678", 1}, m["command_args"])
+		assert.Equal(t, "13812345678", args[1])
+	})
+
+	t.Run("key value slice", func(t *testing.T) {
+		filter := []testElem{{Key: "password", Value: "1"}, {Key: "name", Value: "test"}}
+		m := r.Map(map[string]interface{}{"filter_field": filter})
+		b, err := json.Marshal(m["filter_field"])
+		assert.Nil(t, err)
+		assert.JSONEq(t, `[{"Key":"password","Value":"***"},{"Key":"name","Value":"test"}]`, string(b))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		m := r.Map(map[string]interface{}{"error": errors.New("phone 13812345678 exists")})
+		assert.Equal(t, "phone 138***5678 exists", m["error"].(error).Error())
+	})
+}
+
+func TestRedactor_Nil(t *testing.T) {
+	var r *Redactor
+	m := map[string]interface{}{"password": "1"}
+	assert.Equal(t, m, r.Map(m))
+	assert.Equal(t, "1", r.Value("password", "1"))
+	assert.Equal(t, "1", r.String("1"))
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(&Config{Rules: []*Rule{{Regexp: "("}}})
+	assert.NotNil(t, err)
+	_, err = New(&Config{Rules: []*Rule{{KeepPrefix: 1}}})
+	assert.NotNil(t, err)
+
+	r, err := New(&Config{Rules: []*Rule{{Key: "password"}}, Mask: "[FILTERED]"})
+	assert.Nil(t, err)
+	assert.Equal(t, "[FILTERED]", r.Value("password", "1"))
+	assert.Equal(t, "13812345678", r.String("13812345678"))
+}
@@ -117,6 +117,14 @@ func (db *DB) loadPrefixData(parentCtx context.Context, prefix string, valueFilt
 					}
 
 					for _, e := range watchResp.Events {
+						// 删除时移除数据，并以空值通知
+						if e.Type == mvccpb.DELETE {
+							key := strings.TrimPrefix(string(e.Kv.Key), prefix)
+							dataMap.Delete(key)
+							db.after(ctx, prefix, string(e.Kv.Key), "", "watch delete")
+							storeData.notify(key, "")
+							continue
+						}
 						if e.Type != mvccpb.PUT {
 							continue
 						}
@@ -128,6 +136,7 @@ func (db *DB) loadPrefixData(parentCtx context.Context, prefix string, valueFilt
 
 						dataMap.Store(key, value)
 						db.after(ctx, prefix, string(e.Kv.Key), string(e.Kv.Value), "watch")
+						storeData.notify(key, value)
 					}
 				}
 			}
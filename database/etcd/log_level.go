@@ -0,0 +1,33 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+
+	"gitlab.shanhai.int/sre/library/log"
+)
+
+// 使用指定前缀的数据作为日志级别配置，并实时监听变更
+//
+//	去除前缀后的key为模块路径(如database/redis，root为默认日志级别)，value为日志级别名(如debug)
+//	删除模块的key后该模块使用默认日志级别，删除root时保持当前默认日志级别
+func (db *DB) WatchLogLevel(ctx context.Context, prefix string) error {
+	data, err := db.GetPrefix(prefix)
+	if err != nil || !data.enableWatch {
+		data, err = db.ForceLoadPrefixData(ctx, prefix, nil, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 重复调用时只注册一次，删除模块的键后该模块恢复默认日志级别
+	if data.markLogLevel() {
+		data.OnChange(func(key, value string) {
+			if err := log.UpdateLevels(data.All()); err != nil {
+				db.after(ctx, prefix, key, value, fmt.Sprintf("update log level error: %s", err))
+			}
+		})
+	}
+
+	return log.UpdateLevels(data.All())
+}
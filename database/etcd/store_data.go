@@ -16,6 +16,12 @@ type StoreData struct {
 	cancel context.CancelFunc
 	// 是否开启监听
 	enableWatch bool
+	// 数据变更监听函数
+	listeners []func(key, value string)
+	// 监听函数读写锁
+	listenerLock sync.RWMutex
+	// 是否已作为日志级别配置监听
+	logLevelWatched bool
 }
 
 // 获取指定键的值
@@ -27,3 +33,44 @@ func (s *StoreData) Get(key string) (string, error) {
 
 	return value.(string), nil
 }
+
+// 获取全部键值
+func (s *StoreData) All() map[string]string {
+	m := make(map[string]string)
+	s.data.Range(func(key, value interface{}) bool {
+		m[key.(string)] = value.(string)
+		return true
+	})
+	return m
+}
+
+// 注册数据变更监听函数
+//
+//	仅开启监听时生效，变更数据存储后调用，删除键时value为空
+func (s *StoreData) OnChange(f func(key, value string)) {
+	s.listenerLock.Lock()
+	defer s.listenerLock.Unlock()
+	s.listeners = append(s.listeners, f)
+}
+
+// 通知数据变更
+func (s *StoreData) notify(key, value string) {
+	s.listenerLock.RLock()
+	listeners := s.listeners
+	s.listenerLock.RUnlock()
+
+	for _, f := range listeners {
+		f(key, value)
+	}
+}
+
+// 标记为日志级别配置，已标记时返回false，避免重复注册监听函数
+func (s *StoreData) markLogLevel() bool {
+	s.listenerLock.Lock()
+	defer s.listenerLock.Unlock()
+	if s.logLevelWatched {
+		return false
+	}
+	s.logLevelWatched = true
+	return true
+}
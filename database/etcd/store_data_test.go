@@ -0,0 +1,26 @@
+package etcd
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreData_MarkLogLevel(t *testing.T) {
+	data := &StoreData{data: new(sync.Map)}
+	assert.True(t, data.markLogLevel())
+	// 重复监听时不再注册
+	assert.False(t, data.markLogLevel())
+
+	var changes []string
+	data.OnChange(func(key, value string) {
+		changes = append(changes, key+"="+value)
+	})
+	data.data.Store("database/redis", "debug")
+	data.notify("database/redis", "debug")
+	data.data.Delete("database/redis")
+	data.notify("database/redis", "")
+	assert.Equal(t, []string{"database/redis=debug", "database/redis="}, changes)
+	assert.Equal(t, map[string]string{}, data.All())
+}
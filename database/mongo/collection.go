@@ -41,7 +41,9 @@ func (c *Collection) BulkWrite(ctx context.Context, models []mongo.WriteModel,
 // 插入单个文档
 func (c *Collection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (result *mongo.InsertOneResult, err error) {
 	ctx, hk := c.con.before(ctx, "InsertOne", c.Name(), nil, document, nil, opts)
-	result, err = c.Collection.InsertOne(c.getExecContext(ctx), document, opts...)
+	if err = hk.Err(); err == nil {
+		result, err = c.Collection.InsertOne(c.getExecContext(ctx), document, opts...)
+	}
 	c.con.after(hk, err)
 
 	return
@@ -50,7 +52,9 @@ func (c *Collection) InsertOne(ctx context.Context, document interface{}, opts .
 // 插入多个文档
 func (c *Collection) InsertMany(ctx context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (result *mongo.InsertManyResult, err error) {
 	ctx, hk := c.con.before(ctx, "InsertMany", c.Name(), nil, documents, nil, opts)
-	result, err = c.Collection.InsertMany(c.getExecContext(ctx), documents, opts...)
+	if err = hk.Err(); err == nil {
+		result, err = c.Collection.InsertMany(c.getExecContext(ctx), documents, opts...)
+	}
 	c.con.after(hk, err)
 
 	return
@@ -59,7 +63,9 @@ func (c *Collection) InsertMany(ctx context.Context, documents []interface{}, op
 // 删除单个文档
 func (c *Collection) DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (result *mongo.DeleteResult, err error) {
 	ctx, hk := c.con.before(ctx, "DeleteOne", c.Name(), filter, nil, nil, opts)
-	result, err = c.Collection.DeleteOne(c.getExecContext(ctx), filter, opts...)
+	if err = hk.Err(); err == nil {
+		result, err = c.Collection.DeleteOne(c.getExecContext(ctx), filter, opts...)
+	}
 	c.con.after(hk, err)
 
 	return
@@ -68,7 +74,9 @@ func (c *Collection) DeleteOne(ctx context.Context, filter interface{}, opts ...
 // 删除多个文档
 func (c *Collection) DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (result *mongo.DeleteResult, err error) {
 	ctx, hk := c.con.before(ctx, "DeleteMany", c.Name(), filter, nil, nil, opts)
-	result, err = c.Collection.DeleteMany(c.getExecContext(ctx), filter, opts...)
+	if err = hk.Err(); err == nil {
+		result, err = c.Collection.DeleteMany(c.getExecContext(ctx), filter, opts...)
+	}
 	c.con.after(hk, err)
 
 	return
@@ -77,7 +85,9 @@ func (c *Collection) DeleteMany(ctx context.Context, filter interface{}, opts ..
 // 更新单个文档
 func (c *Collection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (result *mongo.UpdateResult, err error) {
 	ctx, hk := c.con.before(ctx, "UpdateOne", c.Name(), filter, update, nil, opts)
-	result, err = c.Collection.UpdateOne(c.getExecContext(ctx), filter, update, opts...)
+	if err = hk.Err(); err == nil {
+		result, err = c.Collection.UpdateOne(c.getExecContext(ctx), filter, update, opts...)
+	}
 	c.con.after(hk, err)
 
 	return
@@ -86,7 +96,9 @@ func (c *Collection) UpdateOne(ctx context.Context, filter interface{}, update i
 // 更新多个文档
 func (c *Collection) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (result *mongo.UpdateResult, err error) {
 	ctx, hk := c.con.before(ctx, "UpdateMany", c.Name(), filter, update, nil, opts)
-	result, err = c.Collection.UpdateMany(c.getExecContext(ctx), filter, update, opts...)
+	if err = hk.Err(); err == nil {
+		result, err = c.Collection.UpdateMany(c.getExecContext(ctx), filter, update, opts...)
+	}
 	c.con.after(hk, err)
 
 	return
@@ -95,7 +107,9 @@ func (c *Collection) UpdateMany(ctx context.Context, filter interface{}, update
 // 替换单个文档
 func (c *Collection) ReplaceOne(ctx context.Context, filter interface{}, replacement interface{}, opts ...*options.ReplaceOptions) (result *mongo.UpdateResult, err error) {
 	ctx, hk := c.con.before(ctx, "ReplaceOne", c.Name(), filter, replacement, nil, opts)
-	result, err = c.Collection.ReplaceOne(c.getExecContext(ctx), filter, replacement, opts...)
+	if err = hk.Err(); err == nil {
+		result, err = c.Collection.ReplaceOne(c.getExecContext(ctx), filter, replacement, opts...)
+	}
 	c.con.after(hk, err)
 
 	return
@@ -107,7 +121,11 @@ func (c *Collection) Aggregate(ctx context.Context, pipeline interface{}, opts .
 	raws := make([]bson.Raw, 0)
 
 	ctx, hk := c.con.before(ctx, "Aggregate", c.Name(), nil, nil, pipeline, opts)
-	cur, err := c.Collection.Aggregate(execCtx, pipeline, opts...)
+	var cur *mongo.Cursor
+	err := hk.Err()
+	if err == nil {
+		cur, err = c.Collection.Aggregate(execCtx, pipeline, opts...)
+	}
 	c.con.after(hk, err)
 
 	if err != nil {
@@ -131,7 +149,9 @@ func (c *Collection) Aggregate(ctx context.Context, pipeline interface{}, opts .
 // 统计文档数
 func (c *Collection) CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (result int64, err error) {
 	ctx, hk := c.con.before(ctx, "CountDocuments", c.Name(), filter, nil, nil, opts)
-	result, err = c.Collection.CountDocuments(c.getQueryContext(ctx), filter, opts...)
+	if err = hk.Err(); err == nil {
+		result, err = c.Collection.CountDocuments(c.getQueryContext(ctx), filter, opts...)
+	}
 	c.con.after(hk, err)
 
 	return
@@ -140,7 +160,9 @@ func (c *Collection) CountDocuments(ctx context.Context, filter interface{}, opt
 // 预估文档数，从info中直接获取
 func (c *Collection) EstimatedDocumentCount(ctx context.Context, opts ...*options.EstimatedDocumentCountOptions) (result int64, err error) {
 	ctx, hk := c.con.before(ctx, "EstimatedDocumentCount", c.Name(), nil, nil, nil, opts)
-	result, err = c.Collection.EstimatedDocumentCount(c.getQueryContext(ctx), opts...)
+	if err = hk.Err(); err == nil {
+		result, err = c.Collection.EstimatedDocumentCount(c.getQueryContext(ctx), opts...)
+	}
 	c.con.after(hk, err)
 
 	return
@@ -149,7 +171,9 @@ func (c *Collection) EstimatedDocumentCount(ctx context.Context, opts ...*option
 // 去重查找文档
 func (c *Collection) Distinct(ctx context.Context, fieldName string, filter interface{}, opts ...*options.DistinctOptions) (result []interface{}, err error) {
 	ctx, hk := c.con.before(ctx, "Distinct", c.Name(), filter, nil, fieldName, opts)
-	result, err = c.Collection.Distinct(c.getQueryContext(ctx), fieldName, filter, opts...)
+	if err = hk.Err(); err == nil {
+		result, err = c.Collection.Distinct(c.getQueryContext(ctx), fieldName, filter, opts...)
+	}
 	c.con.after(hk, err)
 
 	return
@@ -161,7 +185,11 @@ func (c *Collection) Find(ctx context.Context, filter interface{}, opts ...*opti
 	raws := make([]bson.Raw, 0)
 
 	ctx, hk := c.con.before(ctx, "Find", c.Name(), filter, nil, nil, opts)
-	cur, err := c.Collection.Find(queryCtx, filter, opts...)
+	var cur *mongo.Cursor
+	err := hk.Err()
+	if err == nil {
+		cur, err = c.Collection.Find(queryCtx, filter, opts...)
+	}
 	c.con.after(hk, err)
 
 	if err != nil {
@@ -195,7 +223,11 @@ func (c *Collection) FindPage(ctx context.Context, filter interface{}, page, lim
 	raws := make([]bson.Raw, 0)
 
 	ctx, hk := c.con.before(ctx, "FindPage", c.Name(), filter, nil, nil, opts)
-	cur, err := c.Collection.Find(ctx, filter, opts...)
+	var cur *mongo.Cursor
+	err := hk.Err()
+	if err == nil {
+		cur, err = c.Collection.Find(ctx, filter, opts...)
+	}
 	c.con.after(hk, err)
 
 	if err != nil {
@@ -218,6 +250,10 @@ func (c *Collection) FindPage(ctx context.Context, filter interface{}, page, lim
 // 查找单个文档
 func (c *Collection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *SingleResult {
 	ctx, hk := c.con.before(ctx, "FindOne", c.Name(), filter, nil, nil, opts)
+	if err := hk.Err(); err != nil {
+		c.con.after(hk, err)
+		return &SingleResult{err: err}
+	}
 	result := c.Collection.FindOne(c.getQueryContext(ctx), filter, opts...)
 	c.con.after(hk, result.Err())
 
@@ -229,6 +265,10 @@ func (c *Collection) FindOne(ctx context.Context, filter interface{}, opts ...*o
 // 查找单个文档并删除
 func (c *Collection) FindOneAndDelete(ctx context.Context, filter interface{}, opts ...*options.FindOneAndDeleteOptions) *SingleResult {
 	ctx, hk := c.con.before(ctx, "FindOneAndDelete", c.Name(), filter, nil, nil, opts)
+	if err := hk.Err(); err != nil {
+		c.con.after(hk, err)
+		return &SingleResult{err: err}
+	}
 	result := c.Collection.FindOneAndDelete(c.getExecContext(ctx), filter, opts...)
 	c.con.after(hk, result.Err())
 
@@ -240,6 +280,10 @@ func (c *Collection) FindOneAndDelete(ctx context.Context, filter interface{}, o
 // 查找单个文档并替换
 func (c *Collection) FindOneAndReplace(ctx context.Context, filter interface{}, replacement interface{}, opts ...*options.FindOneAndReplaceOptions) *SingleResult {
 	ctx, hk := c.con.before(ctx, "FindOneAndReplace", c.Name(), filter, replacement, nil, opts)
+	if err := hk.Err(); err != nil {
+		c.con.after(hk, err)
+		return &SingleResult{err: err}
+	}
 	result := c.Collection.FindOneAndReplace(c.getExecContext(ctx), filter, replacement, opts...)
 	c.con.after(hk, result.Err())
 
@@ -251,6 +295,10 @@ func (c *Collection) FindOneAndReplace(ctx context.Context, filter interface{},
 // 查找单个文档并更新
 func (c *Collection) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) *SingleResult {
 	ctx, hk := c.con.before(ctx, "FindOneAndUpdate", c.Name(), filter, update, nil, opts)
+	if err := hk.Err(); err != nil {
+		c.con.after(hk, err)
+		return &SingleResult{err: err}
+	}
 	result := c.Collection.FindOneAndUpdate(c.getExecContext(ctx), filter, update, opts...)
 	c.con.after(hk, result.Err())
 
@@ -262,7 +310,9 @@ func (c *Collection) FindOneAndUpdate(ctx context.Context, filter interface{}, u
 // 观察数据库变动流
 func (c *Collection) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (result *mongo.ChangeStream, err error) {
 	ctx, hk := c.con.before(ctx, "Watch", c.Name(), nil, nil, pipeline, opts)
-	result, err = c.Collection.Watch(c.getQueryContext(ctx), pipeline, opts...)
+	if err = hk.Err(); err == nil {
+		result, err = c.Collection.Watch(c.getQueryContext(ctx), pipeline, opts...)
+	}
 	c.con.after(hk, err)
 
 	return
@@ -271,8 +321,10 @@ func (c *Collection) Watch(ctx context.Context, pipeline interface{}, opts ...*o
 // 获取索引
 func (c *Collection) Indexes(ctx context.Context) (result mongo.IndexView, err error) {
 	ctx, hk := c.con.before(ctx, "Indexes", c.Name(), nil, nil, nil, nil)
-	result = c.Collection.Indexes()
-	c.con.after(hk, nil)
+	if err = hk.Err(); err == nil {
+		result = c.Collection.Indexes()
+	}
+	c.con.after(hk, err)
 
 	return
 }
@@ -280,7 +332,10 @@ func (c *Collection) Indexes(ctx context.Context) (result mongo.IndexView, err e
 // 丢弃
 func (c *Collection) Drop(ctx context.Context) error {
 	ctx, hk := c.con.before(ctx, "Drop", c.Name(), nil, nil, nil, nil)
-	err := c.Collection.Drop(c.getExecContext(ctx))
+	err := hk.Err()
+	if err == nil {
+		err = c.Collection.Drop(c.getExecContext(ctx))
+	}
 	c.con.after(hk, err)
 
 	return err
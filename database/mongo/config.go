@@ -2,6 +2,7 @@ package mongo
 
 import (
 	"gitlab.shanhai.int/sre/library/base/ctime"
+	"gitlab.shanhai.int/sre/library/base/hook"
 	render "gitlab.shanhai.int/sre/library/base/logrender"
 )
 
@@ -33,6 +34,8 @@ type Config struct {
 	MaxPoolSize int `yaml:"maxPoolSize"`
 	// 连接池最小数量
 	MinPoolSize int `yaml:"minPoolSize"`
+	// 慢操作配置，按方法名称匹配阈值，如 Find、UpdateOne
+	Slow *hook.SlowConfig `yaml:"slow"`
 
 	// 日志配置
 	*render.Config `yaml:",inline"`
@@ -61,6 +61,10 @@ func (con *Connection) Transaction(ctx context.Context, callback func(con *Conne
 	defer session.EndSession(ctx)
 
 	ctx, hk := con.before(ctx, "Transaction", "", nil, nil, nil, opts)
+	if err = hk.Err(); err != nil {
+		con.after(hk, err)
+		return nil, err
+	}
 	res, err := session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
 		return callback(con, sessCtx)
 	})
@@ -72,7 +76,9 @@ func (con *Connection) Transaction(ctx context.Context, callback func(con *Conne
 // Ping检查
 func (con *Connection) ping(c context.Context, rp *readpref.ReadPref) (err error) {
 	_, hk := con.before(c, "Ping", "", rp.String(), nil, nil, nil)
-	err = con.Ping(c, rp)
+	if err = hk.Err(); err == nil {
+		err = con.Ping(c, rp)
+	}
 	con.after(hk, err)
 	if err != nil {
 		err = errors.WithStack(err)
@@ -101,10 +107,11 @@ func (con *Connection) before(ctx context.Context, funcName, collectionName stri
 // 操作后注入
 func (con *Connection) after(hk *hook.Hook, err error) {
 	endTime := time.Now()
-	duration := endTime.Sub(hk.Arg(render.StartTimeArgKey).(time.Time))
+	duration := endTime.Sub(hk.Event().StartTime)
 
 	hk = hk.AddArg(render.EndTimeArgKey, endTime).
 		AddArg(render.DurationArgKey, duration).
 		AddArg(render.ErrorArgKey, err).
 		ProcessAfterHook()
+	hk.Release()
 }
@@ -154,7 +154,7 @@ func connect(c *Config, dsnConfig *DSNConfig) (*Connection, error) {
 		Client:  client,
 		conf:    c,
 		dbName:  dsnConfig.DBName,
-		manager: NewHookManager(c.Config, dsnConfig.UserName),
+		manager: NewHookManager(c.Config, dsnConfig.UserName).RegisterSlowHook(c.Slow, slowCommand),
 	}, nil
 }
 
@@ -24,6 +24,7 @@ const (
 // 新建钩子管理器
 func NewHookManager(renderConfig *render.Config, dsn string) *hook.Manager {
 	return hook.NewManager().
+		SetComponent(hook.ComponentMongo).
 		AddArg("dsn", dsn).
 		RegisterLogHook(renderConfig, patternMap).
 		RegisterHook(func(hk *hook.Hook) {
@@ -79,6 +80,11 @@ func NewHookManager(renderConfig *render.Config, dsn string) *hook.Manager {
 		})
 }
 
+// 慢操作按方法名称匹配阈值
+func slowCommand(hk *hook.Hook) string {
+	return funcName(hk.Args()).StringValue()
+}
+
 // 渲染模版
 var patternMap = map[string]render.PatternFunc{
 	"T": render.PatternEndTime,
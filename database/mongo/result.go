@@ -14,6 +14,14 @@ type SingleResult struct {
 	err error
 }
 
+// 获取错误
+func (sr *SingleResult) Err() error {
+	if sr.err != nil {
+		return sr.err
+	}
+	return sr.SingleResult.Err()
+}
+
 // 将单个查询结果解码至value中
 func (sr *SingleResult) Decode(value interface{}) error {
 	if sr.err != nil {
@@ -0,0 +1,580 @@
+package redis
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// 集群槽位数
+const clusterSlots = 16384
+
+// 集群
+//
+//	维护槽位分布及各节点连接池，收到MOVED重定向时更新槽位并异步刷新槽位分布
+type cluster struct {
+	// 配置文件
+	config *Config
+
+	// 读写锁
+	mu sync.RWMutex
+	// 槽位对应的节点地址
+	slots [clusterSlots]string
+	// 各节点连接池
+	pools map[string]*redis.Pool
+
+	// 是否正在刷新槽位分布
+	refreshing int32
+}
+
+// 新建集群
+func newCluster(cfg *Config) *cluster {
+	return &cluster{
+		config: cfg,
+		pools:  make(map[string]*redis.Pool),
+	}
+}
+
+// 刷新槽位分布，依次尝试已知节点及初始节点
+func (c *cluster) refresh() error {
+	c.mu.RLock()
+	addrs := make([]string, 0, len(c.pools)+len(c.config.Cluster.Endpoints))
+	for addr := range c.pools {
+		addrs = append(addrs, addr)
+	}
+	c.mu.RUnlock()
+	for _, endpoint := range c.config.Cluster.Endpoints {
+		addrs = append(addrs, endpoint.String())
+	}
+
+	var lastErr error
+	for _, addr := range addrs {
+		slots, err := c.querySlots(addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		c.mu.Lock()
+		c.slots = slots
+		c.mu.Unlock()
+		return nil
+	}
+	return errors.Wrap(lastErr, "redis cluster refresh slots error")
+}
+
+// 异步刷新槽位分布，同一时间只有一个刷新
+func (c *cluster) refreshAsync() {
+	if !atomic.CompareAndSwapInt32(&c.refreshing, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&c.refreshing, 0)
+		_ = c.refresh()
+	}()
+}
+
+// 向单个节点查询槽位分布
+func (c *cluster) querySlots(addr string) (slots [clusterSlots]string, err error) {
+	conn := c.getConn(addr)
+	defer conn.Close()
+
+	values, err := redis.Values(conn.Do("CLUSTER", "SLOTS"))
+	if err != nil {
+		return slots, err
+	}
+	if len(values) == 0 {
+		return slots, errors.Errorf("redis cluster node %s has no slots", addr)
+	}
+
+	for _, value := range values {
+		info, err := redis.Values(value, nil)
+		if err != nil {
+			return slots, err
+		}
+		if len(info) < 3 {
+			return slots, errors.Errorf("redis cluster node %s reply of slots is invalid", addr)
+		}
+		start, err := redis.Int(info[0], nil)
+		if err != nil {
+			return slots, err
+		}
+		end, err := redis.Int(info[1], nil)
+		if err != nil {
+			return slots, err
+		}
+		master, err := redis.Values(info[2], nil)
+		if err != nil {
+			return slots, err
+		}
+		if len(master) < 2 || start < 0 || end >= clusterSlots || start > end {
+			return slots, errors.Errorf("redis cluster node %s reply of slots is invalid", addr)
+		}
+		host, err := redis.String(master[0], nil)
+		if err != nil {
+			return slots, err
+		}
+		port, err := redis.Int(master[1], nil)
+		if err != nil {
+			return slots, err
+		}
+		// 节点未配置地址时，使用当前查询的节点地址
+		if host == "" {
+			host, _, _ = net.SplitHostPort(addr)
+		}
+
+		nodeAddr := net.JoinHostPort(host, strconv.Itoa(port))
+		for slot := start; slot <= end; slot++ {
+			slots[slot] = nodeAddr
+		}
+	}
+	return slots, nil
+}
+
+// 获取槽位对应的节点地址，槽位未知时返回任意节点地址
+func (c *cluster) addrForSlot(slot int) string {
+	c.mu.RLock()
+	addr := c.slots[slot]
+	c.mu.RUnlock()
+	if addr != "" {
+		return addr
+	}
+	return c.anyAddr()
+}
+
+// 获取任意节点地址
+func (c *cluster) anyAddr() string {
+	c.mu.RLock()
+	addr := c.slots[rand.Intn(clusterSlots)]
+	c.mu.RUnlock()
+	if addr != "" {
+		return addr
+	}
+	return c.config.Cluster.Endpoints[0].String()
+}
+
+// 获取所有主节点地址，槽位分布未知时返回任意节点地址
+func (c *cluster) masters() []string {
+	c.mu.RLock()
+	seen := make(map[string]bool)
+	var addrs []string
+	for _, addr := range c.slots {
+		if addr != "" && !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	c.mu.RUnlock()
+	if len(addrs) == 0 {
+		return []string{c.anyAddr()}
+	}
+	return addrs
+}
+
+// 更新槽位对应的节点地址
+func (c *cluster) setSlot(slot int, addr string) {
+	c.mu.Lock()
+	c.slots[slot] = addr
+	c.mu.Unlock()
+}
+
+// 获取节点连接，节点连接池不存在时新建
+func (c *cluster) getConn(addr string) redis.Conn {
+	c.mu.RLock()
+	pool, ok := c.pools[addr]
+	c.mu.RUnlock()
+	if ok {
+		return pool.Get()
+	}
+
+	c.mu.Lock()
+	pool, ok = c.pools[addr]
+	if !ok {
+		pool = newRedisPool(c.config, func() (redis.Conn, error) {
+			return dial(c.config, addr, 0)
+		})
+		c.pools[addr] = pool
+	}
+	c.mu.Unlock()
+	return pool.Get()
+}
+
+// 新建集群连接
+func (c *cluster) dial() (redis.Conn, error) {
+	return &clusterConn{cluster: c}, nil
+}
+
+// 关闭各节点连接池
+func (c *cluster) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var lastErr error
+	for addr, pool := range c.pools {
+		if err := pool.Close(); err != nil {
+			lastErr = err
+		}
+		delete(c.pools, addr)
+	}
+	return lastErr
+}
+
+// 已缓存的命令
+type clusterCommand struct {
+	name string
+	args []interface{}
+}
+
+// 集群模式连接
+//
+//	Do按key所在槽位路由到对应节点，并处理MOVED/ASK重定向
+//	Send/Flush/Receive(管道及事务)时，连接绑定到首个带key的命令所在节点，之前不带key的命令(如MULTI)暂存至绑定后发送
+//	绑定后不处理重定向，管道及事务中的key需位于同一节点，Do接收全部回复且不在事务中时解除绑定
+type clusterConn struct {
+	// 集群
+	cluster *cluster
+	// 绑定的节点连接
+	bound redis.Conn
+	// 绑定前暂存的命令
+	pending []clusterCommand
+	// 是否在事务中(MULTI/WATCH之后)
+	transaction bool
+	// 是否已关闭
+	closed bool
+}
+
+func (c *clusterConn) Close() error {
+	c.release()
+	c.closed = true
+	return nil
+}
+
+func (c *clusterConn) Err() error {
+	if c.closed {
+		return errors.New("redis cluster connection is closed")
+	}
+	if c.bound != nil {
+		return c.bound.Err()
+	}
+	return nil
+}
+
+func (c *clusterConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	// 刷新并接收所有回复，之后解除绑定
+	if commandName == "" {
+		if c.bound == nil {
+			if len(c.pending) == 0 {
+				return nil, nil
+			}
+			if err := c.bind(c.cluster.anyAddr()); err != nil {
+				return nil, err
+			}
+		}
+		reply, err := c.bound.Do("")
+		c.release()
+		return reply, err
+	}
+
+	c.track(commandName)
+	if c.bound != nil {
+		return c.boundDo(commandName, args)
+	}
+	if len(c.pending) == 0 && !c.transaction && isBroadcast(commandName, args) {
+		return c.broadcast(commandName, args)
+	}
+
+	key, ok := commandKey(commandName, args)
+	if !ok && strings.EqualFold(commandName, "MULTI") {
+		// 事务在首个带key的命令时绑定节点
+		c.pending = append(c.pending, clusterCommand{name: commandName, args: args})
+		return "OK", nil
+	}
+	if len(c.pending) > 0 || c.transaction {
+		addr := c.cluster.anyAddr()
+		if ok {
+			addr = c.cluster.addrForSlot(slot(key))
+		}
+		if err := c.bind(addr); err != nil {
+			return nil, err
+		}
+		return c.boundDo(commandName, args)
+	}
+
+	return c.do(key, ok, commandName, args)
+}
+
+// 在绑定的节点上执行命令，已接收全部回复且不在事务中时解除绑定
+func (c *clusterConn) boundDo(commandName string, args []interface{}) (interface{}, error) {
+	reply, err := c.bound.Do(commandName, args...)
+	if !c.transaction {
+		c.release()
+	}
+	return reply, err
+}
+
+// 记录事务状态
+func (c *clusterConn) track(commandName string) {
+	switch strings.ToUpper(commandName) {
+	case "MULTI", "WATCH":
+		c.transaction = true
+	case "EXEC", "DISCARD", "UNWATCH":
+		c.transaction = false
+	}
+}
+
+// 路由并执行命令，处理重定向
+func (c *clusterConn) do(key string, hasKey bool, commandName string, args []interface{}) (interface{}, error) {
+	addr := c.cluster.anyAddr()
+	if hasKey {
+		addr = c.cluster.addrForSlot(slot(key))
+	}
+
+	asking := false
+	for redirects := 0; ; redirects++ {
+		conn := c.cluster.getConn(addr)
+		if asking {
+			_ = conn.Send("ASKING")
+		}
+		reply, err := conn.Do(commandName, args...)
+		conn.Close()
+
+		if err == nil {
+			return reply, nil
+		}
+		if _, ok := err.(redis.Error); !ok {
+			// 连接异常时节点可能已下线，刷新槽位分布
+			c.cluster.refreshAsync()
+			return reply, err
+		}
+		redirect, ok := parseRedirect(err)
+		if !ok || redirects >= c.cluster.config.Cluster.MaxRedirects {
+			return reply, err
+		}
+
+		if redirect.moved {
+			c.cluster.setSlot(redirect.slot, redirect.addr)
+			c.cluster.refreshAsync()
+		}
+		asking = !redirect.moved
+		addr = redirect.addr
+	}
+}
+
+// 在所有主节点上执行命令，返回首个节点的回复，任一节点失败时返回错误
+func (c *clusterConn) broadcast(commandName string, args []interface{}) (interface{}, error) {
+	var (
+		reply   interface{}
+		lastErr error
+	)
+	for i, addr := range c.cluster.masters() {
+		conn := c.cluster.getConn(addr)
+		r, err := conn.Do(commandName, args...)
+		conn.Close()
+		if err != nil {
+			if _, ok := err.(redis.Error); !ok {
+				c.cluster.refreshAsync()
+			}
+			lastErr = err
+			continue
+		}
+		if i == 0 {
+			reply = r
+		}
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return reply, nil
+}
+
+func (c *clusterConn) Send(commandName string, args ...interface{}) error {
+	c.track(commandName)
+	if c.bound == nil {
+		key, ok := commandKey(commandName, args)
+		if !ok {
+			c.pending = append(c.pending, clusterCommand{name: commandName, args: args})
+			return nil
+		}
+		if err := c.bind(c.cluster.addrForSlot(slot(key))); err != nil {
+			return err
+		}
+	}
+	return c.bound.Send(commandName, args...)
+}
+
+func (c *clusterConn) Flush() error {
+	if c.bound == nil {
+		if len(c.pending) == 0 {
+			return nil
+		}
+		if err := c.bind(c.cluster.anyAddr()); err != nil {
+			return err
+		}
+	}
+	return c.bound.Flush()
+}
+
+func (c *clusterConn) Receive() (interface{}, error) {
+	if c.bound == nil {
+		return nil, errors.New("redis cluster connection has no pending reply")
+	}
+	return c.bound.Receive()
+}
+
+// 绑定到节点，并发送暂存的命令
+func (c *clusterConn) bind(addr string) error {
+	c.bound = c.cluster.getConn(addr)
+	pending := c.pending
+	c.pending = nil
+	for _, command := range pending {
+		if err := c.bound.Send(command.name, command.args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 解除绑定，归还节点连接
+func (c *clusterConn) release() {
+	c.pending = nil
+	c.transaction = false
+	if c.bound != nil {
+		c.bound.Close()
+		c.bound = nil
+	}
+}
+
+// 重定向信息
+type redirectInfo struct {
+	// 是否为MOVED，否则为ASK
+	moved bool
+	// 槽位
+	slot int
+	// 目标节点地址
+	addr string
+}
+
+// 解析MOVED/ASK重定向错误
+func parseRedirect(err error) (*redirectInfo, bool) {
+	e, ok := err.(redis.Error)
+	if !ok {
+		return nil, false
+	}
+	fields := strings.Fields(string(e))
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return nil, false
+	}
+	slot, err := strconv.Atoi(fields[1])
+	if err != nil || slot < 0 || slot >= clusterSlots {
+		return nil, false
+	}
+	return &redirectInfo{
+		moved: fields[0] == "MOVED",
+		slot:  slot,
+		addr:  fields[2],
+	}, true
+}
+
+// 不带key的命令
+var _keylessCommands = map[string]bool{
+	"AUTH": true, "CLIENT": true, "CLUSTER": true, "COMMAND": true, "CONFIG": true,
+	"DBSIZE": true, "DISCARD": true, "ECHO": true, "EXEC": true, "FLUSHALL": true,
+	"FLUSHDB": true, "INFO": true, "KEYS": true, "LASTSAVE": true, "MULTI": true,
+	"PING": true, "RANDOMKEY": true, "ROLE": true, "SCAN": true, "SCRIPT": true,
+	"SELECT": true, "TIME": true, "UNWATCH": true,
+}
+
+// 是否需要在所有主节点上执行，SCRIPT LOAD/FLUSH需广播，否则EVALSHA在其他节点返回NOSCRIPT
+func isBroadcast(commandName string, args []interface{}) bool {
+	if !strings.EqualFold(commandName, "SCRIPT") || len(args) == 0 {
+		return false
+	}
+	switch strings.ToUpper(argString(args[0])) {
+	case "LOAD", "FLUSH":
+		return true
+	}
+	return false
+}
+
+// 获取命令的key
+func commandKey(commandName string, args []interface{}) (string, bool) {
+	command := strings.ToUpper(commandName)
+	if _keylessCommands[command] {
+		return "", false
+	}
+
+	switch command {
+	case "EVAL", "EVALSHA":
+		if len(args) < 3 {
+			return "", false
+		}
+		numKeys, err := strconv.Atoi(argString(args[1]))
+		if err != nil || numKeys <= 0 {
+			return "", false
+		}
+		return argString(args[2]), true
+	case "BITOP", "OBJECT", "XINFO", "MEMORY":
+		// 首个参数为操作或子命令，如 OBJECT ENCODING key，不带key的子命令(如HELP、MEMORY STATS)无后续参数
+		if len(args) < 2 {
+			return "", false
+		}
+		return argString(args[1]), true
+	case "XREAD", "XREADGROUP":
+		for i, arg := range args {
+			if strings.EqualFold(argString(arg), "STREAMS") && i+1 < len(args) {
+				return argString(args[i+1]), true
+			}
+		}
+		return "", false
+	}
+
+	if len(args) == 0 {
+		return "", false
+	}
+	return argString(args[0]), true
+}
+
+// 参数转为字符串
+func argString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// 计算key所在槽位，支持{hash tag}
+func slot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % clusterSlots)
+}
+
+// CRC16/XMODEM
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
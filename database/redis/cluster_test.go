@@ -0,0 +1,165 @@
+package redis
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gitlab.shanhai.int/sre/library/base/ctime"
+)
+
+// 模拟集群节点，每个命令均回复全部槽位位于本节点
+func newFakeClusterNode(t *testing.T) *fakeSentinel {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().(*net.TCPAddr)
+	ip := addr.IP.String()
+	s := &fakeSentinel{
+		listener: l,
+		raw:      fmt.Sprintf("*1\r\n*3\r\n:0\r\n:%d\r\n*2\r\n$%d\r\n%s\r\n:%d\r\n", clusterSlots-1, len(ip), ip, addr.Port),
+	}
+	s.serveAll()
+	return s
+}
+
+func TestCrc16(t *testing.T) {
+	cases := []struct {
+		input  string
+		expect uint16
+	}{
+		{"", 0},
+		{"123456789", 0x31C3},
+		{"foo", 0xAF96},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expect, crc16(c.input), c.input)
+	}
+}
+
+func TestSlot(t *testing.T) {
+	cases := []struct {
+		key    string
+		expect int
+	}{
+		{"foo", 12182},
+		{"123456789", 0x31C3},
+		// 只计算hash tag中的内容
+		{"{user1000}.following", slot("user1000")},
+		{"{user1000}.followers", slot("user1000")},
+		{"foo{bar}{zap}", slot("bar")},
+		// 空hash tag或不完整时计算整个key
+		{"foo{}{bar}", int(crc16("foo{}{bar}") % clusterSlots)},
+		{"foo{bar", int(crc16("foo{bar") % clusterSlots)},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expect, slot(c.key), c.key)
+	}
+}
+
+func TestParseRedirect(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		expect *redirectInfo
+	}{
+		{"moved", redis.Error("MOVED 3999 127.0.0.1:6381"), &redirectInfo{moved: true, slot: 3999, addr: "127.0.0.1:6381"}},
+		{"ask", redis.Error("ASK 3999 127.0.0.1:6381"), &redirectInfo{moved: false, slot: 3999, addr: "127.0.0.1:6381"}},
+		{"other redis error", redis.Error("ERR unknown command"), nil},
+		{"invalid slot", redis.Error("MOVED 16384 127.0.0.1:6381"), nil},
+		{"missing address", redis.Error("MOVED 3999"), nil},
+		{"network error", errors.New("MOVED 3999 127.0.0.1:6381"), nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			redirect, ok := parseRedirect(c.err)
+			assert.Equal(t, c.expect != nil, ok)
+			assert.Equal(t, c.expect, redirect)
+		})
+	}
+}
+
+func TestCommandKey(t *testing.T) {
+	cases := []struct {
+		command string
+		args    []interface{}
+		key     string
+		ok      bool
+	}{
+		{"GET", []interface{}{"foo"}, "foo", true},
+		{"set", []interface{}{[]byte("foo"), "bar"}, "foo", true},
+		{"PING", nil, "", false},
+		{"INFO", []interface{}{"server"}, "", false},
+		{"EVAL", []interface{}{"return 1", 1, "foo"}, "foo", true},
+		{"EVALSHA", []interface{}{"sha", "0"}, "", false},
+		{"BITOP", []interface{}{"AND", "dest", "a", "b"}, "dest", true},
+		{"XREAD", []interface{}{"COUNT", 2, "STREAMS", "stream", "0"}, "stream", true},
+		{"XREADGROUP", []interface{}{"GROUP", "g", "c", "STREAMS", "stream", ">"}, "stream", true},
+		// 首个参数为子命令
+		{"OBJECT", []interface{}{"ENCODING", "foo"}, "foo", true},
+		{"OBJECT", []interface{}{"HELP"}, "", false},
+		{"XINFO", []interface{}{"STREAM", "stream"}, "stream", true},
+		{"MEMORY", []interface{}{"USAGE", "foo", "SAMPLES", 5}, "foo", true},
+		{"MEMORY", []interface{}{"STATS"}, "", false},
+		{"SCRIPT", []interface{}{"LOAD", "return 1"}, "", false},
+	}
+	for _, c := range cases {
+		key, ok := commandKey(c.command, c.args)
+		assert.Equal(t, c.ok, ok, c.command)
+		assert.Equal(t, c.key, key, c.command)
+	}
+}
+
+func TestIsBroadcast(t *testing.T) {
+	assert.True(t, isBroadcast("SCRIPT", []interface{}{"LOAD", "return 1"}))
+	assert.True(t, isBroadcast("script", []interface{}{"flush"}))
+	assert.False(t, isBroadcast("SCRIPT", []interface{}{"EXISTS", "sha"}))
+	assert.False(t, isBroadcast("SCRIPT", nil))
+	assert.False(t, isBroadcast("EVALSHA", []interface{}{"sha", 0}))
+}
+
+func TestCluster_Refresh(t *testing.T) {
+	// 首个初始节点不可用
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := l.Addr().(*net.TCPAddr)
+	l.Close()
+	node := newFakeClusterNode(t)
+	defer node.listener.Close()
+
+	cfg := &Config{
+		Proto: "tcp",
+		PoolConfig: &PoolConfig{
+			ReadTimeout:    ctime.Duration(time.Second),
+			WriteTimeout:   ctime.Duration(time.Second),
+			ConnectTimeout: ctime.Duration(time.Second),
+		},
+		Cluster: &ClusterConfig{
+			Endpoints: []*EndpointConfig{
+				{Address: dead.IP.String(), Port: dead.Port},
+				node.endpoint(),
+			},
+		},
+	}
+	c := newCluster(cfg)
+	defer c.close()
+	// 依次尝试各初始节点
+	assert.Nil(t, c.refresh())
+	assert.Equal(t, node.endpoint().String(), c.addrForSlot(0))
+	assert.Equal(t, []string{node.endpoint().String()}, c.masters())
+
+	// 初始节点均不可用时返回错误
+	cfg.Cluster.Endpoints = cfg.Cluster.Endpoints[:1]
+	assert.NotNil(t, newCluster(cfg).refresh())
+	cfg.Mode = ModeCluster
+	assert.Panics(t, func() {
+		NewPool(cfg)
+	})
+}
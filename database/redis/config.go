@@ -2,9 +2,11 @@ package redis
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"gitlab.shanhai.int/sre/library/base/ctime"
+	"gitlab.shanhai.int/sre/library/base/hook"
 	render "gitlab.shanhai.int/sre/library/base/logrender"
 )
 
@@ -12,6 +14,19 @@ const (
 	DefaultReadTimeout    = time.Second * 3
 	DefaultWriteTimeout   = time.Second * 3
 	DefaultConnectTimeout = time.Second * 10
+	// 集群模式默认最大重定向次数
+	DefaultMaxRedirects = 3
+	// 哨兵模式默认刷新主节点地址间隔
+	DefaultSentinelRefreshInterval = time.Second
+)
+
+const (
+	// 单机模式
+	ModeSingle = "single"
+	// 哨兵模式
+	ModeSentinel = "sentinel"
+	// 集群模式
+	ModeCluster = "cluster"
 )
 
 // 连接池配置
@@ -39,27 +54,73 @@ type EndpointConfig struct {
 	Port    int    `yaml:"port"`
 }
 
+// 获取连接地址
+func (c *EndpointConfig) String() string {
+	return fmt.Sprintf("%s:%d", c.Address, c.Port)
+}
+
+// 哨兵配置
+type SentinelConfig struct {
+	// 主节点名
+	MasterName string `yaml:"masterName"`
+	// 哨兵地址
+	Endpoints []*EndpointConfig `yaml:"endpoints"`
+	// 哨兵校验密码
+	Auth string `yaml:"auth"`
+	// 定时通过哨兵刷新主节点地址的间隔，默认1s
+	RefreshInterval ctime.Duration `yaml:"refreshInterval"`
+}
+
+// 集群配置
+type ClusterConfig struct {
+	// 初始节点地址，用于获取槽位分布
+	Endpoints []*EndpointConfig `yaml:"endpoints"`
+	// 最大重定向次数
+	MaxRedirects int `yaml:"maxRedirects"`
+}
+
 // 配置文件
 type Config struct {
 	// 连接池配置
 	*PoolConfig `yaml:",inline"`
 
+	// 模式，为空时为单机模式
+	Mode string `yaml:"mode"`
 	// 连接协议
 	Proto string `yaml:"proto"`
-	// 数据库名
+	// 数据库名，集群模式只支持0
 	DB int `yaml:"db"`
-	// 连接地址
+	// 连接地址，单机模式使用
 	Endpoint *EndpointConfig `yaml:"endpoint"`
+	// 哨兵配置，哨兵模式使用
+	Sentinel *SentinelConfig `yaml:"sentinel"`
+	// 集群配置，集群模式使用
+	Cluster *ClusterConfig `yaml:"cluster"`
 	// 校验密码
 	Auth string `yaml:"auth"`
 	// 连接完整生命周期时间
 	MaxConnLifetime ctime.Duration `yaml:"maxConnLifetime"`
+	// 慢操作配置，按命令名称匹配阈值
+	Slow *hook.SlowConfig `yaml:"slow"`
 
 	// 日志配置
 	*render.Config `yaml:",inline"`
 }
 
 // 获取 Redis 连接地址
+//
+//	哨兵模式为主节点名，集群模式为初始节点地址
 func (c *Config) GetEndpoint() string {
-	return fmt.Sprintf("%s:%d", c.Endpoint.Address, c.Endpoint.Port)
+	switch c.Mode {
+	case ModeSentinel:
+		return fmt.Sprintf("sentinel://%s", c.Sentinel.MasterName)
+	case ModeCluster:
+		addrs := make([]string, len(c.Cluster.Endpoints))
+		for i, endpoint := range c.Cluster.Endpoints {
+			addrs[i] = endpoint.String()
+		}
+		return fmt.Sprintf("cluster://%s", strings.Join(addrs, ","))
+	default:
+		return c.Endpoint.String()
+	}
 }
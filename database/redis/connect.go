@@ -29,7 +29,9 @@ func (c *Conn) GetOriginConnect() redis.Conn {
 func (c *Conn) Do(ctx context.Context, commandName string, args ...interface{}) (reply interface{}, err error) {
 	ctx, hk := c.before(ctx, "Do", commandName, args)
 
-	reply, err = c.Conn.Do(commandName, args...)
+	if err = hk.Err(); err == nil {
+		reply, err = c.Conn.Do(commandName, args...)
+	}
 
 	c.after(hk, err, reply)
 
@@ -40,7 +42,10 @@ func (c *Conn) Do(ctx context.Context, commandName string, args ...interface{})
 func (c *Conn) Flush(ctx context.Context) error {
 	ctx, hk := c.before(ctx, "Flush", "pipeline::flush", nil)
 
-	err := c.Conn.Flush()
+	err := hk.Err()
+	if err == nil {
+		err = c.Conn.Flush()
+	}
 
 	c.after(hk, err, nil)
 
@@ -51,7 +56,10 @@ func (c *Conn) Flush(ctx context.Context) error {
 func (c *Conn) Send(ctx context.Context, commandName string, args ...interface{}) error {
 	ctx, hk := c.before(ctx, "Send", fmt.Sprintf("pipeline::send::%s", commandName), args)
 
-	err := c.Conn.Send(commandName, args...)
+	err := hk.Err()
+	if err == nil {
+		err = c.Conn.Send(commandName, args...)
+	}
 
 	c.after(hk, err, nil)
 
@@ -62,7 +70,9 @@ func (c *Conn) Send(ctx context.Context, commandName string, args ...interface{}
 func (c *Conn) Receive(ctx context.Context) (reply interface{}, err error) {
 	ctx, hk := c.before(ctx, "Receive", "", nil)
 
-	reply, err = c.Conn.Receive()
+	if err = hk.Err(); err == nil {
+		reply, err = c.Conn.Receive()
+	}
 
 	c.after(hk, err, reply)
 
@@ -84,7 +94,7 @@ func (c *Conn) before(ctx context.Context, funcName, commandName string,
 	commandArgs []interface{}) (context.Context, *hook.Hook) {
 
 	hk := c.manager.CreateHook(ctx).
-		AddArg("endpoint", c.pool.config.GetEndpoint()).
+		AddArg("endpoint", c.pool.endpoint).
 		AddArg(render.StartTimeArgKey, time.Now()).
 		AddArg(render.SourceArgKey, runtime.GetDefaultFilterCallers()).
 		AddArg("func_name", funcName).
@@ -98,11 +108,12 @@ func (c *Conn) before(ctx context.Context, funcName, commandName string,
 // 操作后注入
 func (c *Conn) after(hk *hook.Hook, err error, reply interface{}) {
 	endTime := time.Now()
-	duration := endTime.Sub(hk.Arg(render.StartTimeArgKey).(time.Time))
+	duration := endTime.Sub(hk.Event().StartTime)
 
 	hk = hk.AddArg(render.EndTimeArgKey, endTime).
 		AddArg(render.DurationArgKey, duration).
 		AddArg("replay", reply).
 		AddArg(render.ErrorArgKey, err).
 		ProcessAfterHook()
+	hk.Release()
 }
@@ -56,3 +56,70 @@ func ExampleNewPool() {
 	}
 	fmt.Printf("%v\n", reply)
 }
+
+func ExampleNewPool_sentinel() {
+	c := Config{
+		PoolConfig: &PoolConfig{
+			Active: 10,
+			Idle:   10,
+			Wait:   true,
+		},
+		Mode:  ModeSentinel,
+		Proto: "tcp",
+		Sentinel: &SentinelConfig{
+			MasterName: "mymaster",
+			Endpoints: []*EndpointConfig{
+				{Address: "10.0.0.1", Port: 26379},
+				{Address: "10.0.0.2", Port: 26379},
+			},
+		},
+		Auth: "123456",
+	}
+
+	p := NewPool(&c)
+
+	con := p.Get()
+	defer con.Close()
+	reply, err := con.Do(context.Background(), "set", "key", "value")
+	if err != nil {
+		return
+	}
+	fmt.Printf("%v\n", reply)
+}
+
+func ExampleNewPool_cluster() {
+	c := Config{
+		PoolConfig: &PoolConfig{
+			Active: 10,
+			Idle:   10,
+			Wait:   true,
+		},
+		Mode:  ModeCluster,
+		Proto: "tcp",
+		Cluster: &ClusterConfig{
+			Endpoints: []*EndpointConfig{
+				{Address: "10.0.0.1", Port: 6379},
+				{Address: "10.0.0.2", Port: 6379},
+			},
+		},
+	}
+
+	p := NewPool(&c)
+
+	err := p.WrapDo(func(con *Conn) error {
+		// 事务中的key需位于同一节点
+		_ = con.Send(context.Background(), "MULTI")
+		_ = con.Send(context.Background(), "INCR", "{user:1}:count")
+		_ = con.Send(context.Background(), "EXPIRE", "{user:1}:count", 60)
+		reply, err := con.Do(context.Background(), "EXEC")
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%v\n", reply)
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("%s\n", err)
+	}
+}
@@ -22,6 +22,7 @@ const (
 // 新建钩子管理器
 func NewHookManager(renderConfig *render.Config) *hook.Manager {
 	return hook.NewManager().
+		SetComponent(hook.ComponentRedis).
 		RegisterLogHook(renderConfig, patternMap).
 		RegisterHook(func(hk *hook.Hook) {
 			args := hk.Args()
@@ -83,6 +84,11 @@ func NewHookManager(renderConfig *render.Config) *hook.Manager {
 		})
 }
 
+// 慢操作按命令名称匹配阈值
+func slowCommand(hk *hook.Hook) string {
+	return commandName(hk.Args()).StringValue()
+}
+
 // 渲染模版
 var patternMap = map[string]render.PatternFunc{
 	"E": endpoint,
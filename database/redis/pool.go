@@ -11,6 +11,12 @@ type Pool struct {
 	*redis.Pool
 	// 配置文件
 	config *Config
+	// 连接地址，用于日志及统计
+	endpoint string
+	// 哨兵，哨兵模式使用
+	sentinel *sentinel
+	// 集群，集群模式使用
+	cluster *cluster
 	// 钩子管理器
 	manager *hook.Manager
 }
@@ -36,5 +42,11 @@ func (p *Pool) WrapDo(doFunction func(con *Conn) error) error {
 
 func (p *Pool) Close() (err error) {
 	p.manager.Close()
+	if p.sentinel != nil {
+		p.sentinel.close()
+	}
+	if p.cluster != nil {
+		err = p.cluster.close()
+	}
 	return
 }
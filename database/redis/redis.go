@@ -2,7 +2,6 @@ package redis
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
@@ -16,8 +15,33 @@ func NewPool(cfg *Config) *Pool {
 	if cfg == nil {
 		panic("redis config is nil")
 	}
-	if cfg.Proto == "" || cfg.Endpoint == nil {
-		panic("redis must be set proto/addr")
+	if cfg.Mode == "" {
+		cfg.Mode = ModeSingle
+	}
+	switch cfg.Mode {
+	case ModeSingle:
+		if cfg.Proto == "" || cfg.Endpoint == nil {
+			panic("redis must be set proto/addr")
+		}
+	case ModeSentinel:
+		if cfg.Proto == "" || cfg.Sentinel == nil || cfg.Sentinel.MasterName == "" || len(cfg.Sentinel.Endpoints) == 0 {
+			panic("redis sentinel must be set proto/masterName/endpoints")
+		}
+		if cfg.Sentinel.RefreshInterval <= 0 {
+			cfg.Sentinel.RefreshInterval = ctime.Duration(DefaultSentinelRefreshInterval)
+		}
+	case ModeCluster:
+		if cfg.Proto == "" || cfg.Cluster == nil || len(cfg.Cluster.Endpoints) == 0 {
+			panic("redis cluster must be set proto/endpoints")
+		}
+		if cfg.DB != 0 {
+			panic("redis cluster only support db 0")
+		}
+		if cfg.Cluster.MaxRedirects <= 0 {
+			cfg.Cluster.MaxRedirects = DefaultMaxRedirects
+		}
+	default:
+		panic(errors.Errorf("redis mode %s is not supported", cfg.Mode))
 	}
 
 	if cfg.Config == nil {
@@ -32,6 +56,9 @@ func NewPool(cfg *Config) *Pool {
 	if cfg.Config.OutFile == "" {
 		cfg.Config.OutFile = _infoFile
 	}
+	if cfg.PoolConfig == nil {
+		cfg.PoolConfig = &PoolConfig{}
+	}
 	if cfg.ConnectTimeout == 0 {
 		cfg.ConnectTimeout = ctime.Duration(DefaultConnectTimeout)
 	}
@@ -43,52 +70,32 @@ func NewPool(cfg *Config) *Pool {
 	}
 
 	pool := &Pool{
-		Pool: &redis.Pool{
-			MaxIdle:         cfg.PoolConfig.Idle,
-			IdleTimeout:     time.Duration(cfg.PoolConfig.IdleTimeout),
-			MaxActive:       cfg.PoolConfig.Active,
-			Wait:            cfg.PoolConfig.Wait,
-			MaxConnLifetime: time.Duration(cfg.MaxConnLifetime),
-			Dial: func() (redis.Conn, error) {
-				endpoint := fmt.Sprintf("%s:%d", cfg.Endpoint.Address, cfg.Endpoint.Port)
-				c, err := redis.Dial(
-					cfg.Proto,
-					endpoint,
-					redis.DialConnectTimeout(time.Duration(cfg.ConnectTimeout)),
-					redis.DialReadTimeout(time.Duration(cfg.ReadTimeout)),
-					redis.DialWriteTimeout(time.Duration(cfg.WriteTimeout)),
-				)
-				if err != nil {
-					return nil, err
-				}
-
-				if cfg.Auth != "" {
-					if _, err := c.Do("AUTH", cfg.Auth); err != nil {
-						c.Close()
-						return nil, err
-					}
-				}
-
-				if cfg.DB != 0 {
-					if _, err := c.Do("SELECT", cfg.DB); err != nil {
-						c.Close()
-						return nil, err
-					}
-					return c, nil
-				}
-
-				return c, nil
-			},
-			TestOnBorrow: func(c redis.Conn, t time.Time) error {
-				if time.Since(t) < time.Duration(cfg.PoolConfig.CheckTime) {
-					return nil
-				}
-				_, err := c.Do("PING")
-				return err
-			},
-		},
-		config:  cfg,
-		manager: NewHookManager(cfg.Config),
+		Pool:     newRedisPool(cfg, nil),
+		config:   cfg,
+		endpoint: cfg.GetEndpoint(),
+		manager:  NewHookManager(cfg.Config).RegisterSlowHook(cfg.Slow, slowCommand),
+	}
+	switch cfg.Mode {
+	case ModeSingle:
+		pool.Pool.Dial = func() (redis.Conn, error) {
+			return dial(cfg, cfg.Endpoint.String(), cfg.DB)
+		}
+	case ModeSentinel:
+		s := newSentinel(cfg)
+		pool.Pool.Dial = s.dial
+		pool.Pool.TestOnBorrow = s.testOnBorrow
+		pool.sentinel = s
+	case ModeCluster:
+		c := newCluster(cfg)
+		// 依次尝试各初始节点，均失败时关闭已创建的节点连接池
+		if err := c.refresh(); err != nil {
+			c.close()
+			panic(errors.Wrap(err, "redis cluster slots error"))
+		}
+		pool.Pool.Dial = c.dial
+		// 集群连接不直接持有网络连接，由各节点连接池检查可用性
+		pool.Pool.TestOnBorrow = nil
+		pool.cluster = c
 	}
 
 	err := pool.WrapDo(func(con *Conn) error {
@@ -99,8 +106,60 @@ func NewPool(cfg *Config) *Pool {
 		return nil
 	})
 	if err != nil {
+		// 停止哨兵定时刷新及关闭集群节点连接池
+		pool.Close()
 		panic(errors.Wrap(err, "redis health check error"))
 	}
 
 	return pool
 }
+
+// 新建redigo连接池
+func newRedisPool(cfg *Config, dialFunc func() (redis.Conn, error)) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:         cfg.PoolConfig.Idle,
+		IdleTimeout:     time.Duration(cfg.PoolConfig.IdleTimeout),
+		MaxActive:       cfg.PoolConfig.Active,
+		Wait:            cfg.PoolConfig.Wait,
+		MaxConnLifetime: time.Duration(cfg.MaxConnLifetime),
+		Dial:            dialFunc,
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			if time.Since(t) < time.Duration(cfg.PoolConfig.CheckTime) {
+				return nil
+			}
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+}
+
+// 连接指定地址，并完成校验及选择数据库
+func dial(cfg *Config, address string, db int) (redis.Conn, error) {
+	c, err := redis.Dial(
+		cfg.Proto,
+		address,
+		redis.DialConnectTimeout(time.Duration(cfg.ConnectTimeout)),
+		redis.DialReadTimeout(time.Duration(cfg.ReadTimeout)),
+		redis.DialWriteTimeout(time.Duration(cfg.WriteTimeout)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Auth != "" {
+		if _, err := c.Do("AUTH", cfg.Auth); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	if db != 0 {
+		if _, err := c.Do("SELECT", db); err != nil {
+			c.Close()
+			return nil, err
+		}
+		return c, nil
+	}
+
+	return c, nil
+}
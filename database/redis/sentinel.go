@@ -0,0 +1,195 @@
+package redis
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// 哨兵
+//
+//	新建连接时及定时通过哨兵获取主节点地址，哨兵均不可用时使用缓存的地址，主从切换后旧主节点上的连接在借出时丢弃
+type sentinel struct {
+	// 配置文件
+	config *Config
+
+	// 读写锁
+	mu sync.RWMutex
+	// 当前主节点地址
+	masterAddr string
+
+	// 关闭时停止定时刷新
+	exit chan struct{}
+	// 保证只关闭一次
+	closeOnce sync.Once
+}
+
+// 新建哨兵，并开启定时刷新主节点地址
+func newSentinel(cfg *Config) *sentinel {
+	s := &sentinel{
+		config: cfg,
+		exit:   make(chan struct{}),
+	}
+	go s.daemon()
+	return s
+}
+
+// 定时刷新主节点地址，避免主从切换后连接池中的连接仍访问旧主节点
+func (s *sentinel) daemon() {
+	ticker := time.NewTicker(time.Duration(s.config.Sentinel.RefreshInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// 查询失败时保留原地址
+			_, _ = s.discover()
+		case <-s.exit:
+			return
+		}
+	}
+}
+
+// 停止定时刷新
+func (s *sentinel) close() {
+	s.closeOnce.Do(func() {
+		close(s.exit)
+	})
+}
+
+// 当前主节点地址
+func (s *sentinel) currentMaster() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.masterAddr
+}
+
+// 通过哨兵获取主节点地址，依次尝试各哨兵
+func (s *sentinel) discover() (string, error) {
+	var lastErr error
+	for _, endpoint := range s.config.Sentinel.Endpoints {
+		addr, err := s.queryMaster(endpoint.String())
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		s.mu.Lock()
+		s.masterAddr = addr
+		s.mu.Unlock()
+		return addr, nil
+	}
+	return "", errors.Wrapf(lastErr, "redis sentinel get master %s error", s.config.Sentinel.MasterName)
+}
+
+// 向单个哨兵查询主节点地址
+func (s *sentinel) queryMaster(sentinelAddr string) (string, error) {
+	c, err := redis.Dial(
+		s.config.Proto,
+		sentinelAddr,
+		redis.DialConnectTimeout(time.Duration(s.config.ConnectTimeout)),
+		redis.DialReadTimeout(time.Duration(s.config.ReadTimeout)),
+		redis.DialWriteTimeout(time.Duration(s.config.WriteTimeout)),
+	)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	if s.config.Sentinel.Auth != "" {
+		if _, err := c.Do("AUTH", s.config.Sentinel.Auth); err != nil {
+			return "", err
+		}
+	}
+
+	reply, err := redis.Strings(c.Do("SENTINEL", "get-master-addr-by-name", s.config.Sentinel.MasterName))
+	if err != nil {
+		return "", err
+	}
+	if len(reply) != 2 {
+		return "", errors.Errorf("sentinel %s reply of master address is invalid", sentinelAddr)
+	}
+	return net.JoinHostPort(reply[0], reply[1]), nil
+}
+
+// 新建主节点连接
+func (s *sentinel) dial() (redis.Conn, error) {
+	addr, err := s.discover()
+	if err != nil {
+		// 哨兵均不可用时使用缓存的主节点地址，主从切换由定时刷新感知
+		if addr = s.currentMaster(); addr == "" {
+			return nil, err
+		}
+	}
+
+	c, err := dial(s.config, addr, s.config.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	// 哨兵尚未感知主从切换时，返回的地址可能已不是主节点
+	role, err := redis.Values(c.Do("ROLE"))
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if len(role) == 0 || fmt.Sprintf("%s", role[0]) != "master" {
+		c.Close()
+		return nil, errors.Errorf("redis %s is not master", addr)
+	}
+
+	return &sentinelConn{Conn: c, addr: addr}, nil
+}
+
+// 借出连接时检查，连接的节点已不是主节点时丢弃
+func (s *sentinel) testOnBorrow(c redis.Conn, t time.Time) error {
+	if sc, ok := c.(*sentinelConn); ok && sc.addr != s.currentMaster() {
+		return errors.Errorf("redis %s is not master any more", sc.addr)
+	}
+	if time.Since(t) < time.Duration(s.config.PoolConfig.CheckTime) {
+		return nil
+	}
+	_, err := c.Do("PING")
+	return err
+}
+
+// 哨兵模式连接
+//
+//	主从切换后，旧主节点返回READONLY错误，此时标记连接不可用，归还时由连接池丢弃
+type sentinelConn struct {
+	redis.Conn
+	// 节点地址
+	addr string
+	// 连接不可用的原因
+	err error
+}
+
+func (c *sentinelConn) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.Conn.Err()
+}
+
+func (c *sentinelConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	reply, err := c.Conn.Do(commandName, args...)
+	c.checkError(err)
+	return reply, err
+}
+
+func (c *sentinelConn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	c.checkError(err)
+	return reply, err
+}
+
+// 检查是否为只读错误
+func (c *sentinelConn) checkError(err error) {
+	if e, ok := err.(redis.Error); ok && strings.HasPrefix(string(e), "READONLY") {
+		c.err = errors.Errorf("redis %s is readonly", c.addr)
+	}
+}
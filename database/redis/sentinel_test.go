@@ -0,0 +1,161 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.shanhai.int/sre/library/base/ctime"
+)
+
+// 模拟哨兵，回复当前主节点地址
+type fakeSentinel struct {
+	listener net.Listener
+	mu       sync.Mutex
+	host     string
+	port     string
+	// 固定回复，不为空时替代主节点地址，用于模拟其他节点
+	raw string
+}
+
+func newFakeSentinel(t *testing.T, host, port string) *fakeSentinel {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSentinel{listener: l, host: host, port: port}
+	s.serveAll()
+	return s
+}
+
+// 接受连接并处理
+func (s *fakeSentinel) serveAll() {
+	l := s.listener
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+}
+
+// 每个命令均回复主节点地址
+func (s *fakeSentinel) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(line[1 : len(line)-2])
+		for i := 0; i < n*2; i++ {
+			if _, err := r.ReadString('\n'); err != nil {
+				return
+			}
+		}
+		s.mu.Lock()
+		reply := s.raw
+		if reply == "" {
+			reply = fmt.Sprintf("*2\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(s.host), s.host, len(s.port), s.port)
+		}
+		s.mu.Unlock()
+		if _, err := c.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// 主从切换
+func (s *fakeSentinel) failover(port string) {
+	s.mu.Lock()
+	s.port = port
+	s.mu.Unlock()
+}
+
+func (s *fakeSentinel) endpoint() *EndpointConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return &EndpointConfig{Address: addr.IP.String(), Port: addr.Port}
+}
+
+func TestSentinel_Refresh(t *testing.T) {
+	fake := newFakeSentinel(t, "10.0.0.1", "6379")
+	defer fake.listener.Close()
+
+	s := newSentinel(&Config{
+		Proto: "tcp",
+		PoolConfig: &PoolConfig{
+			CheckTime:      ctime.Duration(time.Hour),
+			ReadTimeout:    ctime.Duration(time.Second),
+			WriteTimeout:   ctime.Duration(time.Second),
+			ConnectTimeout: ctime.Duration(time.Second),
+		},
+		Sentinel: &SentinelConfig{
+			MasterName:      "mymaster",
+			Endpoints:       []*EndpointConfig{fake.endpoint()},
+			RefreshInterval: ctime.Duration(10 * time.Millisecond),
+		},
+	})
+	defer s.close()
+
+	addr, err := s.discover()
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1:6379", addr)
+	conn := &sentinelConn{addr: addr}
+	assert.Nil(t, s.testOnBorrow(conn, time.Now()))
+
+	// 定时刷新后，旧主节点上的连接借出时丢弃
+	fake.failover("6380")
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, "10.0.0.1:6380", s.currentMaster())
+	assert.NotNil(t, s.testOnBorrow(conn, time.Now()))
+}
+
+func TestSentinel_DialFallback(t *testing.T) {
+	// 模拟主节点，ROLE命令回复master
+	master := newFakeSentinel(t, "master", "0")
+	defer master.listener.Close()
+	masterEndpoint := master.endpoint()
+	fake := newFakeSentinel(t, masterEndpoint.Address, strconv.Itoa(masterEndpoint.Port))
+
+	s := newSentinel(&Config{
+		Proto: "tcp",
+		PoolConfig: &PoolConfig{
+			ReadTimeout:    ctime.Duration(time.Second),
+			WriteTimeout:   ctime.Duration(time.Second),
+			ConnectTimeout: ctime.Duration(time.Second),
+		},
+		Sentinel: &SentinelConfig{
+			MasterName:      "mymaster",
+			Endpoints:       []*EndpointConfig{fake.endpoint()},
+			RefreshInterval: ctime.Duration(time.Hour),
+		},
+	})
+	defer s.close()
+
+	c, err := s.dial()
+	assert.Nil(t, err)
+	c.Close()
+
+	// 哨兵均不可用时使用缓存的主节点地址
+	fake.listener.Close()
+	c, err = s.dial()
+	assert.Nil(t, err)
+	assert.Equal(t, masterEndpoint.String(), c.(*sentinelConn).addr)
+	c.Close()
+
+	// 无缓存地址时返回错误
+	s.mu.Lock()
+	s.masterAddr = ""
+	s.mu.Unlock()
+	_, err = s.dial()
+	assert.NotNil(t, err)
+}
@@ -81,6 +81,10 @@ func (c *qtCallBack) before(scope *gorm.Scope, operation string) {
 		AddArg("level", "sql").
 		AddArg("operation", operation).
 		ProcessPreHook()
+	// 前置钩子返回错误时，gorm会跳过本次操作并返回该错误
+	if err := hk.Err(); err != nil {
+		scope.Err(err)
+	}
 
 	scope.Set(HookStoreKey, hk)
 	scope.Set(ContextStoreKey, hk.Context())
@@ -95,7 +99,7 @@ func (c *qtCallBack) after(scope *gorm.Scope, operation string) {
 	hk := hkValue.(*hook.Hook)
 
 	endTime := time.Now()
-	duration := endTime.Sub(hk.Arg(render.StartTimeArgKey).(time.Time))
+	duration := endTime.Sub(hk.Event().StartTime)
 	hk.AddArg(render.EndTimeArgKey, endTime).
 		AddArg(render.DurationArgKey, duration).
 		AddArg("table", scope.TableName()).
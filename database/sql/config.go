@@ -2,6 +2,7 @@ package sql
 
 import (
 	"gitlab.shanhai.int/sre/library/base/ctime"
+	"gitlab.shanhai.int/sre/library/base/hook"
 	render "gitlab.shanhai.int/sre/library/base/logrender"
 )
 
@@ -37,6 +38,8 @@ type Config struct {
 	ExecTimeout ctime.Duration `yaml:"execTimeout"`
 	// 事务超时时间
 	TranTimeout ctime.Duration `yaml:"tranTimeout"`
+	// 慢操作配置，按操作类型匹配阈值，如 SELECT、UPDATE
+	Slow *hook.SlowConfig `yaml:"slow"`
 
 	// 日志配置
 	*render.Config `yaml:",inline"`
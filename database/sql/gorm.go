@@ -203,7 +203,7 @@ func connectGORM(c *Config, dsnConfig *DSNConfig) (*gorm.DB, error) {
 	// todo:超时时间暂时没有配置
 	d.DB().SetConnMaxLifetime(time.Duration(c.IdleTimeout))
 
-	RegisterCustomCallbacks(d, NewHookManager(c.Config, dsnConfig))
+	RegisterCustomCallbacks(d, NewHookManager(c.Config, dsnConfig).RegisterSlowHook(c.Slow, slowCommand))
 
 	return d, nil
 }
@@ -30,6 +30,7 @@ func concatDataSourceName(dsnConfig *DSNConfig) string {
 // 钩子管理器
 func NewHookManager(renderConfig *render.Config, dsnConfig *DSNConfig) *hook.Manager {
 	return hook.NewManager().
+		SetComponent(hook.ComponentGorm).
 		AddArg("dsn", concatDataSourceName(dsnConfig)).
 		RegisterLogHook(renderConfig, patternMap).
 		RegisterHook(func(hk *hook.Hook) {
@@ -86,6 +87,11 @@ func NewHookManager(renderConfig *render.Config, dsnConfig *DSNConfig) *hook.Man
 		})
 }
 
+// 慢操作按操作类型匹配阈值
+func slowCommand(hk *hook.Hook) string {
+	return operation(hk.Args()).StringValue()
+}
+
 // 渲染模版
 var patternMap = map[string]render.PatternFunc{
 	"T": render.PatternEndTime,
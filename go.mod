@@ -6,6 +6,7 @@ require (
 	github.com/BurntSushi/toml v0.3.1
 	github.com/Shopify/sarama v1.24.1
 	github.com/Shopify/toxiproxy v2.1.4+incompatible
+	github.com/aws/aws-sdk-go v1.34.28
 	github.com/cenkalti/backoff v2.2.1+incompatible
 	github.com/codahale/hdrhistogram v0.9.0 // indirect
 	github.com/coreos/etcd v3.3.24+incompatible
@@ -19,6 +20,7 @@ require (
 	github.com/gorilla/mux v1.7.3
 	github.com/jinzhu/gorm v1.9.16
 	github.com/json-iterator/go v1.1.9
+	github.com/klauspost/compress v1.9.5
 	github.com/opentracing/opentracing-go v1.1.0
 	github.com/openzipkin-contrib/zipkin-go-opentracing v0.4.3
 	github.com/openzipkin/zipkin-go v0.2.2
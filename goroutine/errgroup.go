@@ -2,16 +2,19 @@ package goroutine
 
 import (
 	"context"
-	"fmt"
+	"runtime/debug"
 	"sync"
 	"time"
 
+	"github.com/cenkalti/backoff"
 	pkgErrors "github.com/pkg/errors"
 	uuid "github.com/satori/go.uuid"
+	_context "gitlab.shanhai.int/sre/library/base/context"
 	"gitlab.shanhai.int/sre/library/base/hook"
 	render "gitlab.shanhai.int/sre/library/base/logrender"
 	"gitlab.shanhai.int/sre/library/base/runtime"
 	"gitlab.shanhai.int/sre/library/net/errcode"
+	"gitlab.shanhai.int/sre/library/net/sentry"
 )
 
 const (
@@ -49,21 +52,30 @@ type ErrGroup struct {
 	uuid string
 	// 当前组内协程字典，负责存储每个协程相关信息
 	goroutineSet sync.Map
-
-	// 保证设置worker只执行一次
-	workerOnce sync.Once
-	// worker管道
-	workerChan chan WorkerInfo
-	// worker满时是否等待
-	workerWait bool
+	// 按启动顺序存储的协程信息
+	spans []*SpanInfo
+	// 运行中的协程数
+	running int
+	// 是否已Wait，之后启动新协程且无协程运行时清空上一批协程信息
+	waited bool
+	// 协程信息锁，协程启动后对协程信息的修改均需加锁
+	spansMu sync.Mutex
+
+	// 聚合错误的错误码，不为空时Wait返回所有协程的错误
+	aggregateCode errcode.Codes
+
+	// 执行器，为空时不限制协程数
+	executor *Executor
+	// 执行器满时是否等待
+	executorWait bool
 }
 
 // 协程组等待
 func (g *ErrGroup) Wait() error {
 	g.wg.Wait()
-	if g.workerChan != nil {
-		close(g.workerChan)
-	}
+	g.spansMu.Lock()
+	g.waited = true
+	g.spansMu.Unlock()
 	if g.mode == Cancel {
 		g.CallCancel()
 	}
@@ -78,10 +90,44 @@ func (g *ErrGroup) CallCancel() {
 }
 
 // 获取error
+//
+//	聚合错误模式下返回包含所有协程错误的errcode.Group
 func (g *ErrGroup) Error() error {
+	if g.aggregateCode != nil {
+		return g.aggregateError()
+	}
 	return g.err
 }
 
+// 聚合所有协程的错误，按启动顺序排列
+func (g *ErrGroup) aggregateError() error {
+	errs := make([]error, 0)
+	for _, info := range g.Results() {
+		if info.Error != nil {
+			errs = append(errs, info.Error)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errcode.NewGroup(g.aggregateCode).AddChildren(errs...)
+}
+
+// 获取所有协程信息，按启动顺序排列
+//
+//	应在Wait后调用，否则未结束协程的信息不完整
+//	Wait后再次启动协程时，上一批协程信息被清空
+func (g *ErrGroup) Results() []SpanInfo {
+	g.spansMu.Lock()
+	defer g.spansMu.Unlock()
+
+	results := make([]SpanInfo, len(g.spans))
+	for i, span := range g.spans {
+		results[i] = *span
+	}
+	return results
+}
+
 // 获取goroutine信息
 func (g *ErrGroup) GetGoroutineInfo(name string) (SpanInfo, error) {
 	value, ok := g.goroutineSet.Load(name)
@@ -93,6 +139,8 @@ func (g *ErrGroup) GetGoroutineInfo(name string) (SpanInfo, error) {
 		return SpanInfo{}, pkgErrors.Errorf("span %s type error", name)
 	}
 
+	g.spansMu.Lock()
+	defer g.spansMu.Unlock()
 	return *info, nil
 }
 
@@ -109,7 +157,9 @@ func (g *ErrGroup) getContext(defaultContext context.Context) context.Context {
 }
 
 // 启动协程
-func (g *ErrGroup) Go(ctx context.Context, name string, f func(ctx context.Context) error) {
+// 可通过opts设置单次执行超时时间及失败重试
+func (g *ErrGroup) Go(ctx context.Context, name string, f func(ctx context.Context) error, opts ...GoOption) {
+	o := newGoOptions(opts...)
 	ctx, curSpan := g.before(g.getContext(ctx), name, uuid.NewV4().String())
 
 	// 检查信息合法性
@@ -128,39 +178,104 @@ func (g *ErrGroup) Go(ctx context.Context, name string, f func(ctx context.Conte
 		}
 	}
 
+	g.spansMu.Lock()
+	// 上一批协程已Wait且全部结束，清空其信息，避免复用的协程组信息无限增长
+	if g.waited && g.running == 0 {
+		g.reset()
+	}
 	g.goroutineSet.Store(name, curSpan)
+	g.spans = append(g.spans, curSpan)
+	g.running++
+	// 有协程运行时注册到全局活跃协程组
+	if g.running == 1 {
+		registerGroup(g)
+	}
+	g.spansMu.Unlock()
 	g.wg.Add(1)
-	if g.workerChan != nil {
-		for {
-			select {
-			case g.workerChan <- WorkerInfo{
-				f:    f,
-				ctx:  ctx,
-				span: curSpan,
-			}:
-				return
-			default:
-				if !g.workerWait {
-					g.cleanUp(curSpan, pkgErrors.New("goroutine group exhausted"))
-					return
-				}
-			}
+	// 前置钩子返回错误时不启动协程，错误由Wait返回
+	if err := curSpan.Hook.Err(); err != nil {
+		g.cleanUp(curSpan, err)
+		return
+	}
+	if g.executor != nil {
+		if err := g.executor.acquire(ctx, g.executorWait); err != nil {
+			g.cleanUp(curSpan, err)
+			return
 		}
 	}
-	go g.do(ctx, curSpan, f)
+	go g.do(ctx, curSpan, f, o)
 }
 
-func (g *ErrGroup) do(ctx context.Context, span *SpanInfo, f func(ctx context.Context) error) {
+func (g *ErrGroup) do(ctx context.Context, span *SpanInfo, f func(ctx context.Context) error, o *goOptions) {
 	var err error
 
 	defer func() {
-		if p := recover(); p != nil {
-			err = fmt.Errorf("recover panic : %s", p)
+		if g.executor != nil {
+			g.executor.release()
 		}
 		g.cleanUp(span, err)
 	}()
 
-	err = f(ctx)
+	var b backoff.BackOff
+	for attempts := 1; ; attempts++ {
+		g.spansMu.Lock()
+		span.Attempts = attempts
+		g.spansMu.Unlock()
+
+		err = g.call(ctx, span, f, o.timeout)
+		if err == nil || attempts > o.retry {
+			return
+		}
+
+		if b == nil {
+			b = o.backOff()
+		}
+		wait := b.NextBackOff()
+		if wait == backoff.Stop {
+			return
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// 单次执行，捕获panic并上报sentry
+func (g *ErrGroup) call(ctx context.Context, span *SpanInfo, f func(ctx context.Context) error, timeout time.Duration) (err error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			panicErr := &PanicError{Value: p, Stack: debug.Stack()}
+			g.spansMu.Lock()
+			span.Stack = string(panicErr.Stack)
+			g.spansMu.Unlock()
+			sentry.CaptureWithTags(ctx, pkgErrors.WithStack(panicErr),
+				sentry.Tag{Key: "group_name", Value: span.GroupName},
+				sentry.Tag{Key: "goroutine_name", Value: span.GoroutineName},
+			)
+			err = panicErr
+		}
+	}()
+
+	return f(ctx)
+}
+
+// 清空已结束的协程信息，需持有spansMu
+func (g *ErrGroup) reset() {
+	for _, span := range g.spans {
+		if value, ok := g.goroutineSet.Load(span.GoroutineName); ok && value == span {
+			g.goroutineSet.Delete(span.GoroutineName)
+		}
+	}
+	g.spans = nil
+	g.waited = false
 }
 
 func (g *ErrGroup) cleanUp(span *SpanInfo, err error) {
@@ -177,13 +292,15 @@ func (g *ErrGroup) cleanUp(span *SpanInfo, err error) {
 	}
 
 	g.after(span, state, pkgErrors.WithStack(err))
-	g.wg.Done()
-}
 
-type WorkerInfo struct {
-	f    func(ctx context.Context) error
-	ctx  context.Context
-	span *SpanInfo
+	g.spansMu.Lock()
+	g.running--
+	// 无协程运行时从全局活跃协程组中移除
+	if g.running == 0 {
+		unregisterGroup(g)
+	}
+	g.spansMu.Unlock()
+	g.wg.Done()
 }
 
 type SpanInfo struct {
@@ -197,8 +314,16 @@ type SpanInfo struct {
 	GoroutineName string
 	GoroutineID   string
 	Mode          Mode
+	// 所属请求的uuid、路径及方法
+	RequestID     string
+	RequestPath   string
+	RequestMethod string
 	State         State
 	Error         error
+	// 执行次数，重试时大于1
+	Attempts int
+	// panic时的堆栈
+	Stack string
 
 	Hook *hook.Hook
 }
@@ -213,6 +338,9 @@ func (g *ErrGroup) getSpanInfo(ctx context.Context, goroutineName, goroutineID s
 	msg.GoroutineName = goroutineName
 	msg.GoroutineID = goroutineID
 	msg.Mode = g.mode
+	msg.RequestID = _context.GetString(ctx, _context.ContextUUIDKey)
+	msg.RequestPath = _context.GetString(ctx, _context.ContextRequestPathKey)
+	msg.RequestMethod = _context.GetString(ctx, _context.ContextRequestMethodKey)
 
 	return msg
 }
@@ -239,10 +367,12 @@ func (g *ErrGroup) before(ctx context.Context, goroutineName, goroutineID string
 
 // 操作后注入
 func (g *ErrGroup) after(msg *SpanInfo, state State, err error) {
+	g.spansMu.Lock()
 	msg.EndTime = time.Now()
 	msg.Duration = msg.EndTime.Sub(msg.StartTime)
 	msg.Error = err
 	msg.State = state
+	g.spansMu.Unlock()
 
 	hk := msg.Hook.AddArg(render.EndTimeArgKey, msg.EndTime).
 		AddArg(render.DurationArgKey, msg.Duration).
@@ -251,8 +381,13 @@ func (g *ErrGroup) after(msg *SpanInfo, state State, err error) {
 	if err != nil {
 		hk.AddArg("extra", errcode.GetErrorMessageMap(err))
 	}
+	if msg.Attempts > 1 {
+		hk.AddArg("attempts", msg.Attempts)
+	}
+	if msg.Stack != "" {
+		hk.AddArg("stack", msg.Stack)
+	}
 	hk.ProcessAfterHook()
-	msg.Hook = hk
 
 	return
 }
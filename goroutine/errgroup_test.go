@@ -8,7 +8,9 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"gitlab.shanhai.int/sre/library/base/hook"
 	render "gitlab.shanhai.int/sre/library/base/logrender"
+	"gitlab.shanhai.int/sre/library/net/errcode"
 )
 
 func TestErrGroup_Wait(t *testing.T) {
@@ -107,6 +109,37 @@ func TestErrGroup_Go(t *testing.T) {
 
 		assert.Nil(t, err)
 	})
+
+	t.Run("abort", func(t *testing.T) {
+		abortErr := errors.New("abort by hook")
+		_manager.RegisterHandler(hook.Handler{
+			Name: "abort",
+			Pre: func(hk *hook.Hook) error {
+				if hk.Arg("goroutine_name") == "Test2" {
+					return abortErr
+				}
+				return nil
+			},
+		})
+		defer _manager.RemoveHandler("abort")
+
+		ctx := context.Background()
+		eg := WithContext(ctx, "Test")
+
+		var called bool
+		eg.Go(ctx, "Test1", func(c context.Context) error {
+			return nil
+		})
+		eg.Go(ctx, "Test2", func(c context.Context) error {
+			called = true
+			return nil
+		})
+
+		err := eg.Wait()
+
+		assert.Equal(t, abortErr, err)
+		assert.False(t, called)
+	})
 }
 
 func TestErrGroup_GetGoroutineInfo(t *testing.T) {
@@ -236,3 +269,172 @@ func TestSetMaxWorker(t *testing.T) {
 		assert.NotNil(t, err)
 	})
 }
+
+func TestErrGroup_GoOption(t *testing.T) {
+	Init(&Config{
+		Config: &render.Config{
+			Stdout:        true,
+			StdoutPattern: "[%T] [%t] [%U] [status: %s] [mode: %m] %S  Group: %N:%I , Current: %n:%i , %E",
+		},
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		ctx := context.Background()
+		eg := New("Test")
+
+		start := time.Now()
+		eg.Go(ctx, "Test1", func(c context.Context) error {
+			select {
+			case <-time.After(time.Second):
+				return nil
+			case <-c.Done():
+				return c.Err()
+			}
+		}, SetTimeout(50*time.Millisecond))
+
+		err := eg.Wait()
+		assert.Equal(t, context.DeadlineExceeded, err)
+		assert.True(t, time.Since(start) < 500*time.Millisecond)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		ctx := context.Background()
+		eg := New("Test")
+
+		count := 0
+		eg.Go(ctx, "Test1", func(c context.Context) error {
+			count++
+			if count < 3 {
+				return errors.New("this is a error")
+			}
+			return nil
+		}, SetRetry(3, 10*time.Millisecond, 50*time.Millisecond))
+
+		err := eg.Wait()
+		assert.Nil(t, err)
+		info, err := eg.GetGoroutineInfo("Test1")
+		assert.Nil(t, err)
+		assert.Equal(t, 3, info.Attempts)
+
+		eg = New("Test")
+		count = 0
+		eg.Go(ctx, "Test1", func(c context.Context) error {
+			count++
+			return errors.New("this is a error")
+		}, SetRetry(2, 10*time.Millisecond, 50*time.Millisecond))
+
+		err = eg.Wait()
+		assert.EqualError(t, err, "this is a error")
+		assert.Equal(t, 3, count)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		ctx := context.Background()
+		eg := New("Test")
+
+		panicErr := errors.New("this is panic")
+		eg.Go(ctx, "Test1", func(c context.Context) error {
+			panic(panicErr)
+		})
+
+		err := eg.Wait()
+		assert.EqualError(t, err, "recover panic : this is panic")
+		assert.True(t, errors.Is(err, panicErr))
+		assert.Contains(t, fmt.Sprintf("%+v", err), "TestErrGroup_GoOption")
+
+		info, err := eg.GetGoroutineInfo("Test1")
+		assert.Nil(t, err)
+		assert.Equal(t, stateError, info.State)
+		assert.Contains(t, info.Stack, "TestErrGroup_GoOption")
+	})
+}
+
+func TestErrGroup_Results(t *testing.T) {
+	Init(&Config{
+		Config: &render.Config{
+			Stdout:        true,
+			StdoutPattern: "[%T] [%t] [%U] [status: %s] [mode: %m] %S  Group: %N:%I , Current: %n:%i , %E",
+		},
+	})
+
+	t.Run("normal", func(t *testing.T) {
+		ctx := context.Background()
+		eg := New("Test")
+
+		for i := 1; i <= 3; i++ {
+			i := i
+			eg.Go(ctx, fmt.Sprintf("Test%d", i), func(c context.Context) error {
+				time.Sleep(time.Duration(4-i) * 10 * time.Millisecond)
+				if i == 2 {
+					return errors.New("this is a error")
+				}
+				return nil
+			})
+		}
+
+		err := eg.Wait()
+		assert.EqualError(t, err, "this is a error")
+
+		results := eg.Results()
+		assert.Equal(t, 3, len(results))
+		for i, info := range results {
+			assert.Equal(t, fmt.Sprintf("Test%d", i+1), info.GoroutineName)
+		}
+		assert.Equal(t, stateEnd, results[0].State)
+		assert.Equal(t, stateError, results[1].State)
+		assert.EqualError(t, results[1].Error, "this is a error")
+	})
+
+	t.Run("aggregate", func(t *testing.T) {
+		ctx := context.Background()
+		eg := New("Test", SetAggregateMode(nil))
+
+		for i := 1; i <= 3; i++ {
+			i := i
+			eg.Go(ctx, fmt.Sprintf("Test%d", i), func(c context.Context) error {
+				time.Sleep(time.Duration(4-i) * 10 * time.Millisecond)
+				if i == 2 {
+					return nil
+				}
+				return fmt.Errorf("error%d", i)
+			})
+		}
+
+		err := eg.Wait()
+		group, ok := err.(errcode.Group)
+		assert.True(t, ok)
+		assert.Equal(t, errcode.InternalError.Code(), group.Code())
+		assert.Equal(t, []interface{}{"error1", "error3"}, group.Details())
+
+		eg = New("Test", SetAggregateMode(errcode.InvalidParams))
+		eg.Go(ctx, "Test1", func(c context.Context) error {
+			return nil
+		})
+		assert.Nil(t, eg.Wait())
+	})
+
+	t.Run("reuse", func(t *testing.T) {
+		ctx := context.Background()
+		eg := New("Test", SetAggregateMode(nil))
+
+		for i := 1; i <= 3; i++ {
+			eg.Go(ctx, fmt.Sprintf("Test%d", i), func(c context.Context) error {
+				return errors.New("this is a error")
+			})
+		}
+		assert.NotNil(t, eg.Wait())
+		assert.Equal(t, 3, len(eg.Results()))
+
+		// Wait后再次启动协程时清空上一批协程信息
+		eg.Go(ctx, "Test4", func(c context.Context) error {
+			return nil
+		})
+		assert.Nil(t, eg.Wait())
+		results := eg.Results()
+		if assert.Equal(t, 1, len(results)) {
+			assert.Equal(t, "Test4", results[0].GoroutineName)
+		}
+		_, err := eg.GetGoroutineInfo("Test1")
+		assert.NotNil(t, err)
+	})
+}
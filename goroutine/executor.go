@@ -0,0 +1,98 @@
+package goroutine
+
+import (
+	"context"
+	"sync/atomic"
+
+	pkgErrors "github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"gitlab.shanhai.int/sre/library/net/metric"
+)
+
+// 执行器已满
+var ErrExecutorFull = pkgErrors.New("goroutine group exhausted")
+
+// 协程执行器
+//
+//	限制同时运行的协程数，可由多个协程组共享，用于限制进程内的总并发数
+//	无需关闭，不持有常驻协程
+type Executor struct {
+	// 执行器名
+	name string
+	// 运行槽位
+	slots chan struct{}
+	// 排队等待的协程数
+	waiting int64
+
+	// 运行中协程数统计
+	runningGauge prometheus.Gauge
+	// 排队协程数统计
+	queueGauge prometheus.Gauge
+}
+
+// 新建协程执行器，max为最大同时运行的协程数
+func NewExecutor(name string, max int) *Executor {
+	if max <= 0 {
+		panic("executor max must be greater than 0")
+	}
+
+	labels := prometheus.Labels{"executor": name}
+	return &Executor{
+		name:         name,
+		slots:        make(chan struct{}, max),
+		runningGauge: metric.GoroutineExecutorRunning.With(labels),
+		queueGauge:   metric.GoroutineExecutorQueueLength.With(labels),
+	}
+}
+
+// 执行器名
+func (e *Executor) Name() string {
+	return e.name
+}
+
+// 运行中的协程数
+func (e *Executor) Running() int {
+	return len(e.slots)
+}
+
+// 排队等待的协程数
+func (e *Executor) Waiting() int {
+	return int(atomic.LoadInt64(&e.waiting))
+}
+
+// 获取运行槽位
+//
+//	wait为false时，无空闲槽位直接返回ErrExecutorFull
+//	wait为true时，阻塞等待空闲槽位，ctx结束时返回ctx的错误
+func (e *Executor) acquire(ctx context.Context, wait bool) error {
+	select {
+	case e.slots <- struct{}{}:
+		e.runningGauge.Inc()
+		return nil
+	default:
+		if !wait {
+			return ErrExecutorFull
+		}
+	}
+
+	atomic.AddInt64(&e.waiting, 1)
+	e.queueGauge.Inc()
+	defer func() {
+		atomic.AddInt64(&e.waiting, -1)
+		e.queueGauge.Dec()
+	}()
+
+	select {
+	case e.slots <- struct{}{}:
+		e.runningGauge.Inc()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 释放运行槽位
+func (e *Executor) release() {
+	<-e.slots
+	e.runningGauge.Dec()
+}
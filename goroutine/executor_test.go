@@ -0,0 +1,108 @@
+package goroutine
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	render "gitlab.shanhai.int/sre/library/base/logrender"
+)
+
+func TestExecutor(t *testing.T) {
+	Init(&Config{
+		Config: &render.Config{
+			Stdout:        true,
+			StdoutPattern: "[%T] [%t] [%U] [status: %s] [mode: %m] %S  Group: %N:%I , Current: %n:%i , %E",
+		},
+	})
+
+	t.Run("shared", func(t *testing.T) {
+		ctx := context.Background()
+		executor := NewExecutor("Test", 2)
+
+		var running, maxRunning int32
+		f := func(c context.Context) error {
+			cur := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				old := atomic.LoadInt32(&maxRunning)
+				if cur <= old || atomic.CompareAndSwapInt32(&maxRunning, old, cur) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		}
+
+		eg1 := New("Test1", SetExecutor(executor, true))
+		eg2 := New("Test2", SetExecutor(executor, true))
+		done := make(chan struct{})
+		go func() {
+			for i := 0; i < 4; i++ {
+				eg2.Go(ctx, fmt.Sprintf("Test%d", i), f)
+			}
+			close(done)
+		}()
+		for i := 0; i < 4; i++ {
+			eg1.Go(ctx, fmt.Sprintf("Test%d", i), f)
+		}
+		<-done
+
+		assert.Nil(t, eg1.Wait())
+		assert.Nil(t, eg2.Wait())
+		assert.Equal(t, int32(2), atomic.LoadInt32(&maxRunning))
+		assert.Equal(t, 0, executor.Running())
+		assert.Equal(t, 0, executor.Waiting())
+	})
+
+	t.Run("cancel waiting", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		eg := New("Test", SetMaxWorker(1, true))
+
+		block := make(chan struct{})
+		eg.Go(ctx, "Test1", func(c context.Context) error {
+			<-block
+			return nil
+		})
+		time.AfterFunc(20*time.Millisecond, cancel)
+		// 等待中的协程在ctx结束时不再启动
+		eg.Go(ctx, "Test2", func(c context.Context) error {
+			return nil
+		})
+		close(block)
+
+		assert.Equal(t, context.Canceled, eg.Wait())
+		info, err := eg.GetGoroutineInfo("Test2")
+		assert.Nil(t, err)
+		assert.Equal(t, stateError, info.State)
+	})
+
+	t.Run("full", func(t *testing.T) {
+		ctx := context.Background()
+		eg := New("Test", SetMaxWorker(1, false))
+
+		eg.Go(ctx, "Test1", func(c context.Context) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		})
+		eg.Go(ctx, "Test2", func(c context.Context) error {
+			return nil
+		})
+		assert.Equal(t, ErrExecutorFull, eg.Wait())
+	})
+
+	t.Run("reuse", func(t *testing.T) {
+		ctx := context.Background()
+		eg := New("Test", SetMaxWorker(1, true))
+
+		for i := 0; i < 2; i++ {
+			eg.Go(ctx, "Test1", func(c context.Context) error {
+				return nil
+			})
+			assert.Nil(t, eg.Wait())
+		}
+	})
+}
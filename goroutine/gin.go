@@ -0,0 +1,22 @@
+package goroutine
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"gitlab.shanhai.int/sre/library/net/errcode"
+	"gitlab.shanhai.int/sre/library/net/response"
+)
+
+// Gin活跃协程组查询处理器
+//
+//	GET：获取全部活跃协程组及组内协程信息
+func GinGroupsHandler(c *gin.Context) {
+	if c.Request.Method != http.MethodGet {
+		response.StandardJSON(c, nil, errors.Wrap(errcode.BadRequest, "method not allowed"))
+		return
+	}
+
+	response.StandardJSON(c, ActiveGroups(), nil)
+}
@@ -23,12 +23,17 @@ func NewHookManager(renderConfig *render.Config) *hook.Manager {
 	// 创建日志记录器
 	logger := hook.GetDefaultLogger(renderConfig, patternMap)
 	return hook.NewManager().
+		SetComponent(hook.ComponentGoroutine).
 		SetLogger(logger).
 		// goroutine包需要注入前后都打印日志
 		RegisterHook(func(hk *hook.Hook) {
-			hk.GetLogger().Print(hk.Args())
+			if hk.LogEnabled() {
+				hk.GetLogger().Print(hk.Args())
+			}
 		}, func(hk *hook.Hook) {
-			hk.GetLogger().Print(hk.Args())
+			if hk.LogEnabled() {
+				hk.GetLogger().Print(hk.Args())
+			}
 		}).
 		// goroutine包需要注入前后都增加面包屑
 		RegisterHook(func(hk *hook.Hook) {
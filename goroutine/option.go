@@ -2,6 +2,11 @@ package goroutine
 
 import (
 	"context"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"gitlab.shanhai.int/sre/library/net/circuitbreaker"
+	"gitlab.shanhai.int/sre/library/net/errcode"
 )
 
 type Option interface {
@@ -30,20 +35,82 @@ func SetCancelMode(ctx context.Context) Option {
 	})
 }
 
-// 设置最大协程数
-// 只允许设置一次
+// 设置聚合错误模式，可与普通模式及取消模式同时使用
+// Wait返回包含所有协程错误的errcode.Group，code为空时使用errcode.InternalError
+func SetAggregateMode(code errcode.Codes) Option {
+	if code == nil {
+		code = errcode.InternalError
+	}
+
+	return OptionFunc(func(eg *ErrGroup) {
+		eg.aggregateCode = code
+	})
+}
+
+// 设置最大协程数，组内独享执行器
+// wait为true时，协程数已满则阻塞等待，ctx结束时不再启动并返回ctx的错误
+// wait为false时，协程数已满则不启动并返回ErrExecutorFull
 func SetMaxWorker(max int, wait bool) Option {
 	return OptionFunc(func(eg *ErrGroup) {
-		eg.workerOnce.Do(func() {
-			eg.workerChan = make(chan WorkerInfo, max)
-			eg.workerWait = wait
-			for i := 0; i < max; i++ {
-				go func() {
-					for info := range eg.workerChan {
-						eg.do(info.ctx, info.span, info.f)
-					}
-				}()
-			}
-		})
+		eg.executor = NewExecutor(eg.name, max)
+		eg.executorWait = wait
+	})
+}
+
+// 设置共享执行器，多个协程组可共享同一执行器以限制进程内的总并发数
+// wait含义同SetMaxWorker
+func SetExecutor(executor *Executor, wait bool) Option {
+	return OptionFunc(func(eg *ErrGroup) {
+		eg.executor = executor
+		eg.executorWait = wait
+	})
+}
+
+// 协程选项，作用于ErrGroup.Go启动的单个协程
+type GoOption interface {
+	ApplyGo(*goOptions)
+}
+
+type GoOptionFunc func(*goOptions)
+
+func (f GoOptionFunc) ApplyGo(o *goOptions) {
+	f(o)
+}
+
+// 协程选项
+type goOptions struct {
+	// 单次执行超时时间，为0时不限制
+	timeout time.Duration
+	// 最大重试次数
+	retry int
+	// 新建退避方法
+	backOff func() backoff.BackOff
+}
+
+// 新建协程选项
+func newGoOptions(opts ...GoOption) *goOptions {
+	o := new(goOptions)
+	for _, opt := range opts {
+		opt.ApplyGo(o)
+	}
+	return o
+}
+
+// 设置单次执行超时时间
+// 超时后取消传入方法的ctx，方法需响应ctx的取消
+func SetTimeout(timeout time.Duration) GoOption {
+	return GoOptionFunc(func(o *goOptions) {
+		o.timeout = timeout
+	})
+}
+
+// 设置失败重试，times为最大重试次数
+// 重试间隔为指数退避，ctx结束时不再重试
+func SetRetry(times int, minInterval, maxInterval time.Duration) GoOption {
+	return GoOptionFunc(func(o *goOptions) {
+		o.retry = times
+		o.backOff = func() backoff.BackOff {
+			return circuitbreaker.NewExponentialBackOff(minInterval, maxInterval)
+		}
 	})
 }
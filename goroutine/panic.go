@@ -0,0 +1,48 @@
+package goroutine
+
+import (
+	"fmt"
+	"io"
+)
+
+// 协程panic错误，保留完整堆栈
+type PanicError struct {
+	// panic值
+	Value interface{}
+	// panic时的堆栈
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("recover panic : %v", e.Value)
+}
+
+// panic值为error时返回该error
+func (e *PanicError) Cause() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+func (e *PanicError) Unwrap() error {
+	return e.Cause()
+}
+
+// 格式化，%+v时输出堆栈
+func (e *PanicError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			_, _ = io.WriteString(s, e.Error())
+			_, _ = io.WriteString(s, "\n")
+			_, _ = s.Write(e.Stack)
+			return
+		}
+		fallthrough
+	case 's':
+		_, _ = io.WriteString(s, e.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", e.Error())
+	}
+}
@@ -0,0 +1,148 @@
+package goroutine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+
+	pkgErrors "github.com/pkg/errors"
+)
+
+// 并发处理切片，结果与输入顺序一致
+//
+//	items须为切片，concurrency为最大并发数，小于等于0时为切片长度
+//	任一元素处理失败时取消其他元素的处理，并返回该错误
+func ParallelMap(ctx context.Context, name string, items interface{}, concurrency int,
+	f func(ctx context.Context, index int, item interface{}) (interface{}, error)) ([]interface{}, error) {
+	value := reflect.ValueOf(items)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return nil, pkgErrors.Errorf("items must be slice, got %T", items)
+	}
+	length := value.Len()
+	if length == 0 {
+		return []interface{}{}, nil
+	}
+	if concurrency <= 0 || concurrency > length {
+		concurrency = length
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pool := NewPool(name, concurrency, 0)
+	defer pool.Close()
+
+	futures := make([]*Future, length)
+	for i := 0; i < length; i++ {
+		index, item := i, value.Index(i).Interface()
+		futures[i] = pool.Submit(ctx, fmt.Sprintf("%s-%d", name, index), func(ctx context.Context) (interface{}, error) {
+			result, err := f(ctx, index, item)
+			if err != nil {
+				cancel()
+			}
+			return result, err
+		})
+	}
+
+	results := make([]interface{}, length)
+	var firstErr error
+	for i, future := range futures {
+		<-future.Done()
+		results[i] = future.value
+		// 取消导致的错误不作为结果返回
+		if future.err != nil && (firstErr == nil || firstErr == context.Canceled) {
+			firstErr = future.err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
+// 竞争执行结果
+type raceResult struct {
+	value interface{}
+	err   error
+}
+
+// 并发执行，返回第一个成功的结果并取消其他协程
+//
+//	全部失败时返回最后一个错误，返回前等待所有协程结束，方法需响应ctx的取消
+func FirstSuccess(ctx context.Context, name string, fs ...func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	return race(ctx, name, true, fs...)
+}
+
+// 并发执行，返回第一个完成的结果(无论成功与否)并取消其他协程
+//
+//	返回前等待所有协程结束，方法需响应ctx的取消
+func Race(ctx context.Context, name string, fs ...func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	return race(ctx, name, false, fs...)
+}
+
+// 竞争执行
+func race(ctx context.Context, name string, success bool, fs ...func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if len(fs) == 0 {
+		return nil, pkgErrors.New("no function to run")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan raceResult, len(fs))
+	eg := New(name)
+	for i, f := range fs {
+		f := f
+		eg.Go(ctx, fmt.Sprintf("%s-%d", name, i), func(ctx context.Context) (err error) {
+			// 捕获panic，保证每个协程都有结果
+			defer func() {
+				if r := recover(); r != nil {
+					err = &PanicError{Value: r, Stack: debug.Stack()}
+					results <- raceResult{err: err}
+				}
+			}()
+			value, err := f(ctx)
+			results <- raceResult{value: value, err: err}
+			return err
+		})
+	}
+	// 协程被前置钩子中断时不会写入结果
+	go func() {
+		_ = eg.Wait()
+		close(results)
+	}()
+
+	var (
+		winner   *raceResult
+		received int
+		lastErr  error
+	)
+	for result := range results {
+		received++
+		if winner != nil {
+			continue
+		}
+		if result.err == nil || !success {
+			r := result
+			winner = &r
+			cancel()
+			continue
+		}
+		lastErr = result.err
+	}
+	if winner != nil {
+		return winner.value, winner.err
+	}
+	if lastErr == nil {
+		lastErr = eg.Error()
+	}
+	if lastErr == nil {
+		lastErr = pkgErrors.New("no result")
+	}
+	return nil, pkgErrors.Wrapf(lastErr, "all %d goroutines failed", received)
+}
@@ -0,0 +1,108 @@
+package goroutine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	render "gitlab.shanhai.int/sre/library/base/logrender"
+)
+
+func TestParallelMap(t *testing.T) {
+	Init(&Config{
+		Config: &render.Config{
+			Stdout:        true,
+			StdoutPattern: "[%T] [%t] [%U] [status: %s] [mode: %m] %S  Group: %N:%I , Current: %n:%i , %E",
+		},
+	})
+
+	t.Run("ordered", func(t *testing.T) {
+		items := []int{5, 1, 4, 2, 3}
+		results, err := ParallelMap(context.Background(), "Test", items, 3,
+			func(ctx context.Context, index int, item interface{}) (interface{}, error) {
+				// 先完成的元素不影响结果顺序
+				time.Sleep(time.Duration(item.(int)) * 10 * time.Millisecond)
+				return item.(int) * 10, nil
+			})
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{50, 10, 40, 20, 30}, results)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := ParallelMap(context.Background(), "Test", []string{"a", "b", "c", "d"}, 1,
+			func(ctx context.Context, index int, item interface{}) (interface{}, error) {
+				if item == "b" {
+					return nil, errors.New("this is a error")
+				}
+				return item, nil
+			})
+		assert.EqualError(t, err, "this is a error")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParallelMap(context.Background(), "Test", 1, 1, nil)
+		assert.NotNil(t, err)
+
+		results, err := ParallelMap(context.Background(), "Test", []int{}, 1, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{}, results)
+	})
+}
+
+func TestFirstSuccess(t *testing.T) {
+	Init(&Config{
+		Config: &render.Config{
+			Stdout:        true,
+			StdoutPattern: "[%T] [%t] [%U] [status: %s] [mode: %m] %S  Group: %N:%I , Current: %n:%i , %E",
+		},
+	})
+
+	sleep := func(d time.Duration, v interface{}, err error) func(ctx context.Context) (interface{}, error) {
+		return func(ctx context.Context) (interface{}, error) {
+			select {
+			case <-time.After(d):
+				return v, err
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	t.Run("first success", func(t *testing.T) {
+		start := time.Now()
+		v, err := FirstSuccess(context.Background(), "Test",
+			sleep(10*time.Millisecond, nil, errors.New("fast error")),
+			sleep(50*time.Millisecond, "ok", nil),
+			sleep(time.Second, "slow", nil),
+		)
+		assert.Nil(t, err)
+		assert.Equal(t, "ok", v)
+		// 其他协程被取消
+		assert.True(t, time.Since(start) < 500*time.Millisecond)
+	})
+
+	t.Run("all failed", func(t *testing.T) {
+		_, err := FirstSuccess(context.Background(), "Test",
+			sleep(10*time.Millisecond, nil, errors.New("error1")),
+			sleep(20*time.Millisecond, nil, errors.New("error2")),
+		)
+		assert.EqualError(t, err, "all 2 goroutines failed: error2")
+	})
+
+	t.Run("race", func(t *testing.T) {
+		_, err := Race(context.Background(), "Test",
+			sleep(10*time.Millisecond, nil, errors.New("fast error")),
+			sleep(50*time.Millisecond, "ok", nil),
+		)
+		assert.EqualError(t, err, "fast error")
+
+		v, err := Race(context.Background(), "Test",
+			sleep(50*time.Millisecond, nil, errors.New("slow error")),
+			sleep(10*time.Millisecond, "ok", nil),
+		)
+		assert.Nil(t, err)
+		assert.Equal(t, "ok", v)
+	})
+}
@@ -0,0 +1,139 @@
+package goroutine
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// 流水线阶段处理方法
+//
+//	返回的结果传递给下一阶段，返回错误时取消整个流水线
+type StageFunc func(ctx context.Context, in interface{}) (interface{}, error)
+
+// 流水线阶段
+type stage struct {
+	// 阶段名
+	name string
+	// worker数量
+	workers int
+	// 处理方法
+	f StageFunc
+}
+
+// 流水线
+//
+//	各阶段通过有界管道连接，每个worker作为协程组中的协程运行，注入钩子及goroutine_*统计
+//	任一阶段出错时取消整个流水线
+type Pipeline struct {
+	// 流水线名，即协程组名
+	name string
+	// 阶段间管道长度
+	buffer int
+	// 阶段
+	stages []stage
+}
+
+// 新建流水线，buffer为阶段间管道长度
+func NewPipeline(name string, buffer int) *Pipeline {
+	if buffer < 0 {
+		buffer = 0
+	}
+	return &Pipeline{
+		name:   name,
+		buffer: buffer,
+	}
+}
+
+// 增加阶段，workers为该阶段并发数
+func (p *Pipeline) AddStage(name string, workers int, f StageFunc) *Pipeline {
+	if workers <= 0 {
+		workers = 1
+	}
+	p.stages = append(p.stages, stage{name: name, workers: workers, f: f})
+	return p
+}
+
+// 运行流水线
+//
+//	source向管道写入数据，写入时需同时监听ctx.Done()，写入完成后返回即可，管道由流水线关闭
+//	sink依次消费最后阶段的结果，为空时丢弃结果
+//	返回第一个错误，返回前等待所有协程结束
+func (p *Pipeline) Run(ctx context.Context,
+	source func(ctx context.Context, out chan<- interface{}) error,
+	sink func(ctx context.Context, v interface{}) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	eg := WithContext(ctx, p.name)
+
+	sourceOut := make(chan interface{}, p.buffer)
+	eg.Go(ctx, "source", func(ctx context.Context) error {
+		defer close(sourceOut)
+		return source(ctx, sourceOut)
+	})
+
+	in := sourceOut
+	for i, s := range p.stages {
+		in = p.runStage(ctx, eg, i, s, in)
+	}
+
+	eg.Go(ctx, "sink", func(ctx context.Context) error {
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return nil
+				}
+				if sink == nil {
+					continue
+				}
+				if err := sink(ctx, v); err != nil {
+					return err
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+
+	return eg.Wait()
+}
+
+// 运行阶段，返回输出管道
+//
+//	最后一个结束的worker关闭输出管道，出错时协程组取消ctx，各阶段监听ctx退出
+func (p *Pipeline) runStage(ctx context.Context, eg *ErrGroup, index int, s stage, in <-chan interface{}) chan interface{} {
+	out := make(chan interface{}, p.buffer)
+	remaining := int32(s.workers)
+
+	for i := 0; i < s.workers; i++ {
+		eg.Go(ctx, fmt.Sprintf("%d-%s-%d", index, s.name, i), func(ctx context.Context) error {
+			defer func() {
+				if atomic.AddInt32(&remaining, -1) == 0 {
+					close(out)
+				}
+			}()
+			for {
+				select {
+				case v, ok := <-in:
+					if !ok {
+						return nil
+					}
+					result, err := s.f(ctx, v)
+					if err != nil {
+						return err
+					}
+					select {
+					case out <- result:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		})
+	}
+	return out
+}
@@ -0,0 +1,72 @@
+package goroutine
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	render "gitlab.shanhai.int/sre/library/base/logrender"
+)
+
+func TestPipeline_Run(t *testing.T) {
+	Init(&Config{
+		Config: &render.Config{
+			Stdout:        true,
+			StdoutPattern: "[%T] [%t] [%U] [status: %s] [mode: %m] %S  Group: %N:%I , Current: %n:%i , %E",
+		},
+	})
+
+	source := func(n int) func(ctx context.Context, out chan<- interface{}) error {
+		return func(ctx context.Context, out chan<- interface{}) error {
+			for i := 1; i <= n; i++ {
+				select {
+				case out <- i:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		}
+	}
+
+	t.Run("normal", func(t *testing.T) {
+		var results []string
+		err := NewPipeline("Test", 2).
+			AddStage("square", 3, func(ctx context.Context, in interface{}) (interface{}, error) {
+				return in.(int) * in.(int), nil
+			}).
+			AddStage("format", 1, func(ctx context.Context, in interface{}) (interface{}, error) {
+				return strconv.Itoa(in.(int)), nil
+			}).
+			Run(context.Background(), source(5), func(ctx context.Context, v interface{}) error {
+				results = append(results, v.(string))
+				return nil
+			})
+		assert.Nil(t, err)
+		sort.Strings(results)
+		assert.Equal(t, []string{"1", "16", "25", "4", "9"}, results)
+	})
+
+	t.Run("stage error", func(t *testing.T) {
+		err := NewPipeline("Test", 0).
+			AddStage("check", 2, func(ctx context.Context, in interface{}) (interface{}, error) {
+				if in.(int) == 3 {
+					return nil, errors.New("this is a error")
+				}
+				return in, nil
+			}).
+			Run(context.Background(), source(1000), nil)
+		assert.EqualError(t, err, "this is a error")
+	})
+
+	t.Run("sink error", func(t *testing.T) {
+		err := NewPipeline("Test", 1).
+			Run(context.Background(), source(1000), func(ctx context.Context, v interface{}) error {
+				return errors.New("sink error")
+			})
+		assert.EqualError(t, err, "sink error")
+	})
+}
@@ -0,0 +1,177 @@
+package goroutine
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+
+	pkgErrors "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// 协程池已关闭
+var ErrPoolClosed = pkgErrors.New("goroutine pool closed")
+
+// 异步结果
+type Future struct {
+	// 完成信号
+	done chan struct{}
+	// 结果
+	value interface{}
+	// 错误
+	err error
+}
+
+// 新建异步结果
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+// 设置结果
+func (f *Future) resolve(value interface{}, err error) {
+	f.value = value
+	f.err = err
+	close(f.done)
+}
+
+// 完成信号
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// 等待并获取结果，ctx结束时返回ctx的错误
+func (f *Future) Get(ctx context.Context) (interface{}, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// 池任务
+type poolTask struct {
+	ctx    context.Context
+	name   string
+	f      func(ctx context.Context) (interface{}, error)
+	future *Future
+}
+
+// 协程池
+//
+//	固定数量的worker执行提交的任务，每个任务与ErrGroup的协程一样注入钩子，记录日志、链路跟踪及goroutine_*统计
+type Pool struct {
+	// 所属协程组，提供组名、组id及钩子注入
+	group *ErrGroup
+	// 任务队列
+	tasks chan *poolTask
+	// worker wait group
+	wg sync.WaitGroup
+	// 保护closed及队列关闭
+	mu sync.RWMutex
+	// 是否关闭
+	closed bool
+}
+
+// 新建协程池
+//
+//	workers为worker数量，queueSize为等待队列长度，队列满时Submit阻塞
+func NewPool(name string, workers, queueSize int) *Pool {
+	if workers <= 0 {
+		panic("goroutine pool workers must be greater than 0")
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &Pool{
+		group: New(name),
+		tasks: make(chan *poolTask, queueSize),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// 提交任务
+//
+//	队列满时阻塞直到入队或ctx结束，已关闭时返回的结果中包含ErrPoolClosed
+func (p *Pool) Submit(ctx context.Context, name string, f func(ctx context.Context) (interface{}, error)) *Future {
+	future := newFuture()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		future.resolve(nil, ErrPoolClosed)
+		return future
+	}
+
+	select {
+	case p.tasks <- &poolTask{ctx: ctx, name: name, f: f, future: future}:
+	case <-ctx.Done():
+		future.resolve(nil, ctx.Err())
+	}
+	return future
+}
+
+// worker
+func (p *Pool) worker() {
+	defer p.wg.Done()
+	for task := range p.tasks {
+		p.run(task)
+	}
+}
+
+// 执行任务
+func (p *Pool) run(task *poolTask) {
+	// 等待期间ctx已结束时不再执行
+	if err := task.ctx.Err(); err != nil {
+		task.future.resolve(nil, err)
+		return
+	}
+
+	ctx, span := p.group.before(task.ctx, task.name, uuid.NewV4().String())
+	var (
+		value interface{}
+		err   = span.Hook.Err()
+	)
+	if err == nil {
+		value, err = p.call(ctx, task.f)
+	}
+
+	state := stateEnd
+	if err != nil {
+		state = stateError
+	}
+	p.group.after(span, state, pkgErrors.WithStack(err))
+	task.future.resolve(value, err)
+}
+
+// 调用任务方法，捕获panic
+func (p *Pool) call(ctx context.Context, f func(ctx context.Context) (interface{}, error)) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return f(ctx)
+}
+
+// 关闭，不再接受新任务，等待已提交的任务执行完成
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.tasks)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
@@ -0,0 +1,102 @@
+package goroutine
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	render "gitlab.shanhai.int/sre/library/base/logrender"
+)
+
+func TestPool_Submit(t *testing.T) {
+	Init(&Config{
+		Config: &render.Config{
+			Stdout:        true,
+			StdoutPattern: "[%T] [%t] [%U] [status: %s] [mode: %m] %S  Group: %N:%I , Current: %n:%i , %E",
+		},
+	})
+
+	t.Run("normal", func(t *testing.T) {
+		pool := NewPool("Test", 2, 4)
+		defer pool.Close()
+
+		var running, maxRunning int32
+		futures := make([]*Future, 0)
+		for i := 0; i < 6; i++ {
+			i := i
+			futures = append(futures, pool.Submit(context.Background(), "Task", func(ctx context.Context) (interface{}, error) {
+				cur := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					old := atomic.LoadInt32(&maxRunning)
+					if cur <= old || atomic.CompareAndSwapInt32(&maxRunning, old, cur) {
+						break
+					}
+				}
+				time.Sleep(50 * time.Millisecond)
+				return i * i, nil
+			}))
+		}
+
+		for i, future := range futures {
+			v, err := future.Get(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, i*i, v)
+		}
+		assert.Equal(t, int32(2), atomic.LoadInt32(&maxRunning))
+	})
+
+	t.Run("error and panic", func(t *testing.T) {
+		pool := NewPool("Test", 1, 0)
+		defer pool.Close()
+
+		_, err := pool.Submit(context.Background(), "Error", func(ctx context.Context) (interface{}, error) {
+			return nil, errors.New("this is a error")
+		}).Get(context.Background())
+		assert.EqualError(t, err, "this is a error")
+
+		_, err = pool.Submit(context.Background(), "Panic", func(ctx context.Context) (interface{}, error) {
+			panic("this is panic")
+		}).Get(context.Background())
+		assert.EqualError(t, err, "recover panic : this is panic")
+	})
+
+	t.Run("get timeout", func(t *testing.T) {
+		pool := NewPool("Test", 1, 0)
+		defer pool.Close()
+
+		future := pool.Submit(context.Background(), "Slow", func(ctx context.Context) (interface{}, error) {
+			time.Sleep(200 * time.Millisecond)
+			return "done", nil
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := future.Get(ctx)
+		assert.Equal(t, context.DeadlineExceeded, err)
+
+		v, err := future.Get(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, "done", v)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		pool := NewPool("Test", 1, 1)
+		var done int32
+		pool.Submit(context.Background(), "Task", func(ctx context.Context) (interface{}, error) {
+			time.Sleep(50 * time.Millisecond)
+			atomic.StoreInt32(&done, 1)
+			return nil, nil
+		})
+		// 关闭时等待已提交的任务完成
+		pool.Close()
+		assert.Equal(t, int32(1), atomic.LoadInt32(&done))
+
+		_, err := pool.Submit(context.Background(), "Task", func(ctx context.Context) (interface{}, error) {
+			return nil, nil
+		}).Get(context.Background())
+		assert.Equal(t, ErrPoolClosed, err)
+	})
+}
@@ -0,0 +1,132 @@
+package goroutine
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// 全局活跃协程组，key为协程组uuid
+	_groups sync.Map
+)
+
+// 活跃协程组信息
+type GroupInfo struct {
+	// 协程组名
+	Name string `json:"name"`
+	// 协程组uuid
+	ID string `json:"id"`
+	// 模式
+	Mode Mode `json:"mode"`
+	// 运行中的协程数
+	Running int `json:"running"`
+	// 组内协程，按启动顺序排列
+	Goroutines []GoroutineInfo `json:"goroutines"`
+}
+
+// 协程信息
+type GoroutineInfo struct {
+	// 协程名
+	Name string `json:"name"`
+	// 协程uuid
+	ID string `json:"id"`
+	// 状态
+	State State `json:"state"`
+	// 启动时间
+	StartTime time.Time `json:"start_time"`
+	// 已运行时间，已结束时为总耗时
+	Elapsed string `json:"elapsed"`
+	// 调用位置
+	Source string `json:"source"`
+	// 执行次数
+	Attempts int `json:"attempts,omitempty"`
+	// 错误信息
+	Error string `json:"error,omitempty"`
+	// 所属请求的uuid
+	RequestID string `json:"request_id,omitempty"`
+	// 所属请求的路径
+	RequestPath string `json:"request_path,omitempty"`
+	// 所属请求的方法
+	RequestMethod string `json:"request_method,omitempty"`
+}
+
+// 注册活跃协程组
+func registerGroup(g *ErrGroup) {
+	_groups.Store(g.uuid, g)
+}
+
+// 移除活跃协程组
+func unregisterGroup(g *ErrGroup) {
+	_groups.Delete(g.uuid)
+}
+
+// 获取协程组信息
+func (g *ErrGroup) info(now time.Time) GroupInfo {
+	g.spansMu.Lock()
+	defer g.spansMu.Unlock()
+
+	info := GroupInfo{
+		Name:       g.name,
+		ID:         g.uuid,
+		Mode:       g.mode,
+		Running:    g.running,
+		Goroutines: make([]GoroutineInfo, len(g.spans)),
+	}
+	for i, span := range g.spans {
+		elapsed := span.Duration
+		if span.State == stateStart {
+			elapsed = now.Sub(span.StartTime)
+		}
+		goroutineInfo := GoroutineInfo{
+			Name:          span.GoroutineName,
+			ID:            span.GoroutineID,
+			State:         span.State,
+			StartTime:     span.StartTime,
+			Elapsed:       elapsed.String(),
+			Source:        span.Source,
+			Attempts:      span.Attempts,
+			RequestID:     span.RequestID,
+			RequestPath:   span.RequestPath,
+			RequestMethod: span.RequestMethod,
+		}
+		if span.Error != nil {
+			goroutineInfo.Error = span.Error.Error()
+		}
+		info.Goroutines[i] = goroutineInfo
+	}
+	return info
+}
+
+// 获取全部活跃协程组信息
+//
+//	活跃协程组为存在运行中协程的协程组，按最早启动的协程排序
+func ActiveGroups() []GroupInfo {
+	now := time.Now()
+	groups := make([]GroupInfo, 0)
+	_groups.Range(func(key, value interface{}) bool {
+		if g, ok := value.(*ErrGroup); ok {
+			groups = append(groups, g.info(now))
+		}
+		return true
+	})
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groupStartTime(groups[i]).Before(groupStartTime(groups[j]))
+	})
+	return groups
+}
+
+// 协程组中最早启动的协程的启动时间
+func groupStartTime(info GroupInfo) time.Time {
+	if len(info.Goroutines) == 0 {
+		return time.Time{}
+	}
+	return info.Goroutines[0].StartTime
+}
+
+// 以JSON格式导出全部活跃协程组信息，用于排查协程阻塞
+func Dump() ([]byte, error) {
+	return json.MarshalIndent(ActiveGroups(), "", "  ")
+}
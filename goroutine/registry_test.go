@@ -0,0 +1,88 @@
+package goroutine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	_context "gitlab.shanhai.int/sre/library/base/context"
+	render "gitlab.shanhai.int/sre/library/base/logrender"
+	httpUtil "gitlab.shanhai.int/sre/library/base/net"
+)
+
+// 查找指定id的活跃协程组
+func findActiveGroup(id string) (GroupInfo, bool) {
+	for _, info := range ActiveGroups() {
+		if info.ID == id {
+			return info, true
+		}
+	}
+	return GroupInfo{}, false
+}
+
+func TestActiveGroups(t *testing.T) {
+	Init(&Config{
+		Config: &render.Config{
+			Stdout:        true,
+			StdoutPattern: "[%T] [%t] [%U] [status: %s] [mode: %m] %S  Group: %N:%I , Current: %n:%i , %E",
+		},
+	})
+
+	ctx := context.WithValue(context.Background(), _context.ContextUUIDKey, "request-uuid")
+	eg := New("Test")
+	_, ok := findActiveGroup(eg.uuid)
+	assert.False(t, ok)
+
+	block := make(chan struct{})
+	eg.Go(ctx, "Fast", func(ctx context.Context) error {
+		return nil
+	})
+	eg.Go(ctx, "Blocked", func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	info, ok := findActiveGroup(eg.uuid)
+	assert.True(t, ok)
+	assert.Equal(t, "Test", info.Name)
+	assert.Equal(t, 1, info.Running)
+	assert.Equal(t, 2, len(info.Goroutines))
+	assert.Equal(t, "Fast", info.Goroutines[0].Name)
+	assert.Equal(t, stateEnd, info.Goroutines[0].State)
+	assert.Equal(t, "Blocked", info.Goroutines[1].Name)
+	assert.Equal(t, stateStart, info.Goroutines[1].State)
+	assert.Equal(t, "request-uuid", info.Goroutines[1].RequestID)
+	assert.NotEmpty(t, info.Goroutines[1].Source)
+
+	data, err := Dump()
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), eg.uuid)
+
+	router := gin.New()
+	router.GET("/debug/goroutine/groups", GinGroupsHandler)
+	r, err := httpUtil.TestGinJsonRequest(router, http.MethodGet, "/debug/goroutine/groups", nil, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, r.Code)
+	resp := struct {
+		Data []GroupInfo `json:"data"`
+	}{}
+	assert.Nil(t, json.Unmarshal(r.Body.Bytes(), &resp))
+	found := false
+	for _, group := range resp.Data {
+		if group.ID == eg.uuid {
+			found = true
+			assert.Equal(t, "Blocked", group.Goroutines[1].Name)
+		}
+	}
+	assert.True(t, found)
+
+	close(block)
+	assert.Nil(t, eg.Wait())
+	_, ok = findActiveGroup(eg.uuid)
+	assert.False(t, ok)
+}
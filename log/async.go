@@ -0,0 +1,283 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 默认异步队列大小
+const _defaultAsyncQueueSize = 4096
+
+// 队列溢出策略
+type OverflowPolicy int
+
+const (
+	// 阻塞，直到队列有空闲位置
+	OverflowBlock OverflowPolicy = iota
+	// 丢弃新日志
+	OverflowDropNewest
+	// 丢弃队列中最旧的日志
+	OverflowDropOldest
+	// 丢弃低于指定级别的新日志，不低于该级别的日志阻塞写入
+	OverflowDropBelowLevel
+)
+
+// 溢出策略名
+var overflowPolicyNames = map[string]OverflowPolicy{
+	"block":            OverflowBlock,
+	"drop_newest":      OverflowDropNewest,
+	"drop_oldest":      OverflowDropOldest,
+	"drop_below_level": OverflowDropBelowLevel,
+}
+
+// 解析队列溢出策略，为空时为阻塞
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return OverflowBlock, nil
+	}
+	if p, ok := overflowPolicyNames[strings.Replace(s, "-", "_", -1)]; ok {
+		return p, nil
+	}
+	return 0, fmt.Errorf("invalid overflow policy: %s", s)
+}
+
+// 异步日志配置
+type AsyncConfig struct {
+	// 队列大小，默认4096
+	QueueSize int `yaml:"queueSize"`
+	// 队列溢出策略，可选block、drop_newest、drop_oldest、drop_below_level，默认block
+	Overflow string `yaml:"overflow"`
+	// drop_below_level策略下，队列满时低于该级别的日志丢弃，默认error
+	DropBelowLevel string `yaml:"dropBelowLevel"`
+}
+
+// 异步日志统计
+type AsyncStats struct {
+	// 队列容量
+	Capacity int
+	// 当前队列深度
+	Depth int
+	// 累计入队条数
+	Enqueued uint64
+	// 累计丢弃条数
+	Dropped uint64
+	// 累计写入条数
+	Written uint64
+}
+
+// 异步日志条目
+type asyncEntry struct {
+	// 日志级别
+	lv Level
+	// 日志参数
+	args map[string]interface{}
+}
+
+// 异步日志处理器
+//
+//	包装任意日志处理器，调用方只负责入队，由后台协程渲染及写入
+//	入队时会拷贝日志参数并提取上下文中的字段，后台写入时使用context.Background()
+type AsyncHandler struct {
+	// 被包装的日志处理器
+	handler Handler
+	// 溢出策略
+	policy OverflowPolicy
+	// drop_below_level策略下的丢弃级别
+	dropBelow Level
+
+	mu sync.Mutex
+	// 队列非空信号
+	notEmpty *sync.Cond
+	// 队列未满信号
+	notFull *sync.Cond
+	// 环形队列
+	queue []asyncEntry
+	// 队首下标
+	head int
+	// 队列长度
+	size int
+	// 是否关闭
+	closed bool
+	// 后台协程结束信号
+	done chan struct{}
+
+	// 累计入队条数
+	enqueued uint64
+	// 累计丢弃条数
+	dropped uint64
+	// 累计写入条数
+	written uint64
+}
+
+// 新建异步日志处理器
+func NewAsync(handler Handler, config *AsyncConfig) *AsyncHandler {
+	if config == nil {
+		config = &AsyncConfig{}
+	}
+	policy, err := ParseOverflowPolicy(config.Overflow)
+	if err != nil {
+		panic(err)
+	}
+	dropBelow := _errorLevel
+	if config.DropBelowLevel != "" {
+		dropBelow, err = ParseLevel(config.DropBelowLevel)
+		if err != nil {
+			panic(err)
+		}
+	}
+	size := config.QueueSize
+	if size <= 0 {
+		size = _defaultAsyncQueueSize
+	}
+
+	h := &AsyncHandler{
+		handler:   handler,
+		policy:    policy,
+		dropBelow: dropBelow,
+		queue:     make([]asyncEntry, size),
+		done:      make(chan struct{}),
+	}
+	h.notEmpty = sync.NewCond(&h.mu)
+	h.notFull = sync.NewCond(&h.mu)
+
+	go h.daemon()
+
+	return h
+}
+
+func (h *AsyncHandler) Log(ctx context.Context, lv Level, args map[string]interface{}) {
+	// 拷贝参数，避免与其他处理器并发读写，并提前提取上下文中的字段
+	entry := asyncEntry{
+		lv:   lv,
+		args: make(map[string]interface{}, len(args)+4),
+	}
+	for k, v := range args {
+		entry.args[k] = v
+	}
+	addExtraField(ctx, entry.args)
+
+	h.mu.Lock()
+	for h.size == len(h.queue) && !h.closed {
+		switch {
+		case h.policy == OverflowDropNewest,
+			h.policy == OverflowDropBelowLevel && lv < h.dropBelow:
+			h.mu.Unlock()
+			atomic.AddUint64(&h.dropped, 1)
+			return
+		case h.policy == OverflowDropOldest:
+			h.queue[h.head] = asyncEntry{}
+			h.head = (h.head + 1) % len(h.queue)
+			h.size--
+			atomic.AddUint64(&h.dropped, 1)
+		default:
+			h.notFull.Wait()
+		}
+	}
+	if h.closed {
+		h.mu.Unlock()
+		atomic.AddUint64(&h.dropped, 1)
+		return
+	}
+
+	h.queue[(h.head+h.size)%len(h.queue)] = entry
+	h.size++
+	h.mu.Unlock()
+
+	atomic.AddUint64(&h.enqueued, 1)
+	h.notEmpty.Signal()
+}
+
+// 后台写入协程
+func (h *AsyncHandler) daemon() {
+	defer close(h.done)
+
+	batch := make([]asyncEntry, 0, len(h.queue))
+	for {
+		h.mu.Lock()
+		for h.size == 0 && !h.closed {
+			h.notEmpty.Wait()
+		}
+		if h.size == 0 && h.closed {
+			h.mu.Unlock()
+			return
+		}
+		// 一次取出队列中全部日志，减少锁竞争
+		for h.size > 0 {
+			batch = append(batch, h.queue[h.head])
+			h.queue[h.head] = asyncEntry{}
+			h.head = (h.head + 1) % len(h.queue)
+			h.size--
+		}
+		h.mu.Unlock()
+		h.notFull.Broadcast()
+
+		for i := range batch {
+			h.handler.Log(context.Background(), batch[i].lv, batch[i].args)
+			batch[i] = asyncEntry{}
+		}
+		atomic.AddUint64(&h.written, uint64(len(batch)))
+		batch = batch[:0]
+	}
+}
+
+// 获取统计数据
+func (h *AsyncHandler) Stats() AsyncStats {
+	h.mu.Lock()
+	depth := h.size
+	h.mu.Unlock()
+
+	return AsyncStats{
+		Capacity: len(h.queue),
+		Depth:    depth,
+		Enqueued: atomic.LoadUint64(&h.enqueued),
+		Dropped:  atomic.LoadUint64(&h.dropped),
+		Written:  atomic.LoadUint64(&h.written),
+	}
+}
+
+// 关闭，等待队列中的日志全部写入后关闭被包装的日志处理器
+func (h *AsyncHandler) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	h.mu.Unlock()
+
+	h.notEmpty.Broadcast()
+	h.notFull.Broadcast()
+	<-h.done
+
+	return h.handler.Close()
+}
+
+func (h *AsyncHandler) SetFormat(format string) {
+	h.handler.SetFormat(format)
+}
+
+// 获取默认日志处理器中全部异步处理器的统计数据之和
+func GetAsyncStats() AsyncStats {
+	var stats AsyncStats
+	hs, ok := h.(*DefaultBatchHandler)
+	if !ok {
+		return stats
+	}
+	for _, handler := range hs.handlers {
+		ah, ok := handler.(*AsyncHandler)
+		if !ok {
+			continue
+		}
+		s := ah.Stats()
+		stats.Capacity += s.Capacity
+		stats.Depth += s.Depth
+		stats.Enqueued += s.Enqueued
+		stats.Dropped += s.Dropped
+		stats.Written += s.Written
+	}
+	return stats
+}
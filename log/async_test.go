@@ -0,0 +1,193 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	render "gitlab.shanhai.int/sre/library/base/logrender"
+)
+
+// 可阻塞的日志处理器，用于模拟慢速写入
+type gateHandler struct {
+	mu     sync.Mutex
+	gate   chan struct{}
+	logs   []string
+	closed bool
+}
+
+func newGateHandler() *gateHandler {
+	return &gateHandler{gate: make(chan struct{})}
+}
+
+func (h *gateHandler) Log(ctx context.Context, lv Level, args map[string]interface{}) {
+	<-h.gate
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.logs = append(h.logs, args[_log].(string))
+}
+
+func (h *gateHandler) SetFormat(string) {}
+
+func (h *gateHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	return nil
+}
+
+func (h *gateHandler) getLogs() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.logs...)
+}
+
+// 等待后台协程取出第一条日志并阻塞在写入中
+func waitInFlight(t *testing.T, h *AsyncHandler) {
+	for i := 0; i < 100; i++ {
+		if h.Stats().Depth == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("daemon doesn't consume")
+}
+
+func TestAsyncHandler_Overflow(t *testing.T) {
+	ctx := context.Background()
+	logAll := func(h *AsyncHandler, lv Level, msgs ...string) {
+		for _, msg := range msgs {
+			h.Log(ctx, lv, map[string]interface{}{_log: msg})
+		}
+	}
+
+	t.Run("drop newest", func(t *testing.T) {
+		gh := newGateHandler()
+		h := NewAsync(gh, &AsyncConfig{QueueSize: 2, Overflow: "drop_newest"})
+		logAll(h, _infoLevel, "0")
+		waitInFlight(t, h)
+		logAll(h, _infoLevel, "1", "2", "3", "4")
+
+		stats := h.Stats()
+		assert.Equal(t, 2, stats.Depth)
+		assert.Equal(t, uint64(2), stats.Dropped)
+
+		close(gh.gate)
+		assert.Nil(t, h.Close())
+		assert.Equal(t, []string{"0", "1", "2"}, gh.getLogs())
+		assert.True(t, gh.closed)
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		gh := newGateHandler()
+		h := NewAsync(gh, &AsyncConfig{QueueSize: 2, Overflow: "drop-oldest"})
+		logAll(h, _infoLevel, "0")
+		waitInFlight(t, h)
+		logAll(h, _infoLevel, "1", "2", "3", "4")
+
+		close(gh.gate)
+		assert.Nil(t, h.Close())
+		assert.Equal(t, []string{"0", "3", "4"}, gh.getLogs())
+		assert.Equal(t, uint64(2), h.Stats().Dropped)
+	})
+
+	t.Run("drop below level", func(t *testing.T) {
+		gh := newGateHandler()
+		h := NewAsync(gh, &AsyncConfig{QueueSize: 1, Overflow: "drop_below_level", DropBelowLevel: "warn"})
+		logAll(h, _infoLevel, "0")
+		waitInFlight(t, h)
+		logAll(h, _infoLevel, "1", "2")
+
+		// 不低于warn级别的日志阻塞至队列有空闲位置
+		done := make(chan struct{})
+		go func() {
+			logAll(h, _errorLevel, "3")
+			close(done)
+		}()
+		select {
+		case <-done:
+			t.Fatal("error log should block")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		close(gh.gate)
+		<-done
+		assert.Nil(t, h.Close())
+		assert.Equal(t, []string{"0", "1", "3"}, gh.getLogs())
+		assert.Equal(t, uint64(1), h.Stats().Dropped)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		gh := newGateHandler()
+		h := NewAsync(gh, &AsyncConfig{QueueSize: 1})
+		close(gh.gate)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				logAll(h, _infoLevel, "x", "x", "x")
+			}()
+		}
+		wg.Wait()
+		assert.Nil(t, h.Close())
+
+		stats := h.Stats()
+		assert.Equal(t, 30, len(gh.getLogs()))
+		assert.Equal(t, AsyncStats{Capacity: 1, Enqueued: 30, Written: 30}, stats)
+	})
+}
+
+func TestAsyncHandler_Close(t *testing.T) {
+	gh := newGateHandler()
+	close(gh.gate)
+	h := NewAsync(gh, nil)
+	h.Log(context.Background(), _infoLevel, map[string]interface{}{_log: "before"})
+	assert.Nil(t, h.Close())
+	assert.Nil(t, h.Close())
+
+	// 关闭后的日志直接丢弃
+	h.Log(context.Background(), _infoLevel, map[string]interface{}{_log: "after"})
+	assert.Equal(t, []string{"before"}, gh.getLogs())
+	assert.Equal(t, uint64(1), h.Stats().Dropped)
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	p, err := ParseOverflowPolicy("")
+	assert.Nil(t, err)
+	assert.Equal(t, OverflowBlock, p)
+
+	p, err = ParseOverflowPolicy("Drop-Newest")
+	assert.Nil(t, err)
+	assert.Equal(t, OverflowDropNewest, p)
+
+	_, err = ParseOverflowPolicy("unknown")
+	assert.NotNil(t, err)
+}
+
+func TestAsyncHandler_Reinit(t *testing.T) {
+	user := newGateHandler()
+	conf := func() *Config {
+		return &Config{
+			Config:   &render.Config{Stdout: true},
+			Async:    &AsyncConfig{},
+			Handlers: []Handler{user},
+		}
+	}
+	Init(conf())
+	defer Close()
+	old, ok := h.(*DefaultBatchHandler).handlers[0].(*AsyncHandler)
+	assert.True(t, ok)
+
+	// 重新初始化时关闭原异步处理器，不关闭用户传入的处理器
+	Init(conf())
+	old.mu.Lock()
+	closed := old.closed
+	old.mu.Unlock()
+	assert.True(t, closed)
+	assert.False(t, user.closed)
+	assert.NotEqual(t, old, h.(*DefaultBatchHandler).handlers[0])
+}
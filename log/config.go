@@ -1,6 +1,11 @@
 package log
 
-import render "gitlab.shanhai.int/sre/library/base/logrender"
+import (
+	"gitlab.shanhai.int/sre/library/base/archiver"
+	"gitlab.shanhai.int/sre/library/base/ctime"
+	render "gitlab.shanhai.int/sre/library/base/logrender"
+	"gitlab.shanhai.int/sre/library/base/redact"
+)
 
 // Config log config.
 type Config struct {
@@ -10,8 +15,32 @@ type Config struct {
 	Host string `yaml:"host"`
 	// 打印的最低日志级别
 	V int `yaml:"v"`
+	// 模块打印的最低日志级别，key为模块路径(如database/redis)，value为日志级别名(如debug)
+	// 运行时可通过SetModuleLevel、GinLevelHandler及配置中心动态修改
+	Modules map[string]string `yaml:"modules"`
 	// 过滤日志中指定的key，并用***代替
 	Filter []string `yaml:"filter"`
+	// 脱敏配置，支持键名、路径、正则及部分脱敏，对log包及基于hook的组件日志均生效
+	Redact *redact.Config `yaml:"redact"`
+	// 自动输出到日志的上下文键，key为日志字段名，value为上下文键名(如phone_type)
+	// 对log包及基于hook的组件日志均生效
+	ContextFields map[string]string `yaml:"contextFields"`
+	// 文件路由规则，为空时按日志级别写入info.log、warning.log、error.log
+	FileRoutes []*FileRoute `yaml:"fileRoutes"`
+	// 日志归档配置，文件路由规则开启archive时，分割后的文件上传至对象存储
+	Archiver *archiver.Config `yaml:"archiver"`
+	// 异步写入配置，为空时同步写入
+	Async *AsyncConfig `yaml:"async"`
+	// 网络日志发送配置，支持syslog、tcp、udp及http批量发送
+	Shippers []*ShipperConfig `yaml:"shippers"`
+	// 自定义日志处理器，如sentry.NewLogHandler，不经过异步包装
+	Handlers []Handler `yaml:"-"`
+	// 日志采样配置，为空时不采样
+	Sampling *SamplingConfig `yaml:"sampling"`
+	// 日志限流配置，为空时不限流
+	RateLimit *RateLimitConfig `yaml:"rateLimit"`
+	// 丢弃日志汇总的输出周期，开启采样或限流时生效，默认10s
+	DropReportInterval ctime.Duration `yaml:"dropReportInterval"`
 
 	// 日志配置
 	*render.Config `yaml:",inline"`
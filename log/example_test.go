@@ -2,7 +2,9 @@ package log
 
 import (
 	"context"
+	"time"
 
+	"github.com/pkg/errors"
 	render "gitlab.shanhai.int/sre/library/base/logrender"
 )
 
@@ -52,3 +54,43 @@ func ExampleInfo() {
 		"test2": "test",
 	})
 }
+
+func ExampleSetModuleLevel() {
+	Init(&Config{
+		Config: &render.Config{
+			Stdout:        true,
+			StdoutPattern: "[%T] [%t] [%U] [level: %L] %S %J{m}",
+		},
+		V: int(InfoLevel),
+		// 初始模块日志级别
+		Modules: map[string]string{
+			"database/redis": "warn",
+		},
+	})
+
+	// 运行时开启业务模块的debug日志
+	SetModuleLevel("internal/service", DebugLevel)
+	Debugc(context.Background(), "hello %s", "world")
+	Debugv(context.Background(), map[string]interface{}{
+		"key": "value",
+	})
+
+	// 恢复为默认日志级别
+	DeleteModuleLevel("internal/service")
+}
+
+func ExampleWith() {
+	Init(nil)
+
+	// 直接打印带字段的日志
+	Infow(context.Background(), "hello world",
+		String("user", "u1"),
+		Int("count", 1),
+		Duration("duration", time.Second),
+	)
+
+	// 绑定字段后打印，绑定的字段会追加到每条日志中
+	logger := With(context.Background(), String("request_id", "r1"))
+	logger.Info("start")
+	logger.With(Int("step", 2)).Error("failed", Err(errors.New("bad")))
+}
@@ -0,0 +1,255 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// 字段类型
+type fieldType uint8
+
+const (
+	// 跳过，不打印
+	_skipType fieldType = iota
+	_stringType
+	_int64Type
+	_float64Type
+	_boolType
+	_durationType
+	_timeType
+	_errorType
+	_anyType
+)
+
+// 日志字段
+//
+//	相对于map[string]interface{}，基础类型不需要装箱，渲染时也无需反射
+type Field struct {
+	// 字段名
+	key string
+	// 字段类型
+	typ fieldType
+	// 整数、浮点数、布尔值、时长等数值
+	integer int64
+	// 字符串
+	str string
+	// 其他类型的值
+	iface interface{}
+}
+
+// 字符串字段
+func String(key, val string) Field {
+	return Field{key: key, typ: _stringType, str: val}
+}
+
+// 整数字段
+func Int(key string, val int) Field {
+	return Field{key: key, typ: _int64Type, integer: int64(val)}
+}
+
+// 64位整数字段
+func Int64(key string, val int64) Field {
+	return Field{key: key, typ: _int64Type, integer: val}
+}
+
+// 浮点数字段
+func Float64(key string, val float64) Field {
+	return Field{key: key, typ: _float64Type, integer: int64(math.Float64bits(val))}
+}
+
+// 布尔字段
+func Bool(key string, val bool) Field {
+	var i int64
+	if val {
+		i = 1
+	}
+	return Field{key: key, typ: _boolType, integer: i}
+}
+
+// 时长字段
+//
+//	与map方式保持一致，json形式输出纳秒数，text形式输出时长字符串
+func Duration(key string, val time.Duration) Field {
+	return Field{key: key, typ: _durationType, integer: int64(val)}
+}
+
+// 时间字段
+func Time(key string, val time.Time) Field {
+	return Field{key: key, typ: _timeType, iface: val}
+}
+
+// 错误字段，字段名为error，错误为空时不打印
+func Err(err error) Field {
+	return NamedErr("error", err)
+}
+
+// 指定字段名的错误字段，错误为空时不打印
+func NamedErr(key string, err error) Field {
+	if err == nil {
+		return Field{key: key, typ: _skipType}
+	}
+	return Field{key: key, typ: _errorType, iface: err}
+}
+
+// 任意类型字段
+//
+//	基础类型会自动转换为对应的类型字段，其他类型json形式使用json序列化
+func Any(key string, val interface{}) Field {
+	switch v := val.(type) {
+	case string:
+		return String(key, v)
+	case int:
+		return Int(key, v)
+	case int64:
+		return Int64(key, v)
+	case int32:
+		return Int64(key, int64(v))
+	case float64:
+		return Float64(key, v)
+	case bool:
+		return Bool(key, v)
+	case time.Duration:
+		return Duration(key, v)
+	case time.Time:
+		return Time(key, v)
+	case error:
+		return NamedErr(key, v)
+	}
+	return Field{key: key, typ: _anyType, iface: val}
+}
+
+// 字段名
+func (f Field) Key() string {
+	return f.key
+}
+
+// 字段值
+func (f Field) Value() interface{} {
+	switch f.typ {
+	case _stringType:
+		return f.str
+	case _int64Type:
+		return f.integer
+	case _float64Type:
+		return math.Float64frombits(uint64(f.integer))
+	case _boolType:
+		return f.integer == 1
+	case _durationType:
+		return time.Duration(f.integer)
+	}
+	return f.iface
+}
+
+// 追加text形式的字段值
+func (f Field) appendText(buf []byte) []byte {
+	switch f.typ {
+	case _stringType:
+		return append(buf, f.str...)
+	case _int64Type:
+		return strconv.AppendInt(buf, f.integer, 10)
+	case _float64Type:
+		return strconv.AppendFloat(buf, math.Float64frombits(uint64(f.integer)), 'g', -1, 64)
+	case _boolType:
+		return strconv.AppendBool(buf, f.integer == 1)
+	case _durationType:
+		return append(buf, time.Duration(f.integer).String()...)
+	case _errorType:
+		return append(buf, f.iface.(error).Error()...)
+	}
+	return append(buf, fmt.Sprint(f.iface)...)
+}
+
+// 追加json形式的字段值
+func (f Field) appendJSON(buf []byte) []byte {
+	switch f.typ {
+	case _stringType:
+		return appendJSONString(buf, f.str)
+	case _int64Type, _durationType:
+		return strconv.AppendInt(buf, f.integer, 10)
+	case _float64Type:
+		return appendJSONFloat(buf, math.Float64frombits(uint64(f.integer)))
+	case _boolType:
+		return strconv.AppendBool(buf, f.integer == 1)
+	case _timeType:
+		buf = append(buf, '"')
+		buf = f.iface.(time.Time).AppendFormat(buf, time.RFC3339Nano)
+		return append(buf, '"')
+	case _errorType:
+		return appendJSONString(buf, f.iface.(error).Error())
+	}
+	return appendJSONValue(buf, f.iface)
+}
+
+// 追加json形式的任意值，序列化失败时使用字符串形式
+func appendJSONValue(buf []byte, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return appendJSONString(buf, fmt.Sprint(v))
+	}
+	return append(buf, b...)
+}
+
+// 追加json形式的浮点数，NaN及Inf使用字符串形式
+func appendJSONFloat(buf []byte, v float64) []byte {
+	switch {
+	case math.IsNaN(v):
+		return append(buf, `"NaN"`...)
+	case math.IsInf(v, 1):
+		return append(buf, `"+Inf"`...)
+	case math.IsInf(v, -1):
+		return append(buf, `"-Inf"`...)
+	}
+	abs := math.Abs(v)
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		return strconv.AppendFloat(buf, v, 'e', -1, 64)
+	}
+	return strconv.AppendFloat(buf, v, 'f', -1, 64)
+}
+
+const _hex = "0123456789abcdef"
+
+// 追加json形式的字符串
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				buf = append(buf, '\\', c)
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', _hex[c>>4], _hex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
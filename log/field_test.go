@@ -0,0 +1,216 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	render "gitlab.shanhai.int/sre/library/base/logrender"
+	"gitlab.shanhai.int/sre/library/base/redact"
+)
+
+// 渲染到指定writer的日志处理器
+type writerHandler struct {
+	buf    *bytes.Buffer
+	render render.Render
+}
+
+func newWriterHandler(pattern string) *writerHandler {
+	return &writerHandler{
+		buf:    new(bytes.Buffer),
+		render: render.NewPatternRender(patternMap, pattern),
+	}
+}
+
+func (h *writerHandler) Log(ctx context.Context, lv Level, args map[string]interface{}) {
+	h.render.Render(h.buf, args)
+}
+
+func (h *writerHandler) SetFormat(string) {}
+
+func (h *writerHandler) Close() error { return h.render.Close() }
+
+func TestFieldJSON(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	buf := &bytes.Buffer{}
+	p := render.NewPatternRender(patternMap, "%J{Lm}")
+	p.Render(buf, map[string]interface{}{
+		_level:  _infoLevel.String(),
+		_log:    "hello",
+		"extra": 1,
+		_fields: []Field{
+			String("str", "a\"b\n\x01"),
+			Int("int", 10),
+			Float64("float", 1.5),
+			Float64("nan", math.NaN()),
+			Bool("bool", true),
+			Duration("duration", time.Second),
+			Time("time", now),
+			Err(errors.New("bad")),
+			Err(nil),
+			Any("any", map[string]int{"a": 1}),
+			Any("any_int", 2),
+		},
+	})
+	p.Close()
+
+	jsonMap := make(map[string]interface{})
+	err := json.Unmarshal(buf.Bytes(), &jsonMap)
+	assert.Nil(t, err)
+	msg := jsonMap["message"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"extra":    float64(1),
+		"str":      "a\"b\n\x01",
+		"int":      float64(10),
+		"float":    1.5,
+		"nan":      "NaN",
+		"bool":     true,
+		"duration": float64(time.Second),
+		"time":     "2020-01-02T03:04:05Z",
+		"error":    "bad",
+		"any":      map[string]interface{}{"a": float64(1)},
+		"any_int":  float64(2),
+		"log":      "hello",
+	}, msg)
+}
+
+func TestFieldText(t *testing.T) {
+	buf := &bytes.Buffer{}
+	p := render.NewPatternRender(patternMap, "%M")
+	p.Render(buf, map[string]interface{}{
+		_log:    "hello",
+		_fields: []Field{String("a", "b"), Int("c", 1), Duration("d", time.Millisecond), Err(nil)},
+	})
+	p.Close()
+
+	assert.Equal(t, "a=b c=1 d=1ms hello\n", buf.String())
+}
+
+func TestWith(t *testing.T) {
+	wh := newWriterHandler("%M")
+	old := h
+	h = newDefaultBatchHandler(c, []string{"password"}, wh)
+	defer func() {
+		h = old
+	}()
+
+	parent := With(context.Background(), String("user", "u1"), String("password", "123"))
+	child := parent.With(Int("step", 1))
+
+	child.Info("child", Bool("ok", true))
+	parent.Warn("parent")
+	Infow(context.Background(), "plain", Int("n", 2))
+
+	assert.Equal(t, "user=u1 password=*** step=1 ok=true child\n"+
+		"user=u1 password=*** parent\n"+
+		"n=2 plain\n", wh.buf.String())
+	// 过滤字段不影响绑定的字段
+	assert.Equal(t, "123", parent.fields[1].Value())
+	assert.Equal(t, 2, len(parent.fields))
+}
+
+func TestRedact(t *testing.T) {
+	rd, err := redact.New(&redact.Config{
+		Rules: []*redact.Rule{
+			{Key: "token"},
+			{Regexp: `1[3-9]\d{9}`, KeepPrefix: 3, KeepSuffix: 4},
+		},
+	})
+	assert.Nil(t, err)
+	redact.SetDefault(rd)
+	defer redact.SetDefault(nil)
+
+	wh := newWriterHandler("%M")
+	old := h
+	h = newDefaultBatchHandler(c, nil, wh)
+	defer func() {
+		h = old
+	}()
+
+	logger := With(context.Background(), String("token", "abc"), Int("uid", 1))
+	logger.Info("phone 13812345678", String("mobile", "13812345678"))
+	Infov(context.Background(), map[string]interface{}{"token": "abc", _log: "ok"})
+
+	assert.Equal(t, "token=*** uid=1 mobile=138***5678 phone 138***5678\n"+
+		"token=*** ok\n", wh.buf.String())
+	// 脱敏不影响绑定的字段
+	assert.Equal(t, "abc", logger.fields[0].Value())
+}
+
+func BenchmarkInfov(b *testing.B) {
+	wh := &writerHandler{render: render.NewPatternRender(patternMap, defaultPattern)}
+	hs := newDefaultBatchHandler(c, nil, &discardHandler{wh})
+	ctx := context.Background()
+	err := errors.New("bad")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hs.Log(ctx, _infoLevel, map[string]interface{}{
+			_log:       "hello",
+			"user":     "u1",
+			"count":    i,
+			"duration": time.Second,
+			"error":    err.Error(),
+		})
+	}
+}
+
+func BenchmarkInfow(b *testing.B) {
+	wh := &writerHandler{render: render.NewPatternRender(patternMap, defaultPattern)}
+	old := h
+	h = newDefaultBatchHandler(c, nil, &discardHandler{wh})
+	defer func() {
+		h = old
+	}()
+	ctx := context.Background()
+	err := errors.New("bad")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Infow(ctx, "hello",
+			String("user", "u1"),
+			Int("count", i),
+			Duration("duration", time.Second),
+			Err(err),
+		)
+	}
+}
+
+func BenchmarkLogger(b *testing.B) {
+	wh := &writerHandler{render: render.NewPatternRender(patternMap, defaultPattern)}
+	old := h
+	h = newDefaultBatchHandler(c, nil, &discardHandler{wh})
+	defer func() {
+		h = old
+	}()
+	logger := With(context.Background(), String("user", "u1"))
+	err := errors.New("bad")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info("hello",
+			Int("count", i),
+			Duration("duration", time.Second),
+			Err(err),
+		)
+	}
+}
+
+// 丢弃输出的日志处理器，用于性能测试
+type discardHandler struct {
+	*writerHandler
+}
+
+func (h *discardHandler) Log(ctx context.Context, lv Level, args map[string]interface{}) {
+	addExtraField(ctx, args)
+	h.render.Render(ioutil.Discard, args)
+}
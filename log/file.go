@@ -2,65 +2,304 @@ package log
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"gitlab.shanhai.int/sre/library/base/archiver"
+	"gitlab.shanhai.int/sre/library/base/ctime"
 	"gitlab.shanhai.int/sre/library/base/filewriter"
 	render "gitlab.shanhai.int/sre/library/base/logrender"
 )
 
-// 不同日志级别对应文件索引
+// 默认日志文件名
 const (
-	_infoIdx = iota
-	_warnIdx
-	_errorIdx
-	_totalIdx
+	_infoFile  = "info.log"
+	_warnFile  = "warning.log"
+	_errorFile = "error.log"
 )
 
-// 日志文件名
-var _fileNames = map[int]string{
-	_infoIdx:  "info.log",
-	_warnIdx:  "warning.log",
-	_errorIdx: "error.log",
+// 文件路由规则
+//
+//	按顺序匹配，日志写入第一个匹配的规则对应的文件，Continue为true时继续匹配后续规则
+//	所有条件均为空的规则匹配全部日志，未匹配任何规则的日志不写入文件
+type FileRoute struct {
+	// 文件名，相对于日志文件输出目录
+	File string `yaml:"file"`
+	// 最低日志级别，为空时不限制
+	MinLevel string `yaml:"minLevel"`
+	// 最高日志级别，为空时不限制
+	MaxLevel string `yaml:"maxLevel"`
+	// 日志源路径片段，满足任意一个即可，规则同模块日志级别，如database/sql匹配.../database/sql/下的日志源
+	Sources []string `yaml:"sources"`
+	// 字段匹配，需全部满足，支持日志参数及类型字段，值按字符串比较
+	Fields map[string]string `yaml:"fields"`
+	// 匹配后是否继续匹配后续规则
+	Continue bool `yaml:"continue"`
+
+	// 日志文件切割大小，默认使用Config.RotateSize
+	RotateSize int64 `yaml:"rotateSize"`
+	// 同时存在最大日志文件数，默认使用Config.MaxLogFile
+	MaxLogFile int `yaml:"maxLogFile"`
+	// 分割文件保留天数，0为不限制
+	MaxDays int `yaml:"maxDays"`
+	// 是否gzip压缩分割后的文件
+	Compress bool `yaml:"compress"`
+	// 压缩方式，可选gzip、zstd，不为空时开启压缩
+	CompressMethod string `yaml:"compressMethod"`
+	// 分割周期，如1h为每小时分割，为空时按天分割
+	RotatePeriod ctime.Duration `yaml:"rotatePeriod"`
+	// 分割文件及当前文件的总大小上限，0为不限制
+	MaxTotalSize int64 `yaml:"maxTotalSize"`
+	// 落盘策略，可选none(默认)、interval(定时fsync)、error(错误级别日志写入后立即fsync)
+	Durability string `yaml:"durability"`
+	// interval落盘策略的fsync间隔，默认1s
+	SyncInterval ctime.Duration `yaml:"syncInterval"`
+	// 是否将分割后的文件上传至对象存储，需配置Config.Archiver，确认上传后删除本地文件
+	Archive bool `yaml:"archive"`
+}
+
+// 日志归档器，通过Config.Archiver配置
+var _archiver *archiver.Archiver
+
+// 默认文件路由规则
+var _defaultFileRoutes = []*FileRoute{
+	{File: _warnFile, MinLevel: "warn", MaxLevel: "warn"},
+	{File: _errorFile, MinLevel: "error", MaxLevel: "error"},
+	{File: _infoFile},
+}
+
+// 解析后的文件路由规则
+type fileRoute struct {
+	// 最低日志级别
+	minLevel Level
+	// 最高日志级别
+	maxLevel Level
+	// 日志源路径片段，已规范为/module/形式
+	sources []string
+	// 字段匹配
+	fields map[string]string
+	// 匹配后是否继续
+	next bool
+	// 文件writer
+	fw *filewriter.FileWriter
+}
+
+// 判断日志是否匹配
+func (r *fileRoute) match(lv Level, args map[string]interface{}) bool {
+	if lv < r.minLevel || lv > r.maxLevel {
+		return false
+	}
+
+	if len(r.sources) != 0 {
+		source, _ := args[_source].(string)
+		// 日志源可能为相对路径，统一补齐前缀
+		if !strings.HasPrefix(source, "/") {
+			source = "/" + source
+		}
+		matched := false
+		for _, pattern := range r.sources {
+			if strings.Contains(source, pattern) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for k, expected := range r.fields {
+		v, ok := fieldValue(args, k)
+		if !ok || fmt.Sprint(v) != expected {
+			return false
+		}
+	}
+	return true
+}
+
+// 将日志源路径片段规范为/module/形式
+func sourcePatterns(sources []string) []string {
+	patterns := make([]string, 0, len(sources))
+	for _, source := range sources {
+		if module := normalizeModule(source); module != "" {
+			patterns = append(patterns, "/"+module+"/")
+		}
+	}
+	return patterns
+}
+
+// 获取日志参数或类型字段的值
+func fieldValue(args map[string]interface{}, key string) (interface{}, bool) {
+	if v, ok := args[key]; ok {
+		return v, true
+	}
+	fields, _ := args[_fields].([]Field)
+	for _, f := range fields {
+		if f.key == key {
+			return f.Value(), true
+		}
+	}
+	return nil, false
 }
 
 // 文件处理器
 type FileHandler struct {
 	render render.Render
-	// 文件writer
-	fws [_totalIdx]*filewriter.FileWriter
+	// 文件路由规则
+	routes []*fileRoute
+	// 文件writer，同一文件的规则共用
+	fws []*filewriter.FileWriter
 }
 
-// 新建文件处理器
+// 新建文件处理器，按日志级别写入info.log、warning.log、error.log
 func NewFile(customPattern string, dir string, bufferSize, rotateSize int64, maxLogFile int) *FileHandler {
+	return NewFileWithRoutes(customPattern, dir, bufferSize, rotateSize, maxLogFile, nil)
+}
+
+// 新建带路由规则的文件处理器，routes为空时与NewFile一致
+func NewFileWithRoutes(customPattern string, dir string, bufferSize, rotateSize int64, maxLogFile int,
+	routes []*FileRoute) *FileHandler {
 	if customPattern == "" {
 		customPattern = defaultPattern
 	}
+	if len(routes) == 0 {
+		routes = _defaultFileRoutes
+	}
 	handler := &FileHandler{
-		render: render.NewPatternRender(patternMap, customPattern),
+		render: newRender(customPattern),
 	}
-	for idx, name := range _fileNames {
-		handler.fws[idx] = filewriter.NewSingleFileWriter(
-			filepath.Join(dir, name), bufferSize, rotateSize, maxLogFile)
+
+	fws := make(map[string]*filewriter.FileWriter)
+	for _, route := range routes {
+		if route.File == "" {
+			panic("file route should have file name")
+		}
+		r := &fileRoute{
+			minLevel: _debugLevel,
+			maxLevel: _fatalLevel,
+			sources:  sourcePatterns(route.Sources),
+			fields:   route.Fields,
+			next:     route.Continue,
+		}
+		var err error
+		if route.MinLevel != "" {
+			if r.minLevel, err = ParseLevel(route.MinLevel); err != nil {
+				panic(err)
+			}
+		}
+		if route.MaxLevel != "" {
+			if r.maxLevel, err = ParseLevel(route.MaxLevel); err != nil {
+				panic(err)
+			}
+		}
+
+		// 同一文件以第一个规则的分割及保留配置为准
+		fw, ok := fws[route.File]
+		if !ok {
+			fw = newRouteFileWriter(filepath.Join(dir, route.File), rotateSize, maxLogFile, route)
+			fws[route.File] = fw
+			handler.fws = append(handler.fws, fw)
+		}
+		r.fw = fw
+		handler.routes = append(handler.routes, r)
 	}
 	return handler
 }
 
+// 新建路由规则对应的文件writer
+func newRouteFileWriter(fpath string, rotateSize int64, maxLogFile int, route *FileRoute) *filewriter.FileWriter {
+	if route.RotateSize > 0 {
+		rotateSize = route.RotateSize
+	}
+	if route.MaxLogFile > 0 {
+		maxLogFile = route.MaxLogFile
+	}
+
+	var options []filewriter.OptionFunc
+	if rotateSize > 0 {
+		options = append(options, filewriter.MaxSize(rotateSize))
+	}
+	if maxLogFile > 0 {
+		options = append(options, filewriter.MaxFile(maxLogFile))
+	}
+	if route.MaxDays > 0 {
+		options = append(options, filewriter.MaxAge(time.Duration(route.MaxDays)*24*time.Hour))
+	}
+	if route.Compress {
+		options = append(options, filewriter.Compress(true))
+	}
+	if route.CompressMethod != "" {
+		options = append(options, filewriter.CompressMethod(route.CompressMethod))
+	}
+	if route.RotatePeriod > 0 {
+		options = append(options, filewriter.RotatePeriod(time.Duration(route.RotatePeriod)))
+	}
+	if route.MaxTotalSize > 0 {
+		options = append(options, filewriter.MaxTotalSize(route.MaxTotalSize))
+	}
+	if route.Durability != "" {
+		options = append(options, filewriter.Durability(route.Durability))
+	}
+	if route.SyncInterval > 0 {
+		options = append(options, filewriter.SyncInterval(time.Duration(route.SyncInterval)))
+	}
+	// 文件无法写入时输出至标准错误
+	options = append(options, filewriter.Fallback(os.Stderr))
+	if route.Archive {
+		if _archiver == nil {
+			panic("file route archive requires archiver config")
+		}
+		options = append(options, filewriter.OnRotate(_archiver.OnRotate))
+	}
+
+	fw, err := filewriter.New(fpath, options...)
+	if err != nil {
+		panic(err)
+	}
+	// 归档后删除的文件不再由filewriter清理
+	if route.Archive {
+		_archiver.AddForgetter(fw)
+	}
+	return fw
+}
+
 func (h *FileHandler) Log(ctx context.Context, lv Level, args map[string]interface{}) {
 	// 增加额外参数
 	addExtraField(ctx, args)
 
-	// 写入不同级别文件
-	var w io.Writer
-	switch lv {
-	case _warnLevel:
-		w = h.fws[_warnIdx]
-	case _errorLevel:
-		w = h.fws[_errorIdx]
-	default:
-		w = h.fws[_infoIdx]
-	}
-	h.render.Render(w, args)
+	// 按路由规则写入文件，同一文件只写入一次
+	var written []*filewriter.FileWriter
+	for _, r := range h.routes {
+		if !r.match(lv, args) {
+			continue
+		}
+		if !containsWriter(written, r.fw) {
+			// 错误级别日志按落盘策略等待fsync
+			var w io.Writer = r.fw
+			if lv >= _errorLevel {
+				w = r.fw.SyncWriter()
+			}
+			h.render.Render(w, args)
+			written = append(written, r.fw)
+		}
+		if !r.next {
+			break
+		}
+	}
+}
+
+// 判断文件writer是否已写入
+func containsWriter(fws []*filewriter.FileWriter, fw *filewriter.FileWriter) bool {
+	for _, w := range fws {
+		if w == fw {
+			return true
+		}
+	}
+	return false
 }
 
 func (h *FileHandler) Close() error {
@@ -72,5 +311,5 @@ func (h *FileHandler) Close() error {
 }
 
 func (h *FileHandler) SetFormat(format string) {
-	h.render = render.NewPatternRender(patternMap, format)
+	h.render = newRender(format)
 }
@@ -0,0 +1,110 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.shanhai.int/sre/library/base/archiver"
+)
+
+func TestFileHandler_Routes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-routes")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	h := NewFileWithRoutes("%M", dir, 0, 0, 0, []*FileRoute{
+		{File: "db.log", Sources: []string{"database/"}, Continue: true},
+		{File: "self.log", Sources: []string{"log"}},
+		{File: "order.log", Fields: map[string]string{"biz": "order"}},
+		{File: "error.log", MinLevel: "error"},
+		{File: "info.log", MaxLevel: "warn"},
+	})
+	// 实际的日志源为绝对路径
+	_, file, line, _ := runtime.Caller(0)
+	ctx := context.Background()
+	h.Log(ctx, _infoLevel, map[string]interface{}{_log: "query", _source: "/go/src/app/database/sql/gorm.go:10"})
+	h.Log(ctx, _errorLevel, map[string]interface{}{_log: "query failed", _source: "/go/src/app/database/redis/redis.go:10"})
+	h.Log(ctx, _infoLevel, map[string]interface{}{_log: "self", _source: fmt.Sprintf("%s:%d", file, line)})
+	h.Log(ctx, _infoLevel, map[string]interface{}{_log: "other", _source: "/go/src/app/mydatabase/x.go:10"})
+	h.Log(ctx, _infoLevel, map[string]interface{}{_log: "paid", _fields: []Field{String("biz", "order")}})
+	h.Log(ctx, _warnLevel, map[string]interface{}{_log: "slow"})
+	h.Log(ctx, _fatalLevel, map[string]interface{}{_log: "crash"})
+	assert.Nil(t, h.Close())
+
+	read := func(name string) []string {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		assert.Nil(t, err)
+		return strings.Split(strings.TrimSpace(string(b)), "\n")
+	}
+	assert.Equal(t, []string{"query", "query failed"}, read("db.log"))
+	assert.Equal(t, []string{"self"}, read("self.log"))
+	assert.Equal(t, []string{"biz=order paid"}, read("order.log"))
+	assert.Equal(t, []string{"query failed", "crash"}, read("error.log"))
+	assert.Equal(t, []string{"query", "other", "slow"}, read("info.log"))
+}
+
+func TestFileHandler_DefaultRoutes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-default-routes")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	h := NewFile("%M", dir, 0, 0, 0)
+	ctx := context.Background()
+	for _, lv := range []Level{_debugLevel, _infoLevel, _warnLevel, _errorLevel, _fatalLevel} {
+		h.Log(ctx, lv, map[string]interface{}{_log: lv.String()})
+	}
+	assert.Nil(t, h.Close())
+
+	for name, expected := range map[string]string{
+		"info.log":    "DEBUG\nINFO\nFATAL\n",
+		"warning.log": "WARN\n",
+		"error.log":   "ERROR\n",
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(b))
+	}
+}
+
+func TestNewFileWithRoutes_Invalid(t *testing.T) {
+	assert.Panics(t, func() {
+		NewFileWithRoutes("", os.TempDir(), 0, 0, 0, []*FileRoute{{MinLevel: "error"}})
+	})
+	assert.Panics(t, func() {
+		NewFileWithRoutes("", os.TempDir(), 0, 0, 0, []*FileRoute{{File: "a.log", MinLevel: "bad"}})
+	})
+	// 开启归档时需配置归档器
+	assert.Panics(t, func() {
+		NewFileWithRoutes("", os.TempDir(), 0, 0, 0, []*FileRoute{{File: "a.log", Archive: true}})
+	})
+}
+
+func TestInit_Archiver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-archiver")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	Init(&Config{Archiver: &archiver.Config{Dir: filepath.Join(dir, "archive")}})
+	defer Close()
+	old := _archiver
+	assert.NotNil(t, old)
+
+	// 重新初始化时关闭原归档器，未配置时不再保留
+	Init(nil)
+	assert.Nil(t, _archiver)
+
+	fpath := filepath.Join(dir, "a.log.1")
+	assert.Nil(t, ioutil.WriteFile(fpath, []byte("a"), 0644))
+	old.OnRotate(fpath)
+	old.Close()
+	assert.Equal(t, uint64(0), old.Stats().Uploaded)
+	_, err = os.Stat(fpath)
+	assert.Nil(t, err)
+}
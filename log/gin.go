@@ -0,0 +1,100 @@
+package log
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gitlab.shanhai.int/sre/library/net/errcode"
+)
+
+// 日志级别接口请求参数
+type levelRequest struct {
+	// 模块路径，为空或root时表示默认日志级别
+	Module string `json:"module" form:"module"`
+	// 日志级别名
+	Level string `json:"level" form:"level"`
+}
+
+// 日志级别接口响应数据
+type levelData struct {
+	// 默认日志级别
+	Root string `json:"root"`
+	// 模块日志级别
+	Modules map[string]string `json:"modules"`
+}
+
+// 日志级别接口响应
+type levelResponse struct {
+	// 错误码
+	Code int `json:"errcode"`
+	// 错误信息
+	Message string `json:"errmsg"`
+	// 数据
+	Data interface{} `json:"data,omitempty"`
+}
+
+// 获取当前日志级别数据
+func currentLevelData() *levelData {
+	modules := make(map[string]string)
+	for module, lv := range ModuleLevels() {
+		modules[module] = lv.String()
+	}
+	return &levelData{
+		Root:    GetLevel().String(),
+		Modules: modules,
+	}
+}
+
+// Gin日志级别管理处理器
+//
+//	GET：获取当前日志级别
+//	PUT/POST：设置日志级别，参数为module及level，module为空时设置默认日志级别
+//	DELETE：删除模块日志级别，参数为module
+func GinLevelHandler(c *gin.Context) {
+	badRequest := func(msg string) {
+		c.JSON(http.StatusBadRequest, &levelResponse{
+			Code:    errcode.InvalidParams.FrontendCode(),
+			Message: msg,
+		})
+	}
+
+	var req levelRequest
+	switch c.Request.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		if err := c.ShouldBind(&req); err != nil {
+			badRequest(err.Error())
+			return
+		}
+		lv, err := ParseLevel(req.Level)
+		if err != nil {
+			badRequest(err.Error())
+			return
+		}
+		SetModuleLevel(req.Module, lv)
+		Infoc(c, "log level of module %q changed to %s", req.Module, lv)
+	case http.MethodDelete:
+		if err := c.ShouldBind(&req); err != nil {
+			badRequest(err.Error())
+			return
+		}
+		if normalizeModule(req.Module) == "" {
+			badRequest("module can't be empty")
+			return
+		}
+		DeleteModuleLevel(req.Module)
+		Infoc(c, "log level of module %q deleted", req.Module)
+	default:
+		c.JSON(http.StatusMethodNotAllowed, &levelResponse{
+			Code:    errcode.BadRequest.FrontendCode(),
+			Message: "method not allowed",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, &levelResponse{
+		Code:    errcode.OK.FrontendCode(),
+		Message: errcode.OK.Message(),
+		Data:    currentLevelData(),
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"gitlab.shanhai.int/sre/library/base/redact"
 	"gitlab.shanhai.int/sre/library/base/runtime"
 )
 
@@ -25,8 +26,27 @@ const (
 	_appID = "app_id"
 	// UUID
 	_uuid = "uuid"
+	// 类型字段
+	_fields = "log_fields"
 )
 
+// 日志参数的键名，供自定义日志处理器读取
+const (
+	// 日志消息
+	MessageKey = _log
+	// 日志源
+	SourceKey = _source
+	// UUID
+	UUIDKey = _uuid
+	// 类型字段，值为[]Field
+	FieldsKey = _fields
+)
+
+// 是否是日志库写入的内部键(包括日志消息)，用于自定义日志处理器区分用户传入的参数
+func IsInternalKey(k string) bool {
+	return k == _log || isInternalKey(k)
+}
+
 // 日志处理接口
 type Handler interface {
 	// 打印日志
@@ -47,11 +67,14 @@ type DefaultBatchHandler struct {
 	handlers []Handler
 	// 配置文件
 	config *Config
+	// 采样器，未配置采样及限流时为空
+	sampler *sampler
 }
 
 func (hs DefaultBatchHandler) Log(ctx context.Context, lv Level, args map[string]interface{}) {
-	// 过滤日志级别
-	if hs.config.V > int(lv) {
+	// 低于所有模块最低日志级别的直接过滤
+	levels := _levels.load()
+	if lv < levels.min {
 		return
 	}
 
@@ -65,6 +88,11 @@ func (hs DefaultBatchHandler) Log(ctx context.Context, lv Level, args map[string
 			hasSource = true
 		}
 	}
+	if len(hs.filters) != 0 {
+		if fields, ok := args[_fields].([]Field); ok {
+			args[_fields] = filterFields(fields, hs.filters)
+		}
+	}
 
 	// 如果没有日志源则增加
 	if !hasSource {
@@ -72,7 +100,29 @@ func (hs DefaultBatchHandler) Log(ctx context.Context, lv Level, args map[string
 		args[_source] = fn
 	}
 
-	// 增加必要信息
+	// 根据日志源对应的模块过滤日志级别
+	if len(levels.modules) != 0 {
+		source, _ := args[_source].(string)
+		if lv < levels.level(source) {
+			return
+		}
+	}
+
+	// 采样及限流
+	if hs.sampler != nil && !hs.sampler.allow(lv, args) {
+		return
+	}
+
+	// 脱敏
+	if rd := redact.Default(); rd != nil {
+		args = redactArgs(rd, args)
+	}
+
+	hs.write(ctx, lv, args)
+}
+
+// 增加必要信息并写入所有日志处理器
+func (hs DefaultBatchHandler) write(ctx context.Context, lv Level, args map[string]interface{}) {
 	args[_time] = time.Now()
 	args[_levelValue] = lv
 	args[_level] = lv.String()
@@ -83,6 +133,10 @@ func (hs DefaultBatchHandler) Log(ctx context.Context, lv Level, args map[string
 }
 
 func (hs DefaultBatchHandler) Close() (err error) {
+	// 先关闭采样器，输出剩余的丢弃统计
+	if hs.sampler != nil {
+		hs.sampler.close()
+	}
 	for _, h := range hs.handlers {
 		if e := h.Close(); e != nil {
 			err = errors.WithStack(e)
@@ -97,15 +151,66 @@ func (hs DefaultBatchHandler) SetFormat(format string) {
 	}
 }
 
+// 脱敏日志参数，类型字段单独处理
+func redactArgs(rd *redact.Redactor, args map[string]interface{}) map[string]interface{} {
+	fields, hasFields := args[_fields].([]Field)
+	if hasFields {
+		delete(args, _fields)
+	}
+	args = rd.Map(args)
+	if !hasFields {
+		return args
+	}
+
+	var redacted []Field
+	for i, f := range fields {
+		v, changed := rd.Redact(f.key, f.Value())
+		if !changed {
+			continue
+		}
+		if redacted == nil {
+			redacted = make([]Field, len(fields))
+			copy(redacted, fields)
+		}
+		redacted[i] = Any(f.key, v)
+	}
+	if redacted == nil {
+		redacted = fields
+	}
+	args[_fields] = redacted
+	return args
+}
+
+// 过滤类型字段，有需要过滤的字段时拷贝后再修改，避免影响绑定的字段
+func filterFields(fields []Field, filters map[string]struct{}) []Field {
+	var filtered []Field
+	for i, f := range fields {
+		if _, ok := filters[f.key]; !ok {
+			continue
+		}
+		if filtered == nil {
+			filtered = make([]Field, len(fields))
+			copy(filtered, fields)
+		}
+		filtered[i] = String(f.key, "***")
+	}
+	if filtered == nil {
+		return fields
+	}
+	return filtered
+}
+
 // 新建默认日志批量处理器
 func newDefaultBatchHandler(config *Config, filters []string, handlers ...Handler) *DefaultBatchHandler {
 	set := make(map[string]struct{})
 	for _, k := range filters {
 		set[k] = struct{}{}
 	}
-	return &DefaultBatchHandler{
+	hs := &DefaultBatchHandler{
 		config:   config,
 		filters:  set,
 		handlers: handlers,
 	}
+	hs.sampler = newSampler(config, hs.reportDropped)
+	return hs
 }
@@ -1,5 +1,11 @@
 package log
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // 日志级别
 type Level int
 
@@ -11,6 +17,15 @@ const (
 	_fatalLevel
 )
 
+// 对外暴露的日志级别，用于动态设置日志级别
+const (
+	DebugLevel = _debugLevel
+	InfoLevel  = _infoLevel
+	WarnLevel  = _warnLevel
+	ErrorLevel = _errorLevel
+	FatalLevel = _fatalLevel
+)
+
 // 日志名
 var levelNames = [...]string{
 	_debugLevel: "DEBUG",
@@ -21,5 +36,35 @@ var levelNames = [...]string{
 }
 
 func (l Level) String() string {
+	if l < _debugLevel || l > _fatalLevel {
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
 	return levelNames[l]
 }
+
+// 解析日志级别
+//
+//	支持级别名(不区分大小写，如debug、INFO、warning)及级别数值(如0、1)
+func ParseLevel(s string) (Level, error) {
+	s = strings.TrimSpace(s)
+	if v, err := strconv.Atoi(s); err == nil {
+		if Level(v) < _debugLevel || Level(v) > _fatalLevel {
+			return 0, fmt.Errorf("invalid log level value: %d", v)
+		}
+		return Level(v), nil
+	}
+
+	switch strings.ToUpper(s) {
+	case "DEBUG":
+		return _debugLevel, nil
+	case "INFO":
+		return _infoLevel, nil
+	case "WARN", "WARNING":
+		return _warnLevel, nil
+	case "ERROR":
+		return _errorLevel, nil
+	case "FATAL":
+		return _fatalLevel, nil
+	}
+	return 0, fmt.Errorf("invalid log level: %s", s)
+}
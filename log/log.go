@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"os"
 
+	"gitlab.shanhai.int/sre/library/base/archiver"
+	_context "gitlab.shanhai.int/sre/library/base/context"
 	render "gitlab.shanhai.int/sre/library/base/logrender"
+	"gitlab.shanhai.int/sre/library/base/redact"
 )
 
 var (
@@ -13,6 +16,8 @@ var (
 	h Handler
 	// 配置文件
 	c *Config
+	// Init创建的日志处理器，重新Init时关闭，不包括Config.Handlers
+	_owned []Handler
 )
 
 // 默认初始化
@@ -43,22 +48,92 @@ func Init(conf *Config) {
 		conf.Config = &render.Config{}
 	}
 
+	// 日志归档，原归档器在切换处理器后关闭
+	oldArchiver := _archiver
+	_archiver = nil
+	if conf.Archiver != nil {
+		a, err := archiver.New(conf.Archiver)
+		if err != nil {
+			panic(err)
+		}
+		_archiver = a
+	}
+
 	// 增加日志处理器
+	_renderFormat = conf.Format
 	var hs []Handler
 	if conf.Stdout || isNil {
 		hs = append(hs, NewStdout(conf.StdoutPattern))
 	}
 	if conf.OutDir != "" {
-		hs = append(hs, NewFile(conf.OutPattern,
+		hs = append(hs, NewFileWithRoutes(conf.OutPattern,
 			conf.OutDir,
 			conf.FileBufferSize,
 			conf.RotateSize,
 			conf.MaxLogFile,
+			conf.FileRoutes,
 		))
 	}
 
+	// 异步写入
+	if conf.Async != nil {
+		for i := range hs {
+			hs[i] = NewAsync(hs[i], conf.Async)
+		}
+	}
+	// 网络日志发送，自带发送队列，无需异步包装
+	for _, sc := range conf.Shippers {
+		hs = append(hs, NewShipper(sc))
+	}
+	owned := hs
+	hs = append(hs, conf.Handlers...)
+
+	// 设置日志级别
+	modules := make(map[string]Level, len(conf.Modules))
+	for module, value := range conf.Modules {
+		lv, err := ParseLevel(value)
+		if err != nil {
+			panic(err)
+		}
+		modules[module] = lv
+	}
+	SetLevel(Level(conf.V))
+	ResetModuleLevels(modules)
+
+	// 设置全局脱敏器
+	var rd *redact.Redactor
+	if conf.Redact != nil {
+		var err error
+		rd, err = redact.New(conf.Redact)
+		if err != nil {
+			panic(err)
+		}
+	}
+	redact.SetDefault(rd)
+
+	// 注册上下文日志字段
+	for field, key := range conf.ContextFields {
+		_context.RegisterLogField(field, key)
+	}
+
+	old, oldOwned := h, _owned
 	c = conf
 	h = newDefaultBatchHandler(c, conf.Filter, hs...)
+	_owned = owned
+
+	// 关闭原处理器的采样器，停止其后台协程
+	if old, ok := old.(*DefaultBatchHandler); ok && old.sampler != nil {
+		old.sampler.close()
+	}
+	// 关闭原处理器中Init创建的日志处理器，写入异步队列及发送队列中剩余的日志
+	for _, oh := range oldOwned {
+		oh.Close()
+	}
+
+	// 等待原归档器上传完成
+	if oldArchiver != nil {
+		oldArchiver.Close()
+	}
 }
 
 // 简单Info日志，应优先使用Infoc方法
@@ -82,6 +157,17 @@ func Error(format string, args ...interface{}) {
 	})
 }
 
+// 基础Debug日志
+func Debugc(ctx context.Context, format string, args ...interface{}) {
+	// 避免未开启debug时的格式化开销
+	if _debugLevel < _levels.load().min {
+		return
+	}
+	h.Log(ctx, _debugLevel, map[string]interface{}{
+		_log: fmt.Sprintf(format, args...),
+	})
+}
+
 // 基础Info日志
 func Infoc(ctx context.Context, format string, args ...interface{}) {
 	h.Log(ctx, _infoLevel, map[string]interface{}{
@@ -103,6 +189,11 @@ func Warnc(ctx context.Context, format string, args ...interface{}) {
 	})
 }
 
+// 自定义Debug日志
+func Debugv(ctx context.Context, args map[string]interface{}) {
+	h.Log(ctx, _debugLevel, args)
+}
+
 // 自定义Info日志
 func Infov(ctx context.Context, args map[string]interface{}) {
 	h.Log(ctx, _infoLevel, args)
@@ -127,5 +218,11 @@ func SetFormat(format string) {
 func Close() (err error) {
 	err = h.Close()
 	h = _defaultStdout
+	_owned = nil
+	// 文件关闭后等待归档完成
+	if _archiver != nil {
+		_archiver.Close()
+		_archiver = nil
+	}
 	return
 }
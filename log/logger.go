@@ -0,0 +1,110 @@
+package log
+
+import (
+	"context"
+)
+
+// 带字段的日志记录器
+//
+//	通过With创建，绑定的字段会追加到每条日志中
+type Logger struct {
+	// 上下文
+	ctx context.Context
+	// 绑定的字段
+	fields []Field
+}
+
+// 新建带字段的日志记录器
+func With(ctx context.Context, fields ...Field) *Logger {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &Logger{
+		ctx:    ctx,
+		fields: appendFields(nil, fields),
+	}
+}
+
+// 新建绑定更多字段的子日志记录器，不影响当前日志记录器
+func (l *Logger) With(fields ...Field) *Logger {
+	return &Logger{
+		ctx:    l.ctx,
+		fields: appendFields(l.fields, fields),
+	}
+}
+
+// Debug日志
+func (l *Logger) Debug(msg string, fields ...Field) {
+	logFields(l.ctx, _debugLevel, msg, l.fields, fields)
+}
+
+// Info日志
+func (l *Logger) Info(msg string, fields ...Field) {
+	logFields(l.ctx, _infoLevel, msg, l.fields, fields)
+}
+
+// Warn日志
+func (l *Logger) Warn(msg string, fields ...Field) {
+	logFields(l.ctx, _warnLevel, msg, l.fields, fields)
+}
+
+// Error日志
+func (l *Logger) Error(msg string, fields ...Field) {
+	logFields(l.ctx, _errorLevel, msg, l.fields, fields)
+}
+
+// 带字段的Debug日志
+func Debugw(ctx context.Context, msg string, fields ...Field) {
+	logFields(ctx, _debugLevel, msg, nil, fields)
+}
+
+// 带字段的Info日志
+func Infow(ctx context.Context, msg string, fields ...Field) {
+	logFields(ctx, _infoLevel, msg, nil, fields)
+}
+
+// 带字段的Warn日志
+func Warnw(ctx context.Context, msg string, fields ...Field) {
+	logFields(ctx, _warnLevel, msg, nil, fields)
+}
+
+// 带字段的Error日志
+func Errorw(ctx context.Context, msg string, fields ...Field) {
+	logFields(ctx, _errorLevel, msg, nil, fields)
+}
+
+// 合并字段，总是返回新的切片，避免共用底层数组
+func appendFields(bound, fields []Field) []Field {
+	if len(bound)+len(fields) == 0 {
+		return nil
+	}
+	all := make([]Field, 0, len(bound)+len(fields))
+	all = append(all, bound...)
+	return append(all, fields...)
+}
+
+// 打印带字段的日志
+func logFields(ctx context.Context, lv Level, msg string, bound, fields []Field) {
+	// 低于所有模块最低日志级别的直接过滤，避免构造参数的开销
+	if lv < _levels.load().min {
+		return
+	}
+
+	var all []Field
+	switch {
+	case len(bound) == 0:
+		all = fields
+	case len(fields) == 0:
+		all = bound
+	default:
+		all = appendFields(bound, fields)
+	}
+
+	args := map[string]interface{}{
+		_log: msg,
+	}
+	if len(all) != 0 {
+		args[_fields] = all
+	}
+	h.Log(ctx, lv, args)
+}
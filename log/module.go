@@ -0,0 +1,220 @@
+package log
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 根模块名，用于在配置中心等场景下表示默认日志级别
+const RootModule = "root"
+
+// 全局模块日志级别注册表
+var _levels = newLevelRegistry()
+
+// 模块日志级别
+type moduleLevel struct {
+	// 模块名，如 database/redis
+	module string
+	// 用于匹配日志源的模块路径
+	pattern string
+	// 日志级别
+	level Level
+}
+
+// 日志级别快照，只读
+type levelSnapshot struct {
+	// 默认日志级别
+	root Level
+	// 所有级别中的最低级别，用于快速过滤
+	min Level
+	// 模块日志级别，按模块名长度倒序排列，用于最长匹配
+	modules []moduleLevel
+}
+
+// 模块日志级别注册表
+//
+//	读取时无锁，修改时拷贝快照
+type levelRegistry struct {
+	// 写锁
+	mu sync.Mutex
+	// 当前快照
+	snapshot atomic.Value
+}
+
+// 新建模块日志级别注册表
+func newLevelRegistry() *levelRegistry {
+	r := new(levelRegistry)
+	r.snapshot.Store(&levelSnapshot{})
+	return r
+}
+
+// 获取当前快照
+func (r *levelRegistry) load() *levelSnapshot {
+	return r.snapshot.Load().(*levelSnapshot)
+}
+
+// 更新快照
+func (r *levelRegistry) update(root Level, modules map[string]Level) {
+	s := &levelSnapshot{
+		root:    root,
+		min:     root,
+		modules: make([]moduleLevel, 0, len(modules)),
+	}
+	for module, lv := range modules {
+		s.modules = append(s.modules, moduleLevel{
+			module:  module,
+			pattern: "/" + module + "/",
+			level:   lv,
+		})
+		if lv < s.min {
+			s.min = lv
+		}
+	}
+	sort.Slice(s.modules, func(i, j int) bool {
+		if len(s.modules[i].module) == len(s.modules[j].module) {
+			return s.modules[i].module < s.modules[j].module
+		}
+		return len(s.modules[i].module) > len(s.modules[j].module)
+	})
+	r.snapshot.Store(s)
+}
+
+// 获取模块日志级别字典的拷贝
+func (s *levelSnapshot) moduleMap() map[string]Level {
+	m := make(map[string]Level, len(s.modules))
+	for _, item := range s.modules {
+		m[item.module] = item.level
+	}
+	return m
+}
+
+// 获取日志源对应的日志级别
+func (s *levelSnapshot) level(source string) Level {
+	if len(s.modules) == 0 || source == "" {
+		return s.root
+	}
+	// 日志源可能为相对路径，统一补齐前缀
+	if !strings.HasPrefix(source, "/") {
+		source = "/" + source
+	}
+	for _, item := range s.modules {
+		if strings.Contains(source, item.pattern) {
+			return item.level
+		}
+	}
+	return s.root
+}
+
+// 规范模块名
+func normalizeModule(module string) string {
+	return strings.Trim(strings.TrimSpace(module), "/")
+}
+
+// 设置默认日志级别
+func SetLevel(lv Level) {
+	_levels.mu.Lock()
+	defer _levels.mu.Unlock()
+
+	s := _levels.load()
+	_levels.update(lv, s.moduleMap())
+}
+
+// 获取默认日志级别
+func GetLevel() Level {
+	return _levels.load().root
+}
+
+// 设置模块日志级别
+//
+//	module为模块路径，如 database/redis、net/httpclient、业务项目中的 internal/service
+//	日志源(文件路径)中包含该模块路径时生效，多个模块同时匹配时以最长的模块路径为准
+func SetModuleLevel(module string, lv Level) {
+	module = normalizeModule(module)
+	if module == "" || module == RootModule {
+		SetLevel(lv)
+		return
+	}
+
+	_levels.mu.Lock()
+	defer _levels.mu.Unlock()
+
+	s := _levels.load()
+	m := s.moduleMap()
+	m[module] = lv
+	_levels.update(s.root, m)
+}
+
+// 删除模块日志级别，删除后使用默认日志级别
+func DeleteModuleLevel(module string) {
+	module = normalizeModule(module)
+
+	_levels.mu.Lock()
+	defer _levels.mu.Unlock()
+
+	s := _levels.load()
+	m := s.moduleMap()
+	delete(m, module)
+	_levels.update(s.root, m)
+}
+
+// 重置全部模块日志级别
+func ResetModuleLevels(levels map[string]Level) {
+	m := make(map[string]Level, len(levels))
+	for module, lv := range levels {
+		module = normalizeModule(module)
+		if module == "" || module == RootModule {
+			continue
+		}
+		m[module] = lv
+	}
+
+	_levels.mu.Lock()
+	defer _levels.mu.Unlock()
+
+	_levels.update(_levels.load().root, m)
+}
+
+// 获取全部模块日志级别
+func ModuleLevels() map[string]Level {
+	return _levels.load().moduleMap()
+}
+
+// 获取日志源对应的日志级别
+func GetModuleLevel(source string) Level {
+	return _levels.load().level(source)
+}
+
+// 判断日志源在指定级别下是否需要打印
+func Enabled(source string, lv Level) bool {
+	return lv >= _levels.load().level(source)
+}
+
+// 通过字符串字典更新日志级别，用于配置中心等场景
+//
+//	key为模块路径，key为root时表示默认日志级别，value为日志级别
+//	会完全替换当前的模块日志级别，未包含root时默认日志级别保持不变
+func UpdateLevels(levels map[string]string) error {
+	root := GetLevel()
+	modules := make(map[string]Level, len(levels))
+	for module, value := range levels {
+		lv, err := ParseLevel(value)
+		if err != nil {
+			return err
+		}
+
+		module = normalizeModule(module)
+		if module == "" || module == RootModule {
+			root = lv
+			continue
+		}
+		modules[module] = lv
+	}
+
+	_levels.mu.Lock()
+	defer _levels.mu.Unlock()
+
+	_levels.update(root, modules)
+	return nil
+}
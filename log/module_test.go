@@ -0,0 +1,179 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	httpUtil "gitlab.shanhai.int/sre/library/base/net"
+)
+
+// 用于测试的日志处理器，记录收到的日志
+type recordHandler struct {
+	logs []map[string]interface{}
+}
+
+func (h *recordHandler) Log(ctx context.Context, lv Level, args map[string]interface{}) {
+	h.logs = append(h.logs, args)
+}
+
+func (h *recordHandler) SetFormat(string) {}
+
+func (h *recordHandler) Close() error { return nil }
+
+func TestParseLevel(t *testing.T) {
+	t.Run("name", func(t *testing.T) {
+		lv, err := ParseLevel("debug")
+		assert.Nil(t, err)
+		assert.Equal(t, DebugLevel, lv)
+
+		lv, err = ParseLevel(" Warning ")
+		assert.Nil(t, err)
+		assert.Equal(t, WarnLevel, lv)
+	})
+
+	t.Run("value", func(t *testing.T) {
+		lv, err := ParseLevel("3")
+		assert.Nil(t, err)
+		assert.Equal(t, ErrorLevel, lv)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParseLevel("verbose")
+		assert.NotNil(t, err)
+		_, err = ParseLevel("10")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestSetModuleLevel(t *testing.T) {
+	defer func() {
+		SetLevel(DebugLevel)
+		ResetModuleLevels(nil)
+	}()
+
+	SetLevel(InfoLevel)
+	SetModuleLevel("/database/redis/", DebugLevel)
+	SetModuleLevel("database", ErrorLevel)
+
+	t.Run("longest match", func(t *testing.T) {
+		assert.Equal(t, DebugLevel, GetModuleLevel("/go/pkg/mod/gitlab.shanhai.int/sre/library/database/redis/connect.go:40"))
+		assert.Equal(t, ErrorLevel, GetModuleLevel("/go/pkg/mod/gitlab.shanhai.int/sre/library/database/mongo/connect.go:40"))
+		assert.Equal(t, InfoLevel, GetModuleLevel("/app/internal/service/user.go:12"))
+		assert.Equal(t, InfoLevel, GetModuleLevel(""))
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		assert.True(t, Enabled("/library/database/redis/", DebugLevel))
+		assert.False(t, Enabled("/library/database/mongo/", WarnLevel))
+		assert.False(t, Enabled("/app/main.go:1", DebugLevel))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		DeleteModuleLevel("database/redis")
+		assert.Equal(t, ErrorLevel, GetModuleLevel("/library/database/redis/connect.go:40"))
+		assert.Equal(t, map[string]Level{"database": ErrorLevel}, ModuleLevels())
+	})
+
+	t.Run("update", func(t *testing.T) {
+		err := UpdateLevels(map[string]string{
+			RootModule:       "warn",
+			"net/httpclient": "debug",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, WarnLevel, GetLevel())
+		assert.Equal(t, map[string]Level{"net/httpclient": DebugLevel}, ModuleLevels())
+
+		err = UpdateLevels(map[string]string{"net/httpclient": "bad"})
+		assert.NotNil(t, err)
+		assert.Equal(t, map[string]Level{"net/httpclient": DebugLevel}, ModuleLevels())
+	})
+}
+
+func TestDefaultBatchHandler_ModuleLevel(t *testing.T) {
+	defer func() {
+		SetLevel(DebugLevel)
+		ResetModuleLevels(nil)
+	}()
+
+	rh := new(recordHandler)
+	hs := newDefaultBatchHandler(c, nil, rh)
+	ctx := context.Background()
+
+	SetLevel(WarnLevel)
+	SetModuleLevel("internal/service", DebugLevel)
+
+	hs.Log(ctx, _infoLevel, map[string]interface{}{_source: "/app/internal/service/user.go:12"})
+	hs.Log(ctx, _debugLevel, map[string]interface{}{_source: "/app/internal/dao/user.go:12"})
+	hs.Log(ctx, _warnLevel, map[string]interface{}{_source: "/app/internal/dao/user.go:12"})
+	hs.Log(ctx, _infoLevel, map[string]interface{}{})
+
+	assert.Equal(t, 2, len(rh.logs))
+	assert.Equal(t, "INFO", rh.logs[0][_level])
+	assert.Equal(t, "WARN", rh.logs[1][_level])
+
+	ResetModuleLevels(nil)
+	hs.Log(ctx, _infoLevel, map[string]interface{}{_source: "/app/internal/service/user.go:12"})
+	assert.Equal(t, 2, len(rh.logs))
+}
+
+func TestGinLevelHandler(t *testing.T) {
+	defer func() {
+		SetLevel(DebugLevel)
+		ResetModuleLevels(nil)
+	}()
+
+	router := gin.New()
+	router.Any("/log/level", GinLevelHandler)
+	headers := http.Header{"Content-Type": []string{"application/json"}}
+
+	getData := func(body []byte) levelData {
+		resp := struct {
+			Code int       `json:"errcode"`
+			Data levelData `json:"data"`
+		}{}
+		err := json.Unmarshal(body, &resp)
+		assert.Nil(t, err)
+		return resp.Data
+	}
+
+	t.Run("set", func(t *testing.T) {
+		r, err := httpUtil.TestGinJsonRequest(router, http.MethodPut, "/log/level", headers,
+			map[string]string{"module": "database/redis", "level": "debug"}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, r.Code)
+		assert.Equal(t, "DEBUG", getData(r.Body.Bytes()).Modules["database/redis"])
+
+		r, err = httpUtil.TestGinJsonRequest(router, http.MethodPost, "/log/level", headers,
+			map[string]string{"level": "error"}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, r.Code)
+		assert.Equal(t, "ERROR", getData(r.Body.Bytes()).Root)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		r, err := httpUtil.TestGinJsonRequest(router, http.MethodGet, "/log/level", nil, nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, r.Code)
+		data := getData(r.Body.Bytes())
+		assert.Equal(t, "ERROR", data.Root)
+		assert.Equal(t, map[string]string{"database/redis": "DEBUG"}, data.Modules)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		r, err := httpUtil.TestGinJsonRequest(router, http.MethodPut, "/log/level", headers,
+			map[string]string{"module": "database/redis", "level": "verbose"}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, r.Code)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		r, err := httpUtil.TestGinJsonRequest(router, http.MethodDelete, "/log/level?module=database/redis", nil, nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, r.Code)
+		assert.Equal(t, 0, len(getData(r.Body.Bytes()).Modules))
+	})
+}
@@ -2,6 +2,7 @@ package log
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
@@ -39,7 +40,7 @@ func title(args render.PatternArgs) render.PatternResult {
 // 是否是内部键，内部键不打印到消息主体中
 func isInternalKey(k string) bool {
 	switch k {
-	case _level, _levelValue, _time, _source, _appID, _uuid:
+	case _level, _levelValue, _time, _source, _appID, _uuid, _fields, render.ContextFieldsArgKey:
 		return true
 	}
 	return false
@@ -47,6 +48,11 @@ func isInternalKey(k string) bool {
 
 // 消息主体,用于json形式
 func jsonMessage(args render.PatternArgs) render.PatternResult {
+	// 带类型字段时直接序列化，无需构造中间map
+	if fields, ok := args[_fields].([]Field); ok {
+		return render.NewPatternResult("message", &fieldMessage{args: args, fields: fields})
+	}
+
 	var m string
 	msgMap := make(map[string]interface{})
 
@@ -71,6 +77,63 @@ func jsonMessage(args render.PatternArgs) render.PatternResult {
 	return render.NewPatternResult("message", msgMap)
 }
 
+// 带类型字段的json消息主体
+type fieldMessage struct {
+	// 日志参数
+	args render.PatternArgs
+	// 类型字段
+	fields []Field
+}
+
+// 按顺序输出非内部键值对、类型字段及消息主体
+func (m *fieldMessage) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 0, 256)
+	buf = append(buf, '{')
+
+	var keys []string
+	for k := range m.args {
+		if k == _log || isInternalKey(k) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf = appendJSONKey(buf, k)
+		buf = appendJSONValue(buf, m.args[k])
+	}
+
+	for _, f := range m.fields {
+		if f.typ == _skipType {
+			continue
+		}
+		buf = appendJSONKey(buf, f.key)
+		buf = f.appendJSON(buf)
+	}
+
+	if v, ok := m.args[_log]; ok {
+		s, ok := v.(string)
+		if !ok {
+			s = fmt.Sprint(v)
+		}
+		if s != "" {
+			buf = appendJSONKey(buf, "log")
+			buf = appendJSONString(buf, s)
+		}
+	}
+
+	return append(buf, '}'), nil
+}
+
+// 追加json的键，非首个键时追加逗号
+func appendJSONKey(buf []byte, key string) []byte {
+	if buf[len(buf)-1] != '{' {
+		buf = append(buf, ',')
+	}
+	buf = appendJSONString(buf, key)
+	return append(buf, ':')
+}
+
 // 消息切片对象池
 var messageSlicePool = sync.Pool{
 	New: func() interface{} {
@@ -95,6 +158,18 @@ func textMessage(args render.PatternArgs) render.PatternResult {
 		}
 		s = append(s, fmt.Sprintf("%s=%v", k, v))
 	}
+	// 添加类型字段
+	if fields, ok := args[_fields].([]Field); ok {
+		for _, f := range fields {
+			if f.typ == _skipType {
+				continue
+			}
+			b := make([]byte, 0, len(f.key)+16)
+			b = append(b, f.key...)
+			b = append(b, '=')
+			s = append(s, string(f.appendText(b)))
+		}
+	}
 	// 追加消息主体
 	msg := strings.Join(append(s, m), " ")
 
@@ -2,11 +2,13 @@ package log
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"strings"
 	"testing"
 	"time"
 
+	_context "gitlab.shanhai.int/sre/library/base/context"
 	render "gitlab.shanhai.int/sre/library/base/logrender"
 
 	"github.com/stretchr/testify/assert"
@@ -55,3 +57,47 @@ func TestBadSymbol(t *testing.T) {
 
 	assert.Equal(t, "%12 %% %xd 2233\n", buf.String())
 }
+
+func TestContextFields(t *testing.T) {
+	ctx := _context.WithLogField(context.Background(), "tenant", "t1")
+	args := map[string]interface{}{_log: "hello"}
+	addExtraField(ctx, args)
+
+	p := render.NewPatternRender(patternMap, "%M")
+	assert.Equal(t, "hello tenant=t1\n", p.RenderString(args))
+
+	p = render.NewPatternRender(patternMap, "%J{m}")
+	jsonMap := make(map[string]interface{})
+	err := json.Unmarshal([]byte(p.RenderString(args)), &jsonMap)
+	assert.Nil(t, err)
+	assert.Equal(t, "t1", jsonMap["tenant"])
+	assert.Equal(t, map[string]interface{}{"log": "hello"}, jsonMap["message"])
+}
+
+func TestRenderFormat(t *testing.T) {
+	args := map[string]interface{}{
+		_log:    "hello",
+		_level:  _infoLevel.String(),
+		_source: "a.go:1",
+		_appID:  "demo",
+		_uuid:   "u1",
+		_fields: []Field{String("user", "u 1"), Int("n", 2)},
+	}
+
+	p := render.NewRender(render.FormatLogfmt, patternMap, "%J{tLSm}")
+	assert.Equal(t, `title=LOG level=INFO source=a.go:1 log=hello n=2 user="u 1"`+"\n", p.RenderString(args))
+
+	p = render.NewRender(render.FormatOTel, patternMap, defaultPattern)
+	m := make(map[string]interface{})
+	err := json.Unmarshal([]byte(p.RenderString(args)), &m)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", m["body"])
+	assert.Equal(t, "INFO", m["severity_text"])
+	assert.Equal(t, float64(9), m["severity_number"])
+	assert.Equal(t, map[string]interface{}{"service.name": "demo"}, m["resource"])
+	attributes := m["attributes"].(map[string]interface{})
+	assert.Equal(t, "u 1", attributes["user"])
+	assert.Equal(t, float64(2), attributes["n"])
+	assert.Equal(t, "u1", attributes["uuid"])
+	assert.Equal(t, "a.go:1", attributes["source"])
+}
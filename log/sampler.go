@@ -0,0 +1,288 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gitlab.shanhai.int/sre/library/base/ctime"
+	"gitlab.shanhai.int/sre/library/base/ratelimit"
+)
+
+const (
+	// 默认采样周期
+	_defaultSamplingInterval = time.Second
+	// 默认丢弃日志汇总周期
+	_defaultDropReportInterval = 10 * time.Second
+	// 每个日志级别的采样计数器数量
+	_samplingCounters = 4096
+	// 日志级别数量
+	_levelCount = int(_fatalLevel) + 1
+)
+
+// 日志采样配置
+//
+//	每个采样周期内，同一日志源及消息的日志先打印前First条，之后每Thereafter条打印1条
+type SamplingConfig struct {
+	// 采样周期，默认1s
+	Interval ctime.Duration `yaml:"interval"`
+	// 每个周期内打印的前N条，小于等于0时不采样
+	First int `yaml:"first"`
+	// 超过First后每M条打印1条，小于等于0时全部丢弃
+	Thereafter int `yaml:"thereafter"`
+}
+
+// 日志限流配置
+type RateLimitConfig struct {
+	// 每秒允许打印的日志条数，key为日志级别名(如error)，未配置的级别不限流
+	Rates map[string]float64 `yaml:"rates"`
+	// 令牌桶容量，默认与每秒条数相同
+	Burst int `yaml:"burst"`
+}
+
+// 日志丢弃统计
+type DropStats struct {
+	// 因采样丢弃的条数
+	Sampled uint64
+	// 因限流丢弃的条数
+	Limited uint64
+}
+
+// 采样计数器
+type samplingCounter struct {
+	// 重置时间
+	resetAt int64
+	// 计数
+	count uint64
+}
+
+// 增加计数，超过采样周期时重置
+func (c *samplingCounter) inc(now int64, interval time.Duration) uint64 {
+	resetAfter := atomic.LoadInt64(&c.resetAt)
+	if resetAfter > now {
+		return atomic.AddUint64(&c.count, 1)
+	}
+
+	atomic.StoreUint64(&c.count, 1)
+	newResetAfter := now + int64(interval)
+	if !atomic.CompareAndSwapInt64(&c.resetAt, resetAfter, newResetAfter) {
+		// 其他协程已重置
+		return atomic.AddUint64(&c.count, 1)
+	}
+	return 1
+}
+
+// 日志采样器，包含采样及限流
+type sampler struct {
+	// 采样周期
+	interval time.Duration
+	// 每个周期内打印的前N条
+	first uint64
+	// 超过first后每M条打印1条
+	thereafter uint64
+	// 采样计数器，按日志级别区分
+	counters *[_levelCount][_samplingCounters]samplingCounter
+
+	// 各日志级别的令牌桶，为空时不限流
+	buckets [_levelCount]*ratelimit.TokenBucket
+
+	// 因采样丢弃的条数
+	sampled uint64
+	// 因限流丢弃的条数
+	limited uint64
+	// 累计因采样丢弃的条数
+	totalSampled uint64
+	// 累计因限流丢弃的条数
+	totalLimited uint64
+
+	// 丢弃日志汇总周期
+	reportInterval time.Duration
+	// 汇总方法
+	report func(dropped DropStats, interval time.Duration)
+	// 关闭信号
+	done chan struct{}
+	// 关闭等待
+	wg sync.WaitGroup
+	// 关闭一次
+	closeOnce sync.Once
+}
+
+// 新建日志采样器，未配置采样及限流时返回nil
+func newSampler(config *Config, report func(dropped DropStats, interval time.Duration)) *sampler {
+	if config == nil {
+		return nil
+	}
+	samplingEnabled := config.Sampling != nil && config.Sampling.First > 0
+	limitEnabled := config.RateLimit != nil && len(config.RateLimit.Rates) != 0
+	if !samplingEnabled && !limitEnabled {
+		return nil
+	}
+
+	s := &sampler{
+		reportInterval: time.Duration(config.DropReportInterval),
+		report:         report,
+		done:           make(chan struct{}),
+	}
+	if s.reportInterval <= 0 {
+		s.reportInterval = _defaultDropReportInterval
+	}
+
+	if samplingEnabled {
+		s.interval = time.Duration(config.Sampling.Interval)
+		if s.interval <= 0 {
+			s.interval = _defaultSamplingInterval
+		}
+		s.first = uint64(config.Sampling.First)
+		if config.Sampling.Thereafter > 0 {
+			s.thereafter = uint64(config.Sampling.Thereafter)
+		}
+		s.counters = new([_levelCount][_samplingCounters]samplingCounter)
+	}
+
+	if limitEnabled {
+		for name, rate := range config.RateLimit.Rates {
+			lv, err := ParseLevel(name)
+			if err != nil {
+				panic(err)
+			}
+			if rate <= 0 {
+				continue
+			}
+			s.buckets[lv] = ratelimit.NewTokenBucket(rate, config.RateLimit.Burst)
+		}
+	}
+
+	s.wg.Add(1)
+	go s.daemon()
+
+	return s
+}
+
+// 规范日志级别，用于数组下标
+func levelIndex(lv Level) int {
+	if lv < _debugLevel {
+		return int(_debugLevel)
+	}
+	if lv > _fatalLevel {
+		return int(_fatalLevel)
+	}
+	return int(lv)
+}
+
+// 判断日志是否需要打印
+func (s *sampler) allow(lv Level, args map[string]interface{}) bool {
+	idx := levelIndex(lv)
+
+	if s.counters != nil {
+		source, _ := args[_source].(string)
+		msg, _ := args[_log].(string)
+
+		counter := &s.counters[idx][samplingHash(source, msg)%_samplingCounters]
+
+		n := counter.inc(time.Now().UnixNano(), s.interval)
+		if n > s.first && (s.thereafter == 0 || (n-s.first)%s.thereafter != 0) {
+			atomic.AddUint64(&s.sampled, 1)
+			atomic.AddUint64(&s.totalSampled, 1)
+			return false
+		}
+	}
+
+	if b := s.buckets[idx]; b != nil && !b.Allow(time.Now()) {
+		atomic.AddUint64(&s.limited, 1)
+		atomic.AddUint64(&s.totalLimited, 1)
+		return false
+	}
+
+	return true
+}
+
+// 计算日志源及消息的FNV-1a哈希值
+func samplingHash(source, msg string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	hash := uint32(offset32)
+	for i := 0; i < len(source); i++ {
+		hash ^= uint32(source[i])
+		hash *= prime32
+	}
+	// 分隔日志源及消息
+	hash *= prime32
+	for i := 0; i < len(msg); i++ {
+		hash ^= uint32(msg[i])
+		hash *= prime32
+	}
+	return hash
+}
+
+// 定时汇总丢弃的日志
+func (s *sampler) daemon() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.reportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.done:
+			s.flush()
+			return
+		}
+	}
+}
+
+// 输出上个周期丢弃的日志条数
+func (s *sampler) flush() {
+	dropped := DropStats{
+		Sampled: atomic.SwapUint64(&s.sampled, 0),
+		Limited: atomic.SwapUint64(&s.limited, 0),
+	}
+	if dropped.Sampled+dropped.Limited == 0 || s.report == nil {
+		return
+	}
+	s.report(dropped, s.reportInterval)
+}
+
+// 累计丢弃的日志条数
+func (s *sampler) stats() DropStats {
+	return DropStats{
+		Sampled: atomic.LoadUint64(&s.totalSampled),
+		Limited: atomic.LoadUint64(&s.totalLimited),
+	}
+}
+
+// 关闭采样器，并输出剩余的丢弃统计
+func (s *sampler) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+	})
+}
+
+// 丢弃日志汇总参数
+func dropReportArgs(dropped DropStats, interval time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		_log: fmt.Sprintf("%d messages dropped in last %s",
+			dropped.Sampled+dropped.Limited, interval),
+		"dropped_by_sampling":   dropped.Sampled,
+		"dropped_by_rate_limit": dropped.Limited,
+		_source:                 "log/sampler",
+	}
+}
+
+// 获取默认日志处理器累计丢弃的日志条数
+func GetDropStats() DropStats {
+	if hs, ok := h.(*DefaultBatchHandler); ok && hs.sampler != nil {
+		return hs.sampler.stats()
+	}
+	return DropStats{}
+}
+
+// 输出丢弃日志汇总，不经过采样及限流
+func (hs DefaultBatchHandler) reportDropped(dropped DropStats, interval time.Duration) {
+	hs.write(context.Background(), _warnLevel, dropReportArgs(dropped, interval))
+}
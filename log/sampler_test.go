@@ -0,0 +1,92 @@
+package log
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.shanhai.int/sre/library/base/ctime"
+)
+
+func TestSampler_Sampling(t *testing.T) {
+	rh := new(recordHandler)
+	hs := newDefaultBatchHandler(&Config{
+		Sampling: &SamplingConfig{
+			Interval:   ctime.Duration(time.Minute),
+			First:      3,
+			Thereafter: 5,
+		},
+	}, nil, rh)
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		hs.Log(ctx, _errorLevel, map[string]interface{}{_log: "same", _source: "a.go:1"})
+	}
+	// 不同消息单独计数
+	hs.Log(ctx, _errorLevel, map[string]interface{}{_log: "other", _source: "a.go:1"})
+	// 不同级别单独计数
+	hs.Log(ctx, _infoLevel, map[string]interface{}{_log: "same", _source: "a.go:1"})
+
+	// 前3条，及第8、13、18条
+	assert.Equal(t, 8, len(rh.logs))
+	assert.Equal(t, DropStats{Sampled: 14}, hs.sampler.stats())
+
+	assert.Nil(t, hs.Close())
+	// 关闭时输出丢弃汇总
+	assert.Equal(t, 9, len(rh.logs))
+	summary := rh.logs[8]
+	assert.Equal(t, "WARN", summary[_level])
+	assert.Equal(t, uint64(14), summary["dropped_by_sampling"])
+	assert.Equal(t, "14 messages dropped in last 10s", summary[_log])
+}
+
+func TestSampler_RateLimit(t *testing.T) {
+	rh := new(recordHandler)
+	hs := newDefaultBatchHandler(&Config{
+		RateLimit: &RateLimitConfig{
+			Rates: map[string]float64{"error": 0.001},
+			Burst: 2,
+		},
+		DropReportInterval: ctime.Duration(time.Hour),
+	}, nil, rh)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		hs.Log(ctx, _errorLevel, map[string]interface{}{_log: "error", _source: "a.go:1"})
+		hs.Log(ctx, _infoLevel, map[string]interface{}{_log: "info", _source: "a.go:1"})
+	}
+
+	assert.Equal(t, 7, len(rh.logs))
+	assert.Equal(t, DropStats{Limited: 3}, hs.sampler.stats())
+
+	assert.Nil(t, hs.Close())
+	assert.Equal(t, uint64(3), rh.logs[7]["dropped_by_rate_limit"])
+	assert.Equal(t, "3 messages dropped in last 1h0m0s", rh.logs[7][_log])
+}
+
+func TestSampler_Disabled(t *testing.T) {
+	hs := newDefaultBatchHandler(&Config{Sampling: &SamplingConfig{}}, nil, new(recordHandler))
+	assert.Nil(t, hs.sampler)
+}
+
+func TestSampler_Reinit(t *testing.T) {
+	conf := func() *Config {
+		return &Config{
+			Sampling: &SamplingConfig{First: 1},
+			Handlers: []Handler{new(recordHandler)},
+		}
+	}
+	Init(conf())
+	defer Close()
+	old := h.(*DefaultBatchHandler).sampler
+
+	// 重新初始化时关闭原采样器
+	Init(conf())
+	select {
+	case <-old.done:
+	default:
+		t.Fatal("old sampler not closed")
+	}
+	assert.NotEqual(t, old, h.(*DefaultBatchHandler).sampler)
+}
@@ -0,0 +1,586 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	stdlog "log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"gitlab.shanhai.int/sre/library/base/ctime"
+	render "gitlab.shanhai.int/sre/library/base/logrender"
+)
+
+// 日志发送类型
+const (
+	ShipperSyslog = "syslog"
+	ShipperTCP    = "tcp"
+	ShipperUDP    = "udp"
+	ShipperHTTP   = "http"
+)
+
+const (
+	// 默认批量大小
+	_defaultShipBatchSize = 100
+	// 默认批量发送间隔
+	_defaultShipFlushInterval = time.Second
+	// 默认队列大小
+	_defaultShipQueueSize = 4096
+	// 默认超时时间
+	_defaultShipTimeout = 5 * time.Second
+	// 默认最小重试间隔
+	_defaultShipMinBackoff = 100 * time.Millisecond
+	// 默认最大重试间隔
+	_defaultShipMaxBackoff = 30 * time.Second
+	// 默认落盘文件最大大小
+	_defaultMaxSpillSize = 100 * 1024 * 1024
+	// 单次补发落盘日志的最大批次数，避免长时间阻塞后台协程
+	_maxReplayBatches = 10
+)
+
+// 网络日志发送配置
+type ShipperConfig struct {
+	// 类型，可选syslog、tcp、udp、http
+	Type string `yaml:"type"`
+	// 发送地址，syslog、tcp、udp为host:port，http为完整url
+	Addr string `yaml:"addr"`
+	// 渲染模版，默认为 %J{tTLUSm}
+	Pattern string `yaml:"pattern"`
+
+	// syslog传输协议，可选tcp、udp，默认udp
+	Network string `yaml:"network"`
+	// syslog facility，默认1(user-level)
+	Facility int `yaml:"facility"`
+	// syslog应用名，默认为进程名
+	AppName string `yaml:"appName"`
+	// syslog主机名，默认为当前主机名
+	Hostname string `yaml:"hostname"`
+
+	// http请求体格式，可选lines(json lines)、loki、es_bulk，默认lines
+	Format string `yaml:"format"`
+	// es_bulk格式的索引名
+	Index string `yaml:"index"`
+	// loki格式的stream标签
+	Labels map[string]string `yaml:"labels"`
+	// http请求头
+	Headers map[string]string `yaml:"headers"`
+
+	// 批量发送的最大条数，默认100
+	BatchSize int `yaml:"batchSize"`
+	// 批量发送间隔，默认1s
+	FlushInterval ctime.Duration `yaml:"flushInterval"`
+	// 待发送队列大小，队列满时丢弃新日志，默认4096
+	QueueSize int `yaml:"queueSize"`
+	// 连接及发送超时时间，默认5s
+	Timeout ctime.Duration `yaml:"timeout"`
+	// 发送失败后的最小重试间隔，默认100ms
+	MinBackoff ctime.Duration `yaml:"minBackoff"`
+	// 发送失败后的最大重试间隔，默认30s
+	MaxBackoff ctime.Duration `yaml:"maxBackoff"`
+	// 采集端不可用时的本地落盘目录，为空时直接丢弃
+	SpillDir string `yaml:"spillDir"`
+	// 落盘文件最大大小，超出后丢弃，默认100MB
+	MaxSpillSize int64 `yaml:"maxSpillSize"`
+}
+
+// 网络日志发送统计
+type ShipperStats struct {
+	// 累计发送成功条数
+	Sent uint64
+	// 累计丢弃条数
+	Dropped uint64
+	// 累计落盘条数
+	Spilled uint64
+	// 累计发送失败次数
+	Failed uint64
+}
+
+// 待发送的日志
+type shipEntry struct {
+	// 日志级别
+	lv Level
+	// 日志时间
+	time time.Time
+	// 渲染后的日志，不包含换行符
+	line []byte
+}
+
+// 日志发送器
+type sender interface {
+	// 批量发送，部分日志发送失败时返回*partialSendError
+	send(entries []shipEntry) error
+	// 关闭
+	close() error
+}
+
+// 部分日志发送失败，如elasticsearch bulk接口返回errors为true
+type partialSendError struct {
+	// 需重试的日志
+	retry []shipEntry
+	// 无法重试而丢弃的条数
+	dropped int
+	// 错误
+	err error
+}
+
+func (e *partialSendError) Error() string {
+	return e.err.Error()
+}
+
+// 网络日志处理器
+//
+//	渲染后的日志由后台协程批量发送，发送失败时按指数退避重试
+//	退避期间的日志写入本地落盘文件，恢复后分批优先补发
+type ShipperHandler struct {
+	render render.Render
+	// 配置
+	config *ShipperConfig
+	// 发送器
+	sender sender
+	// 待发送队列
+	entries chan shipEntry
+	// 关闭锁，避免关闭后写入队列
+	closeLock sync.RWMutex
+	// 是否关闭
+	closed bool
+	// 后台协程结束信号
+	done chan struct{}
+
+	// 重试退避
+	backOff backoff.BackOff
+	// 下次允许发送的时间
+	retryAt time.Time
+	// 落盘文件路径
+	spillPath string
+	// 落盘文件中已补发的字节数，只在后台协程中使用
+	replayOffset int64
+
+	// 统计
+	sent    uint64
+	dropped uint64
+	spilled uint64
+	failed  uint64
+}
+
+// 新建网络日志处理器
+func NewShipper(config *ShipperConfig) *ShipperHandler {
+	if config == nil || config.Addr == "" {
+		panic("shipper addr can't be empty")
+	}
+	fillShipperConfig(config)
+
+	var s sender
+	switch strings.ToLower(config.Type) {
+	case ShipperSyslog:
+		s = newSyslogSender(config)
+	case ShipperTCP, ShipperUDP:
+		s = newSocketSender(strings.ToLower(config.Type), config)
+	case ShipperHTTP:
+		s = newHTTPSender(config)
+	default:
+		panic(fmt.Sprintf("invalid shipper type: %s", config.Type))
+	}
+
+	return newShipper(config, s)
+}
+
+// 填充默认配置
+func fillShipperConfig(config *ShipperConfig) {
+	if config.Pattern == "" {
+		config.Pattern = defaultPattern
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = _defaultShipBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = ctime.Duration(_defaultShipFlushInterval)
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = _defaultShipQueueSize
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = ctime.Duration(_defaultShipTimeout)
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = ctime.Duration(_defaultShipMinBackoff)
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = ctime.Duration(_defaultShipMaxBackoff)
+	}
+	if config.MaxSpillSize <= 0 {
+		config.MaxSpillSize = _defaultMaxSpillSize
+	}
+}
+
+// 使用指定发送器新建网络日志处理器
+func newShipper(config *ShipperConfig, s sender) *ShipperHandler {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = time.Duration(config.MinBackoff)
+	b.MaxInterval = time.Duration(config.MaxBackoff)
+	// 一直重试
+	b.MaxElapsedTime = 0
+	b.Reset()
+
+	h := &ShipperHandler{
+		render:  newRender(config.Pattern),
+		config:  config,
+		sender:  s,
+		entries: make(chan shipEntry, config.QueueSize),
+		done:    make(chan struct{}),
+		backOff: b,
+	}
+	if config.SpillDir != "" {
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(config.Addr))
+		h.spillPath = filepath.Join(config.SpillDir,
+			fmt.Sprintf("%s-%x.spill", strings.ToLower(config.Type), hash.Sum32()))
+	}
+
+	go h.daemon()
+
+	return h
+}
+
+func (h *ShipperHandler) Log(ctx context.Context, lv Level, args map[string]interface{}) {
+	// 增加额外参数
+	addExtraField(ctx, args)
+
+	line := strings.TrimRight(h.render.RenderString(args), "\n")
+	entry := shipEntry{
+		lv:   lv,
+		time: time.Now(),
+		line: []byte(line),
+	}
+
+	h.closeLock.RLock()
+	defer h.closeLock.RUnlock()
+	if h.closed {
+		atomic.AddUint64(&h.dropped, 1)
+		return
+	}
+	select {
+	case h.entries <- entry:
+	default:
+		atomic.AddUint64(&h.dropped, 1)
+	}
+}
+
+// 后台批量发送协程
+func (h *ShipperHandler) daemon() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(time.Duration(h.config.FlushInterval))
+	defer ticker.Stop()
+
+	batch := make([]shipEntry, 0, h.config.BatchSize)
+	for {
+		select {
+		case entry, ok := <-h.entries:
+			if !ok {
+				h.flush(batch)
+				// 删除已补发的部分，避免重启后重复发送
+				h.compactSpill()
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= h.config.BatchSize {
+				h.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			// 即使没有新日志，也需要补发落盘的日志
+			h.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// 发送一批日志，失败时落盘
+func (h *ShipperHandler) flush(batch []shipEntry) {
+	if len(batch) == 0 && !h.hasSpill() {
+		return
+	}
+
+	// 退避期间直接落盘
+	if time.Now().Before(h.retryAt) {
+		h.spill(batch)
+		return
+	}
+
+	// 优先补发落盘的日志，保证顺序
+	done, err := h.replay()
+	if err != nil {
+		h.compactSpill()
+		h.fail(err)
+		h.spill(batch)
+		return
+	}
+	// 补发未完成时追加到落盘文件末尾，下次继续补发
+	if !done {
+		h.spill(batch)
+		return
+	}
+
+	if len(batch) == 0 {
+		return
+	}
+	if err := h.sender.send(batch); err != nil {
+		// 部分失败时只落盘需重试的日志
+		if batch, err = h.partial(batch, err); err != nil {
+			h.fail(err)
+			h.spill(batch)
+			return
+		}
+	} else {
+		atomic.AddUint64(&h.sent, uint64(len(batch)))
+	}
+	h.backOff.Reset()
+	h.retryAt = time.Time{}
+}
+
+// 处理部分发送失败，统计已发送及丢弃的条数，返回需重试的日志
+//
+//	非部分失败时原样返回，无需重试时返回空错误
+func (h *ShipperHandler) partial(batch []shipEntry, err error) ([]shipEntry, error) {
+	pe, ok := err.(*partialSendError)
+	if !ok {
+		return batch, err
+	}
+	atomic.AddUint64(&h.sent, uint64(len(batch)-len(pe.retry)-pe.dropped))
+	if pe.dropped > 0 {
+		atomic.AddUint64(&h.dropped, uint64(pe.dropped))
+		stdlog.Printf("log shipper %s drop %d entries rejected by %s: %s\n",
+			h.config.Type, pe.dropped, h.config.Addr, pe.err)
+	}
+	if len(pe.retry) == 0 {
+		return nil, nil
+	}
+	return pe.retry, pe
+}
+
+// 发送失败，设置下次重试时间
+func (h *ShipperHandler) fail(err error) {
+	atomic.AddUint64(&h.failed, 1)
+	next := h.backOff.NextBackOff()
+	h.retryAt = time.Now().Add(next)
+	stdlog.Printf("log shipper %s send to %s error: %s, retry after %s\n",
+		h.config.Type, h.config.Addr, err, next)
+}
+
+// 是否存在落盘文件
+func (h *ShipperHandler) hasSpill() bool {
+	if h.spillPath == "" {
+		return false
+	}
+	_, err := os.Stat(h.spillPath)
+	return err == nil
+}
+
+// 落盘，未配置落盘目录或超出大小时丢弃
+//
+//	每行格式为：日志级别 日志时间(纳秒时间戳) 渲染后的日志
+func (h *ShipperHandler) spill(batch []shipEntry) {
+	if len(batch) == 0 {
+		return
+	}
+	if h.spillPath == "" {
+		atomic.AddUint64(&h.dropped, uint64(len(batch)))
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.spillPath), 0755); err != nil {
+		stdlog.Printf("log shipper create spill dir error: %s\n", err)
+		atomic.AddUint64(&h.dropped, uint64(len(batch)))
+		return
+	}
+	f, err := os.OpenFile(h.spillPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		stdlog.Printf("log shipper open spill file error: %s\n", err)
+		atomic.AddUint64(&h.dropped, uint64(len(batch)))
+		return
+	}
+	defer f.Close()
+
+	var size int64
+	if info, err := f.Stat(); err == nil {
+		size = info.Size()
+	}
+
+	w := bufio.NewWriter(f)
+	for _, entry := range batch {
+		if size >= h.config.MaxSpillSize {
+			atomic.AddUint64(&h.dropped, 1)
+			continue
+		}
+		n, _ := w.WriteString(strconv.Itoa(int(entry.lv)) + " " + strconv.FormatInt(entry.time.UnixNano(), 10) + " ")
+		m, _ := w.Write(entry.line)
+		_ = w.WriteByte('\n')
+		size += int64(n + m + 1)
+		atomic.AddUint64(&h.spilled, 1)
+	}
+	if err := w.Flush(); err != nil {
+		stdlog.Printf("log shipper write spill file error: %s\n", err)
+	}
+}
+
+// 补发落盘的日志
+//
+//	从上次补发的位置开始，每次最多补发_maxReplayBatches批，全部补发后删除落盘文件
+//	返回是否已全部补发，发送失败时保留未发送的部分
+func (h *ShipperHandler) replay() (bool, error) {
+	if !h.hasSpill() {
+		h.replayOffset = 0
+		return true, nil
+	}
+
+	f, err := os.Open(h.spillPath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if _, err = f.Seek(h.replayOffset, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	reader := bufio.NewReader(f)
+	batch := make([]shipEntry, 0, h.config.BatchSize)
+	batchSize := int64(0)
+	for batches := 0; batches < _maxReplayBatches; {
+		line, err := reader.ReadBytes('\n')
+		if len(line) != 0 {
+			batchSize += int64(len(line))
+			if entry, ok := parseSpillLine(line); ok {
+				batch = append(batch, entry)
+			}
+		}
+		if len(batch) >= h.config.BatchSize || (err != nil && len(batch) != 0) {
+			if sendErr := h.sender.send(batch); sendErr != nil {
+				if _, ok := sendErr.(*partialSendError); !ok {
+					return false, sendErr
+				}
+				// 部分失败时跳过该批，需重试的日志追加至落盘文件末尾
+				retry, sendErr := h.partial(batch, sendErr)
+				h.spill(retry)
+				h.replayOffset += batchSize
+				if sendErr != nil {
+					return false, sendErr
+				}
+			} else {
+				atomic.AddUint64(&h.sent, uint64(len(batch)))
+				h.replayOffset += batchSize
+			}
+			batch = batch[:0]
+			batchSize = 0
+			batches++
+		}
+		if err != nil {
+			if err != io.EOF {
+				return false, err
+			}
+			f.Close()
+			h.replayOffset = 0
+			return true, os.Remove(h.spillPath)
+		}
+	}
+	return false, nil
+}
+
+// 删除落盘文件中已补发的部分
+func (h *ShipperHandler) compactSpill() {
+	if h.replayOffset == 0 {
+		return
+	}
+	if err := truncateHead(h.spillPath, h.replayOffset); err != nil {
+		stdlog.Printf("log shipper truncate spill file error: %s\n", err)
+		return
+	}
+	h.replayOffset = 0
+}
+
+// 解析落盘的日志
+func parseSpillLine(line []byte) (shipEntry, bool) {
+	line = bytes.TrimRight(line, "\n")
+	parts := bytes.SplitN(line, []byte(" "), 3)
+	if len(parts) != 3 {
+		return shipEntry{}, false
+	}
+	lv, err := strconv.Atoi(string(parts[0]))
+	if err != nil {
+		return shipEntry{}, false
+	}
+	ts, err := strconv.ParseInt(string(parts[1]), 10, 64)
+	if err != nil {
+		return shipEntry{}, false
+	}
+	return shipEntry{
+		lv:   Level(lv),
+		time: time.Unix(0, ts),
+		line: append([]byte(nil), parts[2]...),
+	}, true
+}
+
+// 删除文件头部指定字节
+func truncateHead(path string, offset int64) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if _, err = src.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	dst, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// 获取统计数据
+func (h *ShipperHandler) Stats() ShipperStats {
+	return ShipperStats{
+		Sent:    atomic.LoadUint64(&h.sent),
+		Dropped: atomic.LoadUint64(&h.dropped),
+		Spilled: atomic.LoadUint64(&h.spilled),
+		Failed:  atomic.LoadUint64(&h.failed),
+	}
+}
+
+// 关闭，发送队列中剩余的日志，发送失败时落盘
+func (h *ShipperHandler) Close() error {
+	h.closeLock.Lock()
+	if h.closed {
+		h.closeLock.Unlock()
+		return nil
+	}
+	h.closed = true
+	close(h.entries)
+	h.closeLock.Unlock()
+
+	<-h.done
+	h.render.Close()
+	return h.sender.close()
+}
+
+func (h *ShipperHandler) SetFormat(format string) {
+	h.render = newRender(format)
+}
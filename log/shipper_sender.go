@@ -0,0 +1,367 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// http请求体格式
+const (
+	ShipFormatLines  = "lines"
+	ShipFormatLoki   = "loki"
+	ShipFormatESBulk = "es_bulk"
+)
+
+// 日志级别对应的syslog severity
+var syslogSeverities = [...]int{
+	_debugLevel: 7,
+	_infoLevel:  6,
+	_warnLevel:  4,
+	_errorLevel: 3,
+	_fatalLevel: 2,
+}
+
+// 基于tcp/udp连接的发送器
+type socketSender struct {
+	// 网络类型，tcp或udp
+	network string
+	// 地址
+	addr string
+	// 超时时间
+	timeout time.Duration
+	// 当前连接，发送失败时关闭并置空，下次发送时重连
+	conn net.Conn
+	// 封装单条日志
+	frame func(buf []byte, entry shipEntry) []byte
+}
+
+// 新建tcp/udp发送器，每条日志以换行符结尾
+func newSocketSender(network string, config *ShipperConfig) *socketSender {
+	return &socketSender{
+		network: network,
+		addr:    config.Addr,
+		timeout: time.Duration(config.Timeout),
+		frame: func(buf []byte, entry shipEntry) []byte {
+			buf = append(buf, entry.line...)
+			return append(buf, '\n')
+		},
+	}
+}
+
+// 新建RFC5424 syslog发送器
+//
+//	udp时每条日志一个数据包，tcp时使用RFC6587的octet-counting分帧
+func newSyslogSender(config *ShipperConfig) *socketSender {
+	network := strings.ToLower(config.Network)
+	if network == "" {
+		network = "udp"
+	}
+	if network != "udp" && network != "tcp" {
+		panic(fmt.Sprintf("invalid syslog network: %s", config.Network))
+	}
+	facility := config.Facility
+	if facility <= 0 {
+		facility = 1
+	}
+	appName := config.AppName
+	if appName == "" {
+		appName = filepath.Base(os.Args[0])
+	}
+	hostname := config.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	header := fmt.Sprintf("%s %s %d - -", syslogField(hostname), syslogField(appName), os.Getpid())
+
+	s := &socketSender{
+		network: network,
+		addr:    config.Addr,
+		timeout: time.Duration(config.Timeout),
+	}
+	s.frame = func(buf []byte, entry shipEntry) []byte {
+		msg := formatSyslog(facility, header, entry)
+		if network == "tcp" {
+			buf = strconv.AppendInt(buf, int64(len(msg)), 10)
+			buf = append(buf, ' ')
+		}
+		return append(buf, msg...)
+	}
+	return s
+}
+
+// syslog头部字段，为空时使用-
+func syslogField(s string) string {
+	s = strings.Replace(strings.TrimSpace(s), " ", "_", -1)
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// 格式化RFC5424 syslog消息
+func formatSyslog(facility int, header string, entry shipEntry) []byte {
+	severity := syslogSeverities[levelIndex(entry.lv)]
+
+	buf := make([]byte, 0, len(entry.line)+len(header)+48)
+	buf = append(buf, '<')
+	buf = strconv.AppendInt(buf, int64(facility*8+severity), 10)
+	buf = append(buf, ">1 "...)
+	buf = entry.time.AppendFormat(buf, "2006-01-02T15:04:05.000000Z07:00")
+	buf = append(buf, ' ')
+	buf = append(buf, header...)
+	buf = append(buf, ' ')
+	return append(buf, entry.line...)
+}
+
+func (s *socketSender) send(entries []shipEntry) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.addr, s.timeout)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		s.conn = conn
+	}
+
+	var err error
+	if s.network == "udp" {
+		// 每条日志一个数据包
+		buf := make([]byte, 0, 1024)
+		for _, entry := range entries {
+			buf = s.frame(buf[:0], entry)
+			if err = s.write(buf); err != nil {
+				break
+			}
+		}
+	} else {
+		var buf []byte
+		for _, entry := range entries {
+			buf = s.frame(buf, entry)
+		}
+		err = s.write(buf)
+	}
+
+	if err != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// 带超时写入
+func (s *socketSender) write(b []byte) error {
+	if s.timeout > 0 {
+		if err := s.conn.SetWriteDeadline(time.Now().Add(s.timeout)); err != nil {
+			return err
+		}
+	}
+	_, err := s.conn.Write(b)
+	return err
+}
+
+func (s *socketSender) close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// 基于http批量请求的发送器
+type httpSender struct {
+	// 请求地址
+	url string
+	// 请求体格式
+	format string
+	// es_bulk格式的索引名
+	index string
+	// loki格式的stream标签
+	labels map[string]string
+	// 请求头
+	headers map[string]string
+	// http客户端
+	client *http.Client
+}
+
+// 新建http发送器
+func newHTTPSender(config *ShipperConfig) *httpSender {
+	format := strings.ToLower(config.Format)
+	switch format {
+	case "":
+		format = ShipFormatLines
+	case ShipFormatLines, ShipFormatLoki, ShipFormatESBulk:
+	default:
+		panic(fmt.Sprintf("invalid shipper http format: %s", config.Format))
+	}
+	labels := config.Labels
+	if len(labels) == 0 {
+		labels = map[string]string{"job": "log"}
+	}
+
+	return &httpSender{
+		url:     config.Addr,
+		format:  format,
+		index:   config.Index,
+		labels:  labels,
+		headers: config.Headers,
+		client:  &http.Client{Timeout: time.Duration(config.Timeout)},
+	}
+}
+
+// loki推送请求体
+type lokiPushBody struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+// loki stream
+type lokiStream struct {
+	// 标签
+	Stream map[string]string `json:"stream"`
+	// 日志，每条为[纳秒时间戳, 日志]
+	Values [][2]string `json:"values"`
+}
+
+// 构造请求体
+func (s *httpSender) body(entries []shipEntry) ([]byte, string, error) {
+	var buf bytes.Buffer
+	switch s.format {
+	case ShipFormatLoki:
+		stream := lokiStream{
+			Stream: s.labels,
+			Values: make([][2]string, 0, len(entries)),
+		}
+		for _, entry := range entries {
+			stream.Values = append(stream.Values, [2]string{
+				strconv.FormatInt(entry.time.UnixNano(), 10),
+				string(entry.line),
+			})
+		}
+		b, err := json.Marshal(&lokiPushBody{Streams: []lokiStream{stream}})
+		return b, "application/json", err
+	case ShipFormatESBulk:
+		action := `{"index":{}}`
+		if s.index != "" {
+			b, _ := json.Marshal(map[string]interface{}{
+				"index": map[string]string{"_index": s.index},
+			})
+			action = string(b)
+		}
+		for _, entry := range entries {
+			buf.WriteString(action)
+			buf.WriteByte('\n')
+			buf.Write(entry.line)
+			buf.WriteByte('\n')
+		}
+	default:
+		for _, entry := range entries {
+			buf.Write(entry.line)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), "application/x-ndjson", nil
+}
+
+func (s *httpSender) send(entries []shipEntry) error {
+	body, contentType, err := s.body(entries)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if s.format == ShipFormatESBulk {
+		return esBulkError(resp.Body, entries)
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+// elasticsearch bulk接口响应
+type esBulkResponse struct {
+	// 是否有失败的条目
+	Errors bool `json:"errors"`
+	// 各条目结果，key为操作类型
+	Items []map[string]esBulkItem `json:"items"`
+}
+
+// elasticsearch bulk接口单个条目结果
+type esBulkItem struct {
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// 解析elasticsearch bulk接口响应，http状态码为200时仍可能有条目失败
+//
+//	429及5xx的条目需重试，其他失败的条目(如mapping错误)重试无效，直接丢弃
+func esBulkError(r io.Reader, entries []shipEntry) error {
+	var resp esBulkResponse
+	if err := json.NewDecoder(r).Decode(&resp); err != nil {
+		// 无响应体时视为成功，如兼容接口返回204
+		if err == io.EOF {
+			return nil
+		}
+		return errors.Wrap(err, "decode es bulk response error")
+	}
+	_, _ = io.Copy(ioutil.Discard, r)
+	if !resp.Errors {
+		return nil
+	}
+	if len(resp.Items) != len(entries) {
+		return errors.Errorf("es bulk response has %d items, expected %d", len(resp.Items), len(entries))
+	}
+
+	pe := new(partialSendError)
+	var firstErr json.RawMessage
+	for i, item := range resp.Items {
+		for _, result := range item {
+			if result.Status >= 200 && result.Status < 300 {
+				continue
+			}
+			if firstErr == nil {
+				firstErr = result.Error
+			}
+			if result.Status == http.StatusTooManyRequests || result.Status >= 500 {
+				pe.retry = append(pe.retry, entries[i])
+			} else {
+				pe.dropped++
+			}
+		}
+	}
+	pe.err = errors.Errorf("es bulk %d items failed, first error: %s", len(pe.retry)+pe.dropped, firstErr)
+	return pe
+}
+
+func (s *httpSender) close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,349 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gitlab.shanhai.int/sre/library/base/ctime"
+)
+
+// 用于测试的发送器，可模拟发送失败
+type fakeSender struct {
+	mu    sync.Mutex
+	fail  bool
+	lines []string
+}
+
+func (s *fakeSender) send(entries []shipEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("collector down")
+	}
+	for _, entry := range entries {
+		s.lines = append(s.lines, string(entry.line))
+	}
+	return nil
+}
+
+func (s *fakeSender) close() error { return nil }
+
+func (s *fakeSender) setFail(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = fail
+}
+
+func (s *fakeSender) getLines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.lines...)
+}
+
+// 等待条件满足
+func waitFor(t *testing.T, cond func() bool) {
+	for i := 0; i < 200; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("wait timeout")
+}
+
+func testShipperConfig(typ, addr string) *ShipperConfig {
+	return &ShipperConfig{
+		Type:          typ,
+		Addr:          addr,
+		Pattern:       "%M",
+		FlushInterval: ctime.Duration(10 * time.Millisecond),
+	}
+}
+
+func TestShipper_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	h := NewShipper(testShipperConfig(ShipperTCP, ln.Addr().String()))
+	h.Log(context.Background(), _infoLevel, map[string]interface{}{_log: "a"})
+	h.Log(context.Background(), _infoLevel, map[string]interface{}{_log: "b"})
+	assert.Nil(t, h.Close())
+
+	assert.Equal(t, "a", <-lines)
+	assert.Equal(t, "b", <-lines)
+	assert.Equal(t, uint64(2), h.Stats().Sent)
+}
+
+func TestShipper_Syslog(t *testing.T) {
+	t.Run("udp", func(t *testing.T) {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		defer pc.Close()
+
+		config := testShipperConfig(ShipperSyslog, pc.LocalAddr().String())
+		config.AppName = "app"
+		config.Hostname = "host"
+		h := NewShipper(config)
+		h.Log(context.Background(), _errorLevel, map[string]interface{}{_log: "hello"})
+		assert.Nil(t, h.Close())
+
+		buf := make([]byte, 1024)
+		_ = pc.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		assert.Nil(t, err)
+		msg := string(buf[:n])
+		// facility 1 * 8 + severity 3
+		assert.True(t, strings.HasPrefix(msg, "<11>1 "), msg)
+		assert.True(t, strings.HasSuffix(msg, " host app "+strings.Split(msg, " ")[4]+" - - hello"), msg)
+	})
+
+	t.Run("tcp framing", func(t *testing.T) {
+		s := newSyslogSender(&ShipperConfig{Network: "tcp", AppName: "app", Hostname: "host"})
+		entry := shipEntry{lv: _infoLevel, time: time.Now(), line: []byte("hello")}
+		parts := strings.SplitN(string(s.frame(nil, entry)), " ", 2)
+		// octet-counting分帧，长度为消息字节数
+		assert.Equal(t, strconv.Itoa(len(parts[1])), parts[0])
+		assert.True(t, strings.HasPrefix(parts[1], "<14>1 "))
+		assert.True(t, strings.HasSuffix(parts[1], " - - hello"))
+	})
+}
+
+func TestShipper_HTTP(t *testing.T) {
+	var mu sync.Mutex
+	bodies := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		bodies[r.URL.Path] = string(b)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	t.Run("loki", func(t *testing.T) {
+		config := testShipperConfig(ShipperHTTP, server.URL+"/loki/api/v1/push")
+		config.Format = ShipFormatLoki
+		config.Labels = map[string]string{"app": "test"}
+		h := NewShipper(config)
+		h.Log(context.Background(), _infoLevel, map[string]interface{}{_log: "hello"})
+		assert.Nil(t, h.Close())
+
+		var body lokiPushBody
+		mu.Lock()
+		err := json.Unmarshal([]byte(bodies["/loki/api/v1/push"]), &body)
+		mu.Unlock()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(body.Streams))
+		assert.Equal(t, map[string]string{"app": "test"}, body.Streams[0].Stream)
+		assert.Equal(t, "hello", body.Streams[0].Values[0][1])
+	})
+
+	t.Run("es bulk", func(t *testing.T) {
+		config := testShipperConfig(ShipperHTTP, server.URL+"/_bulk")
+		config.Format = ShipFormatESBulk
+		config.Index = "logs"
+		h := NewShipper(config)
+		h.Log(context.Background(), _infoLevel, map[string]interface{}{_log: "a"})
+		h.Log(context.Background(), _infoLevel, map[string]interface{}{_log: "b"})
+		assert.Nil(t, h.Close())
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "{\"index\":{\"_index\":\"logs\"}}\na\n{\"index\":{\"_index\":\"logs\"}}\nb\n", bodies["/_bulk"])
+	})
+}
+
+func TestShipper_Spill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-spill")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	config := testShipperConfig(ShipperTCP, "127.0.0.1:0")
+	config.SpillDir = dir
+	config.MinBackoff = ctime.Duration(time.Millisecond)
+	config.MaxBackoff = ctime.Duration(10 * time.Millisecond)
+	fillShipperConfig(config)
+
+	s := &fakeSender{fail: true}
+	h := newShipper(config, s)
+	ctx := context.Background()
+
+	// 采集端不可用时落盘
+	h.Log(ctx, _infoLevel, map[string]interface{}{_log: "a"})
+	h.Log(ctx, _infoLevel, map[string]interface{}{_log: "b"})
+	waitFor(t, func() bool { return h.Stats().Spilled == 2 })
+	_, err = os.Stat(h.spillPath)
+	assert.Nil(t, err)
+
+	// 恢复后优先补发落盘的日志
+	s.setFail(false)
+	h.Log(ctx, _infoLevel, map[string]interface{}{_log: "c"})
+	waitFor(t, func() bool { return h.Stats().Sent == 3 })
+	assert.Nil(t, h.Close())
+
+	assert.Equal(t, []string{"a", "b", "c"}, s.getLines())
+	_, err = os.Stat(h.spillPath)
+	assert.True(t, os.IsNotExist(err))
+	assert.True(t, h.Stats().Failed > 0)
+	assert.Equal(t, uint64(0), h.Stats().Dropped)
+}
+
+func TestShipper_Replay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-replay")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	config := testShipperConfig(ShipperTCP, "127.0.0.1:0")
+	config.SpillDir = dir
+	config.BatchSize = 1
+	fillShipperConfig(config)
+
+	s := &fakeSender{}
+	h := &ShipperHandler{config: config, sender: s, spillPath: filepath.Join(dir, "replay.spill")}
+	batch := make([]shipEntry, 0, _maxReplayBatches+5)
+	for i := 0; i < _maxReplayBatches+5; i++ {
+		batch = append(batch, shipEntry{lv: _infoLevel, time: time.Now(), line: []byte(strconv.Itoa(i))})
+	}
+	h.spill(batch)
+
+	// 每次最多补发_maxReplayBatches批
+	done, err := h.replay()
+	assert.Nil(t, err)
+	assert.False(t, done)
+	assert.Equal(t, _maxReplayBatches, len(s.getLines()))
+
+	// 发送失败时删除已补发的部分
+	s.setFail(true)
+	_, err = h.replay()
+	assert.NotNil(t, err)
+	h.compactSpill()
+	assert.Equal(t, int64(0), h.replayOffset)
+
+	s.setFail(false)
+	done, err = h.replay()
+	assert.Nil(t, err)
+	assert.True(t, done)
+	lines := s.getLines()
+	assert.Equal(t, _maxReplayBatches+5, len(lines))
+	assert.Equal(t, strconv.Itoa(_maxReplayBatches+4), lines[len(lines)-1])
+	_, err = os.Stat(h.spillPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestShipper_ESBulkErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-es-bulk")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		first := len(bodies) == 1
+		mu.Unlock()
+		// 首次请求时b需重试，c无法重试
+		if first {
+			_, _ = w.Write([]byte(`{"errors":true,"items":[{"index":{"status":201}},` +
+				`{"index":{"status":429,"error":{"type":"es_rejected_execution_exception"}}},` +
+				`{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"errors":false,"items":[{"index":{"status":201}}]}`))
+	}))
+	defer server.Close()
+
+	config := testShipperConfig(ShipperHTTP, server.URL+"/_bulk")
+	config.Format = ShipFormatESBulk
+	config.SpillDir = dir
+	fillShipperConfig(config)
+	h := &ShipperHandler{
+		config:    config,
+		sender:    newHTTPSender(config),
+		spillPath: filepath.Join(dir, "es.spill"),
+		backOff:   backoff.NewExponentialBackOff(),
+	}
+
+	var batch []shipEntry
+	for _, line := range []string{"a", "b", "c"} {
+		batch = append(batch, shipEntry{lv: _infoLevel, time: time.Now(), line: []byte(line)})
+	}
+	// 状态码为200时仍检查各条目结果，只落盘需重试的日志
+	h.flush(batch)
+	assert.Equal(t, ShipperStats{Sent: 1, Dropped: 1, Spilled: 1, Failed: 1}, h.Stats())
+	b, err := ioutil.ReadFile(h.spillPath)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(string(b), " b\n"), string(b))
+
+	// 退避结束后补发
+	h.retryAt = time.Time{}
+	h.flush(nil)
+	assert.Equal(t, ShipperStats{Sent: 2, Dropped: 1, Spilled: 1, Failed: 1}, h.Stats())
+	mu.Lock()
+	assert.Equal(t, "{\"index\":{}}\nb\n", bodies[len(bodies)-1])
+	mu.Unlock()
+	_, err = os.Stat(h.spillPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestShipper_Drop(t *testing.T) {
+	config := testShipperConfig(ShipperTCP, "127.0.0.1:0")
+	config.MinBackoff = ctime.Duration(time.Hour)
+	fillShipperConfig(config)
+
+	h := newShipper(config, &fakeSender{fail: true})
+	h.Log(context.Background(), _infoLevel, map[string]interface{}{_log: "a"})
+	assert.Nil(t, h.Close())
+	// 未配置落盘目录时直接丢弃
+	assert.Equal(t, ShipperStats{Dropped: 1, Failed: 1}, h.Stats())
+}
+
+func TestTruncateHead(t *testing.T) {
+	f, err := ioutil.TempFile("", "log-truncate")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	_, _ = f.WriteString("0 1 a\n0 2 b\n")
+	f.Close()
+
+	assert.Nil(t, truncateHead(f.Name(), 6))
+	b, err := ioutil.ReadFile(f.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, "0 2 b\n", string(b))
+
+	entry, ok := parseSpillLine(b)
+	assert.True(t, ok)
+	assert.Equal(t, "b", string(entry.line))
+	assert.Equal(t, int64(2), entry.time.UnixNano())
+}
@@ -9,6 +9,14 @@ import (
 
 const defaultPattern = "%J{tTLUSm}"
 
+// 渲染格式，由Init时的Config.Format设置
+var _renderFormat string
+
+// 按当前渲染格式新建渲染器
+func newRender(pattern string) render.Render {
+	return render.NewRender(_renderFormat, patternMap, pattern)
+}
+
 // 默认控制台输出
 var _defaultStdout = NewStdout("")
 
@@ -22,7 +30,7 @@ func NewStdout(customPattern string) *StdoutHandler {
 	if customPattern == "" {
 		customPattern = defaultPattern
 	}
-	return &StdoutHandler{render: render.NewPatternRender(patternMap, customPattern)}
+	return &StdoutHandler{render: newRender(customPattern)}
 }
 
 func (h *StdoutHandler) Log(ctx context.Context, lv Level, args map[string]interface{}) {
@@ -42,5 +50,5 @@ func (h *StdoutHandler) Close() error {
 }
 
 func (h *StdoutHandler) SetFormat(format string) {
-	h.render = render.NewPatternRender(patternMap, format)
+	h.render = newRender(format)
 }
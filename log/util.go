@@ -4,10 +4,20 @@ import (
 	"context"
 
 	_context "gitlab.shanhai.int/sre/library/base/context"
+	render "gitlab.shanhai.int/sre/library/base/logrender"
 )
 
 // 增加额外参数
+//
+//	已增加过时跳过，如异步处理器入队时已提取上下文中的字段
 func addExtraField(ctx context.Context, fields map[string]interface{}) {
+	if _, ok := fields[_appID]; ok {
+		return
+	}
 	fields[_appID] = c.AppID
 	fields[_uuid] = ctx.Value(_context.ContextUUIDKey)
+	// 上下文日志字段由渲染器自动输出
+	if contextFields := _context.GetLogFields(ctx); len(contextFields) != 0 {
+		fields[render.ContextFieldsArgKey] = contextFields
+	}
 }
@@ -26,6 +26,10 @@ type Client struct {
 	manager *hook.Manager
 	// 配置信息
 	config *Config
+	// namespace变更监听函数，key为namespace
+	listeners map[string][]func(newValue map[string]interface{})
+	// 监听函数读写锁
+	listenerLock sync.RWMutex
 }
 
 // 新建Apollo客户端后台watch
@@ -56,10 +60,11 @@ func NewClient(conf *Config) *Client {
 	}
 
 	c := &Client{
-		client:  client,
-		config:  conf,
-		manager: NewHookManager(conf.Config),
-		done:    make(chan bool),
+		client:    client,
+		config:    conf,
+		manager:   NewHookManager(conf.Config),
+		done:      make(chan bool),
+		listeners: make(map[string][]func(newValue map[string]interface{})),
 	}
 
 	c.storeMap = make(map[string]*StoreData)
@@ -153,6 +158,32 @@ func (c *Client) GetNewValue(namespace, key string) (interface{}, error) {
 	return value, nil
 }
 
+// 注册namespace变更监听函数
+//
+//	仅daemon模式下生效，变更数据存储后调用
+func (c *Client) OnChange(namespace string, f func(newValue map[string]interface{})) error {
+	if c.config.NotDaemon {
+		return errors.New("your apollo config is not daemon pattern")
+	}
+
+	c.listenerLock.Lock()
+	defer c.listenerLock.Unlock()
+	c.listeners[namespace] = append(c.listeners[namespace], f)
+
+	return nil
+}
+
+// 通知namespace变更
+func (c *Client) notify(namespace string, newValue map[string]interface{}) {
+	c.listenerLock.RLock()
+	listeners := c.listeners[namespace]
+	c.listenerLock.RUnlock()
+
+	for _, f := range listeners {
+		f(newValue)
+	}
+}
+
 // 判断client是否close
 func (c *Client) Done() <-chan bool {
 	return c.done
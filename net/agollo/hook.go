@@ -13,6 +13,7 @@ const (
 // 新建钩子管理器
 func NewHookManager(renderConfig *render.Config) *hook.Manager {
 	return hook.NewManager().
+		SetComponent(hook.ComponentAgollo).
 		RegisterLogHook(renderConfig, patternMap)
 }
 
@@ -0,0 +1,35 @@
+package agollo
+
+import (
+	"fmt"
+
+	"gitlab.shanhai.int/sre/library/log"
+)
+
+// 使用指定namespace作为日志级别配置，并实时监听变更
+//
+//	namespace中key为模块路径(如database/redis，root为默认日志级别)，value为日志级别名(如debug)
+//	仅daemon模式下可用，且namespace需在PreloadNamespaces中
+func (c *Client) WatchLogLevel(namespace string) error {
+	store, err := c.GetNamespace(namespace)
+	if err != nil {
+		return err
+	}
+	if err = log.UpdateLevels(store.toStringMap()); err != nil {
+		return err
+	}
+
+	return c.OnChange(namespace, func(newValue map[string]interface{}) {
+		levels := make(map[string]string, len(newValue))
+		for k, v := range newValue {
+			levels[k] = fmt.Sprint(v)
+		}
+		if err := log.UpdateLevels(levels); err != nil {
+			c.log(&logInfo{
+				NamespaceName: namespace,
+				extra:         fmt.Sprintf("Update log level err: %s", err),
+				watcherType:   DaemonWatcher,
+			})
+		}
+	})
+}
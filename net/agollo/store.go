@@ -1,8 +1,10 @@
 package agollo
 
 import (
-	"github.com/pkg/errors"
+	"fmt"
 	"sync"
+
+	"github.com/pkg/errors"
 )
 
 // 存储信息结构体
@@ -20,3 +22,13 @@ func (cd *StoreData) Load(key string) (interface{}, error) {
 
 	return nil, errors.New("target key is not set")
 }
+
+// 转换为字符串字典
+func (cd *StoreData) toStringMap() map[string]string {
+	m := make(map[string]string)
+	cd.data.Range(func(key, value interface{}) bool {
+		m[fmt.Sprint(key)] = fmt.Sprint(value)
+		return true
+	})
+	return m
+}
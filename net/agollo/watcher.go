@@ -200,6 +200,8 @@ func (dw *daemonWatcher) Watch(namespaces []string) {
 				})
 				// 数据存map
 				dw.client.Store(watchResp.Namespace, watchResp.NewValue)
+				// 通知监听函数
+				dw.client.notify(watchResp.Namespace, watchResp.NewValue)
 			case <-dw.client.Done():
 				return
 			}
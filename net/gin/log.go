@@ -74,7 +74,7 @@ func getDefaultRouterLogger(conf *Config) *Logger {
 	if conf.Stdout {
 		writers = append(writers, LogWriter{
 			Writer: os.Stdout,
-			Render: render.NewPatternRender(patternMap, conf.StdoutRouterPattern),
+			Render: render.NewRender(conf.Format, patternMap, conf.StdoutRouterPattern, conf.Options()...),
 		})
 	}
 	if conf.OutDir != "" {
@@ -84,7 +84,7 @@ func getDefaultRouterLogger(conf *Config) *Logger {
 		)
 		writers = append(writers, LogWriter{
 			Writer: fw,
-			Render: render.NewPatternRender(patternMap, conf.OutRouterPattern),
+			Render: render.NewRender(conf.Format, patternMap, conf.OutRouterPattern, conf.Options()...),
 		})
 	}
 
@@ -138,7 +138,7 @@ func GetDefaultFormatter(conf *Config) gin.HandlerFunc {
 		conf.OutPattern = defaultPattern
 	}
 
-	ginRender := render.NewPatternRender(patternMap, conf.StdoutPattern)
+	ginRender := render.NewRender(conf.Format, patternMap, conf.StdoutPattern)
 	return func(c *gin.Context) {
 		relativePath := GetGinRelativePath(c)
 
@@ -136,7 +136,12 @@ func GetHookHandler() HandlerFunc {
 		hk.ProcessPreHook()
 		b.ctx = hk.Context()
 
-		b.Next()
+		// 前置钩子返回错误时不发起请求
+		if err := hk.Err(); err != nil {
+			b.SetError(err)
+		} else {
+			b.Next()
+		}
 
 		hk.AddArg(render.StartTimeArgKey, b.startTime).
 			AddArg(render.EndTimeArgKey, b.endTime).
@@ -161,6 +166,7 @@ func GetHookHandler() HandlerFunc {
 		}
 	SkipResponse:
 		hk.ProcessAfterHook()
+		hk.Release()
 	}
 }
 
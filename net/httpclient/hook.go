@@ -13,7 +13,7 @@ const (
 
 // 新建钩子管理器
 func NewHookManager() *hook.Manager {
-	return hook.NewManager()
+	return hook.NewManager().SetComponent(hook.ComponentHttpClient)
 }
 
 // 渲染模版
@@ -60,3 +60,11 @@ var RedlockRequestTotal = prometheus.NewCounterVec(
 	},
 	[]string{"web_url", "web_method"},
 )
+
+// 慢操作数量
+var SlowOperationsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "slow_operations_total",
+	},
+	[]string{"component", "command"},
+)
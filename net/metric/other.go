@@ -51,3 +51,19 @@ var GoroutineResponseTotal = prometheus.NewCounterVec(
 	},
 	[]string{"web_url", "web_method", "group_name", "state"},
 )
+
+// 协程执行器运行中的协程数
+var GoroutineExecutorRunning = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "goroutine_executor_running",
+	},
+	[]string{"executor"},
+)
+
+// 协程执行器排队等待的协程数
+var GoroutineExecutorQueueLength = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "goroutine_executor_queue_length",
+	},
+	[]string{"executor"},
+)
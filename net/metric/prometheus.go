@@ -8,6 +8,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	_context "gitlab.shanhai.int/sre/library/base/context"
+	"gitlab.shanhai.int/sre/library/base/filewriter"
 	_gin "gitlab.shanhai.int/sre/library/net/gin"
 )
 
@@ -22,12 +23,15 @@ var DBCollector = []prometheus.Collector{
 	RedisRequestTotal, RedisRequestDurationSummary,
 	GormRequestTotal, GormRequestDurationSummary,
 	RedlockRequestTotal,
+	SlowOperationsTotal,
 }
 
 // 其他收集器
 var OtherCollector = []prometheus.Collector{
 	HttpRequestTotal, HttpRequestDurationSummary, HttpResponseTotal,
 	GoroutineRequestTotal, GoroutineRequestDurationSummary, GoroutineResponseTotal,
+	GoroutineExecutorRunning, GoroutineExecutorQueueLength,
+	filewriter.ErrorTotal,
 }
 
 // 初始化
@@ -1,8 +1,10 @@
 package middleware
 
 import (
-	"github.com/gin-gonic/gin"
 	"regexp"
+
+	"github.com/gin-gonic/gin"
+	_context "gitlab.shanhai.int/sre/library/base/context"
 )
 
 const (
@@ -19,7 +21,7 @@ var (
 	UserAgentRegex = regexp.MustCompile(`QingTing-(iOS|Android)(-WV)?\/(\d+\.\d+\.\d+(.\d+)?)`)
 )
 
-// 解析UserAgent，并将相关信息装入context中
+// 解析UserAgent，并将相关信息装入context中，app版本同时作为日志字段自动输出
 func ParseUserAgentMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userAgent := c.Request.Header.Get(HeaderUserAgentKey)
@@ -31,6 +33,7 @@ func ParseUserAgentMiddleware() gin.HandlerFunc {
 		}
 		if len(userAgentMatch) > 3 {
 			c.Set(ContextAppVersionKey, userAgentMatch[3])
+			_context.SetLogField(c, ContextAppVersionKey, userAgentMatch[3])
 		}
 
 		c.Next()
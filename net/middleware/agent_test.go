@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	_context "gitlab.shanhai.int/sre/library/base/context"
 	httpUtil "gitlab.shanhai.int/sre/library/base/net"
 )
 
@@ -16,6 +17,8 @@ func TestParseUserAgentMiddleware(t *testing.T) {
 		router.GET("/", func(c *gin.Context) {
 			assert.Equal(t, ContextPhoneTypeAndroid, c.GetString(ContextPhoneTypeKey))
 			assert.Equal(t, "8.4.2.0", c.GetString(ContextAppVersionKey))
+			// 无需注册即输出到日志
+			assert.Equal(t, "8.4.2.0", _context.GetLogFields(c)[ContextAppVersionKey])
 		})
 
 		header := make(http.Header)
@@ -22,6 +22,7 @@ const (
 // 新建钩子管理器
 func NewHookManager(renderConfig *render.Config) *hook.Manager {
 	return hook.NewManager().
+		SetComponent(hook.ComponentRedlock).
 		RegisterLogHook(renderConfig, patternMap).
 		RegisterPreHook(func(hk *hook.Hook) {
 			args := hk.Args()
@@ -29,7 +29,10 @@ type Mutex struct {
 // 锁
 func (m *Mutex) Lock(ctx context.Context) error {
 	ctx, hk := m.before(ctx, "LOCK")
-	e := m.Mutex.Lock()
+	e := hk.Err()
+	if e == nil {
+		e = m.Mutex.Lock()
+	}
 	m.after(hk, e)
 
 	return e
@@ -38,10 +41,13 @@ func (m *Mutex) Lock(ctx context.Context) error {
 // 解锁
 func (m *Mutex) Unlock(ctx context.Context) bool {
 	ctx, hk := m.before(ctx, "UNLOCK")
-	result := m.Mutex.Unlock()
-	var err error
-	if !result {
-		err = errors.New("Unlock Fail")
+	err := hk.Err()
+	result := false
+	if err == nil {
+		result = m.Mutex.Unlock()
+		if !result {
+			err = errors.New("Unlock Fail")
+		}
 	}
 	m.after(hk, err)
 
@@ -51,10 +57,13 @@ func (m *Mutex) Unlock(ctx context.Context) bool {
 // 延长锁的时间
 func (m *Mutex) Extend(ctx context.Context) bool {
 	ctx, hk := m.before(ctx, "EXTEND")
-	result := m.Mutex.Extend()
-	var err error
-	if !result {
-		err = errors.New("Extend Fail")
+	err := hk.Err()
+	result := false
+	if err == nil {
+		result = m.Mutex.Extend()
+		if !result {
+			err = errors.New("Extend Fail")
+		}
 	}
 	m.after(hk, err)
 
@@ -82,11 +91,12 @@ func (m *Mutex) after(hk *hook.Hook, err error) {
 		state = StateSuccess
 	}
 	endTime := time.Now()
-	duration := endTime.Sub(hk.Arg(render.StartTimeArgKey).(time.Time))
+	duration := endTime.Sub(hk.Event().StartTime)
 
 	hk = hk.AddArg(render.EndTimeArgKey, endTime).
 		AddArg(render.DurationArgKey, duration).
 		AddArg("state", state).
 		AddArg(render.ErrorArgKey, err).
 		ProcessAfterHook()
+	hk.Release()
 }
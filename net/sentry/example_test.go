@@ -2,9 +2,12 @@ package sentry
 
 import (
 	"context"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"gitlab.shanhai.int/sre/library/base/ctime"
+	"gitlab.shanhai.int/sre/library/log"
 	"gitlab.shanhai.int/sre/library/net/errcode"
 )
 
@@ -95,3 +98,24 @@ func ExampleNewGinMiddleware() {
 
 	_ = app.Run(":3000")
 }
+
+func ExampleNewLogHandler() {
+	Init(&Config{
+		DSN:           "sentry_DSN",
+		Environment:   "prd",
+		ErrCodeFilter: []string{"code1", "code2"},
+	})
+
+	log.Init(&log.Config{
+		Handlers: []log.Handler{
+			NewLogHandler(&LogHandlerConfig{
+				Level:          "error",
+				Rate:           10,
+				DedupeInterval: ctime.Duration(time.Minute),
+			}),
+		},
+	})
+	defer log.Close()
+
+	log.Errorw(context.Background(), "query failed", log.Err(errcode.BreakerTimeoutError))
+}
@@ -0,0 +1,330 @@
+package sentry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	_context "gitlab.shanhai.int/sre/library/base/context"
+	"gitlab.shanhai.int/sre/library/base/ctime"
+	render "gitlab.shanhai.int/sre/library/base/logrender"
+	"gitlab.shanhai.int/sre/library/base/ratelimit"
+	"gitlab.shanhai.int/sre/library/base/slice"
+	"gitlab.shanhai.int/sre/library/log"
+	"gitlab.shanhai.int/sre/library/net/errcode"
+)
+
+const (
+	// 默认每秒上报的事件数
+	_defaultLogRate = 10
+	// 默认去重周期
+	_defaultDedupeInterval = time.Minute
+	// 指纹数量超过该值时清理过期指纹
+	_maxFingerprints = 4096
+
+	// 日志源标签
+	TagLogSource = "source"
+	// uuid标签
+	TagUUID = "uuid"
+	// 额外信息，去重周期内被忽略的重复事件数
+	ExtraSuppressed = "suppressed"
+)
+
+// 日志级别对应的sentry级别
+var logLevels = map[log.Level]sentry.Level{
+	log.DebugLevel: sentry.LevelDebug,
+	log.InfoLevel:  sentry.LevelInfo,
+	log.WarnLevel:  sentry.LevelWarning,
+	log.ErrorLevel: sentry.LevelError,
+	log.FatalLevel: sentry.LevelFatal,
+}
+
+// sentry日志处理器配置
+type LogHandlerConfig struct {
+	// 上报的最低日志级别，默认error
+	Level string `yaml:"level"`
+	// 每秒允许上报的事件数，默认10，小于0时不限流
+	Rate float64 `yaml:"rate"`
+	// 令牌桶容量，默认与每秒事件数相同
+	Burst int `yaml:"burst"`
+	// 相同指纹的事件去重周期，默认1m，小于0时不去重
+	DedupeInterval ctime.Duration `yaml:"dedupeInterval"`
+}
+
+// sentry日志处理器统计
+type LogHandlerStats struct {
+	// 上报的事件数
+	Captured uint64
+	// 因错误码过滤忽略的条数
+	Filtered uint64
+	// 因去重忽略的条数
+	Deduped uint64
+	// 因限流丢弃的条数
+	Limited uint64
+}
+
+// 指纹去重记录
+type dedupeEntry struct {
+	// 上次上报时间
+	last time.Time
+	// 上次上报后被忽略的条数
+	suppressed uint64
+}
+
+// sentry日志处理器
+//
+//	将不低于指定级别的日志转换为sentry事件，包含日志字段、uuid、日志源及错误码
+//	日志中包含error时以异常上报，并按错误码聚合，否则以消息上报，并按日志源及消息聚合
+//	遵循Config.ErrCodeFilter，相同指纹的事件在去重周期内只上报一次
+type LogHandler struct {
+	// 上报的最低日志级别
+	level log.Level
+	// 令牌桶，为空时不限流
+	limiter *ratelimit.TokenBucket
+	// 去重周期
+	dedupeInterval time.Duration
+
+	mu sync.Mutex
+	// 指纹去重记录
+	fingerprints map[string]*dedupeEntry
+
+	// 统计
+	captured uint64
+	filtered uint64
+	deduped  uint64
+	limited  uint64
+}
+
+// 新建sentry日志处理器，通过log.Config.Handlers注册
+func NewLogHandler(config *LogHandlerConfig) *LogHandler {
+	if config == nil {
+		config = &LogHandlerConfig{}
+	}
+	level := log.ErrorLevel
+	if config.Level != "" {
+		var err error
+		level, err = log.ParseLevel(config.Level)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	h := &LogHandler{
+		level:          level,
+		dedupeInterval: time.Duration(config.DedupeInterval),
+		fingerprints:   make(map[string]*dedupeEntry),
+	}
+	if h.dedupeInterval == 0 {
+		h.dedupeInterval = _defaultDedupeInterval
+	}
+	rate := config.Rate
+	if rate == 0 {
+		rate = _defaultLogRate
+	}
+	if rate > 0 {
+		h.limiter = ratelimit.NewTokenBucket(rate, config.Burst)
+	}
+
+	return h
+}
+
+func (h *LogHandler) Log(ctx context.Context, lv log.Level, args map[string]interface{}) {
+	if lv < h.level || !IsInit() {
+		return
+	}
+
+	msg, _ := args[log.MessageKey].(string)
+	source, _ := args[log.SourceKey].(string)
+	fields, _ := args[log.FieldsKey].([]log.Field)
+	err := findError(args, fields)
+
+	// 有错误时与beforeSend保持一致，按错误码聚合
+	var fingerprint []string
+	tags := make(map[string]string)
+	if err != nil {
+		var c errcode.Codes
+		if !errors.As(err, &c) {
+			c = errcode.Cause(err)
+		}
+		code := strconv.Itoa(c.Code())
+		if slice.StrSliceContains(_errCodeFilter, code) {
+			atomic.AddUint64(&h.filtered, 1)
+			return
+		}
+		tags[TagErrorCode] = code
+		fingerprint = []string{code, c.Message()}
+	} else {
+		fingerprint = []string{source, msg}
+	}
+
+	suppressed, ok := h.admit(fingerprint)
+	if !ok {
+		return
+	}
+
+	if source != "" {
+		tags[TagLogSource] = source
+	}
+	uuid := args[log.UUIDKey]
+	if uuid == nil {
+		uuid = ctx.Value(_context.ContextUUIDKey)
+	}
+	if uuid != nil {
+		tags[TagUUID] = fmt.Sprint(uuid)
+	}
+
+	extras := logExtras(ctx, args, fields)
+	if suppressed > 0 {
+		extras[ExtraSuppressed] = suppressed
+	}
+
+	level, ok := logLevels[lv]
+	if !ok {
+		level = sentry.LevelError
+	}
+
+	hub := getOrCloneHubFromCtx(ctx)
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetLevel(level)
+		scope.SetTags(tags)
+		scope.SetExtras(extras)
+		scope.SetFingerprint(fingerprint)
+		if err != nil {
+			scope.SetExtra(ExtraMessage, msg)
+			hub.CaptureException(err)
+		} else {
+			hub.CaptureMessage(msg)
+		}
+	})
+	atomic.AddUint64(&h.captured, 1)
+}
+
+// 判断是否需要上报，返回上次上报后被忽略的条数
+//
+//	先去重再限流，通过限流后才记录指纹，被限流的事件不影响后续同指纹事件的上报
+func (h *LogHandler) admit(fingerprint []string) (uint64, bool) {
+	now := time.Now()
+	if h.dedupeInterval < 0 {
+		return 0, h.allowRate(now)
+	}
+	key := fmt.Sprintf("%q", fingerprint)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if entry, ok := h.fingerprints[key]; ok {
+		if now.Sub(entry.last) < h.dedupeInterval {
+			entry.suppressed++
+			atomic.AddUint64(&h.deduped, 1)
+			return 0, false
+		}
+		if !h.allowRate(now) {
+			return 0, false
+		}
+		suppressed := entry.suppressed
+		entry.last = now
+		entry.suppressed = 0
+		return suppressed, true
+	}
+	if !h.allowRate(now) {
+		return 0, false
+	}
+
+	// 清理过期指纹
+	if len(h.fingerprints) >= _maxFingerprints {
+		for k, entry := range h.fingerprints {
+			if now.Sub(entry.last) >= h.dedupeInterval {
+				delete(h.fingerprints, k)
+			}
+		}
+	}
+	h.fingerprints[key] = &dedupeEntry{last: now}
+	return 0, true
+}
+
+// 判断是否通过限流
+func (h *LogHandler) allowRate(now time.Time) bool {
+	if h.limiter != nil && !h.limiter.Allow(now) {
+		atomic.AddUint64(&h.limited, 1)
+		return false
+	}
+	return true
+}
+
+// 获取统计数据
+func (h *LogHandler) Stats() LogHandlerStats {
+	return LogHandlerStats{
+		Captured: atomic.LoadUint64(&h.captured),
+		Filtered: atomic.LoadUint64(&h.filtered),
+		Deduped:  atomic.LoadUint64(&h.deduped),
+		Limited:  atomic.LoadUint64(&h.limited),
+	}
+}
+
+func (h *LogHandler) SetFormat(string) {}
+
+// 关闭，等待已上报的事件发送完成
+func (h *LogHandler) Close() error {
+	if IsInit() {
+		sentry.Flush(DefaultFlushTimeout)
+	}
+	return nil
+}
+
+// 查找日志中的错误，优先使用类型字段
+func findError(args map[string]interface{}, fields []log.Field) error {
+	for _, f := range fields {
+		if err, ok := f.Value().(error); ok && err != nil {
+			return err
+		}
+	}
+
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err, ok := args[k].(error); ok && err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 日志字段及上下文字段作为额外信息
+func logExtras(ctx context.Context, args map[string]interface{}, fields []log.Field) map[string]interface{} {
+	extras := make(map[string]interface{}, len(args)+len(fields))
+	contextFields, ok := args[render.ContextFieldsArgKey].(map[string]interface{})
+	if !ok {
+		contextFields = _context.GetLogFields(ctx)
+	}
+	for k, v := range contextFields {
+		extras[k] = v
+	}
+	for k, v := range args {
+		// 日志库内部键不作为额外信息上报
+		if log.IsInternalKey(k) {
+			continue
+		}
+		extras[k] = extraValue(v)
+	}
+	for _, f := range fields {
+		extras[f.Key()] = extraValue(f.Value())
+	}
+	return extras
+}
+
+// 额外信息的值，错误转换为字符串
+func extraValue(v interface{}) interface{} {
+	if err, ok := v.(error); ok {
+		return err.Error()
+	}
+	return v
+}
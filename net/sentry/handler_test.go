@@ -0,0 +1,184 @@
+package sentry
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	_context "gitlab.shanhai.int/sre/library/base/context"
+	"gitlab.shanhai.int/sre/library/base/ratelimit"
+	"gitlab.shanhai.int/sre/library/log"
+	"gitlab.shanhai.int/sre/library/net/errcode"
+)
+
+// 错误码只能注册一次，在包级别声明
+var (
+	_customErr   = errcode.New(2990001, "custom")
+	_filteredErr = errcode.New(2990002, "filtered")
+)
+
+type recordTransport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *recordTransport) Flush(time.Duration) bool { return true }
+
+func (t *recordTransport) Configure(sentry.ClientOptions) {}
+
+func (t *recordTransport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+// 绑定记录事件的客户端，返回恢复方法
+func bindRecordClient(t *testing.T) (*recordTransport, func()) {
+	transport := new(recordTransport)
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Transport: transport,
+		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			beforeSend(event, hint)
+			return event
+		},
+	})
+	assert.Nil(t, err)
+
+	hub := sentry.CurrentHub()
+	old := hub.Client()
+	hub.BindClient(client)
+	return transport, func() {
+		hub.BindClient(old)
+	}
+}
+
+func TestLogHandler_Log(t *testing.T) {
+	t.Run("message", func(t *testing.T) {
+		transport, restore := bindRecordClient(t)
+		defer restore()
+		h := NewLogHandler(nil)
+
+		ctx := context.WithValue(context.Background(), _context.ContextUUIDKey, "u1")
+		ctx = _context.WithLogField(ctx, "tenant", "t1")
+		h.Log(ctx, log.InfoLevel, map[string]interface{}{"log": "ignored"})
+		h.Log(ctx, log.ErrorLevel, map[string]interface{}{
+			log.MessageKey: "bad thing",
+			log.SourceKey:  "a.go:1",
+			"user":         "u2",
+			log.FieldsKey:  []log.Field{log.Int("count", 3)},
+		})
+
+		assert.Equal(t, 1, len(transport.events))
+		event := transport.events[0]
+		assert.Equal(t, "bad thing", event.Message)
+		assert.Equal(t, sentry.LevelError, event.Level)
+		assert.Equal(t, "u1", event.Tags[TagUUID])
+		assert.Equal(t, "a.go:1", event.Tags[TagLogSource])
+		assert.Equal(t, "u2", event.Extra["user"])
+		assert.Equal(t, int64(3), event.Extra["count"])
+		assert.Equal(t, "t1", event.Extra["tenant"])
+		// 日志库内部键不作为额外信息上报
+		assert.NotContains(t, event.Extra, log.SourceKey)
+		assert.NotContains(t, event.Extra, log.FieldsKey)
+		assert.Equal(t, []string{"a.go:1", "bad thing"}, event.Fingerprint)
+	})
+
+	t.Run("errcode", func(t *testing.T) {
+		transport, restore := bindRecordClient(t)
+		defer restore()
+		h := NewLogHandler(nil)
+
+		err := errors.Wrap(_customErr, "wrap")
+		h.Log(context.Background(), log.ErrorLevel, map[string]interface{}{
+			"log":        "failed",
+			"log_fields": []log.Field{log.Err(err)},
+		})
+
+		assert.Equal(t, 1, len(transport.events))
+		event := transport.events[0]
+		assert.Equal(t, "2990001", event.Tags[TagErrorCode])
+		assert.Equal(t, []string{"2990001", "custom"}, event.Fingerprint)
+		assert.Equal(t, "failed", event.Extra[ExtraMessage])
+		assert.Equal(t, "wrap: 2990001:custom", event.Extra["error"])
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		transport, restore := bindRecordClient(t)
+		defer restore()
+		old := _errCodeFilter
+		_errCodeFilter = []string{"2990002"}
+		defer func() {
+			_errCodeFilter = old
+		}()
+		h := NewLogHandler(nil)
+
+		h.Log(context.Background(), log.ErrorLevel, map[string]interface{}{
+			"log": "failed",
+			"err": _filteredErr,
+		})
+
+		assert.Equal(t, 0, len(transport.events))
+		assert.Equal(t, LogHandlerStats{Filtered: 1}, h.Stats())
+	})
+
+	t.Run("dedupe", func(t *testing.T) {
+		transport, restore := bindRecordClient(t)
+		defer restore()
+		h := NewLogHandler(&LogHandlerConfig{Rate: -1})
+
+		for i := 0; i < 5; i++ {
+			h.Log(context.Background(), log.ErrorLevel, map[string]interface{}{"log": "same"})
+		}
+		h.Log(context.Background(), log.ErrorLevel, map[string]interface{}{"log": "other"})
+		assert.Equal(t, 2, len(transport.events))
+		assert.Equal(t, LogHandlerStats{Captured: 2, Deduped: 4}, h.Stats())
+
+		// 超过去重周期后重新上报，并带上被忽略的条数
+		h.mu.Lock()
+		for _, entry := range h.fingerprints {
+			entry.last = entry.last.Add(-2 * _defaultDedupeInterval)
+		}
+		h.mu.Unlock()
+		h.Log(context.Background(), log.ErrorLevel, map[string]interface{}{"log": "same"})
+		assert.Equal(t, 3, len(transport.events))
+		assert.Equal(t, uint64(4), transport.events[2].Extra[ExtraSuppressed])
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		transport, restore := bindRecordClient(t)
+		defer restore()
+		h := NewLogHandler(&LogHandlerConfig{Rate: 1, Burst: 2, DedupeInterval: -1})
+
+		for i := 0; i < 5; i++ {
+			h.Log(context.Background(), log.ErrorLevel, map[string]interface{}{"log": "same"})
+		}
+		assert.Equal(t, 2, len(transport.events))
+		assert.Equal(t, LogHandlerStats{Captured: 2, Limited: 3}, h.Stats())
+	})
+
+	t.Run("limited not deduped", func(t *testing.T) {
+		transport, restore := bindRecordClient(t)
+		defer restore()
+		h := NewLogHandler(&LogHandlerConfig{Rate: 1, Burst: 1})
+
+		h.Log(context.Background(), log.ErrorLevel, map[string]interface{}{"log": "first"})
+		h.Log(context.Background(), log.ErrorLevel, map[string]interface{}{"log": "second"})
+		assert.Equal(t, LogHandlerStats{Captured: 1, Limited: 1}, h.Stats())
+
+		// 被限流的事件未记录指纹，恢复令牌后同指纹事件正常上报
+		h.limiter = ratelimit.NewTokenBucket(1, 1)
+		h.Log(context.Background(), log.ErrorLevel, map[string]interface{}{"log": "second"})
+		assert.Equal(t, 2, len(transport.events))
+		assert.Equal(t, LogHandlerStats{Captured: 2, Limited: 1}, h.Stats())
+	})
+
+	t.Run("not init", func(t *testing.T) {
+		h := NewLogHandler(nil)
+		h.Log(context.Background(), log.ErrorLevel, map[string]interface{}{"log": "failed"})
+		assert.Equal(t, LogHandlerStats{}, h.Stats())
+	})
+}
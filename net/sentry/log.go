@@ -46,7 +46,7 @@ func getDefaultLogger(config *Config) *Logger {
 	if config.Stdout {
 		writers = append(writers, LogWriter{
 			Writer: os.Stdout,
-			Render: render.NewPatternRender(patternMap, config.StdoutPattern),
+			Render: render.NewRender(config.Format, patternMap, config.StdoutPattern, config.Options()...),
 		})
 	}
 	if config.OutDir != "" {
@@ -56,7 +56,7 @@ func getDefaultLogger(config *Config) *Logger {
 		)
 		writers = append(writers, LogWriter{
 			Writer: fw,
-			Render: render.NewPatternRender(patternMap, config.OutPattern),
+			Render: render.NewRender(config.Format, patternMap, config.OutPattern, config.Options()...),
 		})
 	}
 
@@ -20,6 +20,8 @@ const (
 
 var (
 	_logger logger
+	// 错误码过滤器
+	_errCodeFilter []string
 )
 
 // 初始化sentry
@@ -46,6 +48,7 @@ func Init(config *Config) {
 		config.Tags = make(map[string]string)
 	}
 	_logger = getDefaultLogger(config)
+	_errCodeFilter = config.ErrCodeFilter
 
 	// 创建hub
 	err := sentry.Init(sentry.ClientOptions{
@@ -60,7 +60,7 @@ func getDefaultWriter(conf *Config) *Logger {
 	if conf.Stdout {
 		writers = append(writers, LogWriter{
 			Writer: os.Stdout,
-			Render: render.NewPatternRender(patternMap, conf.StdoutPattern),
+			Render: render.NewRender(conf.Format, patternMap, conf.StdoutPattern, conf.Options()...),
 		})
 	}
 	if conf.OutDir != "" {
@@ -70,7 +70,7 @@ func getDefaultWriter(conf *Config) *Logger {
 		)
 		writers = append(writers, LogWriter{
 			Writer: fw,
-			Render: render.NewPatternRender(patternMap, conf.OutPattern),
+			Render: render.NewRender(conf.Format, patternMap, conf.OutPattern, conf.Options()...),
 		})
 	}
 
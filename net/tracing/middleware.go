@@ -39,6 +39,8 @@ func ExtractFromUpstream(advancedOpts ...opentracing.StartSpanOption) gin.Handle
 			traceID = sc.TraceID().String()
 		}
 		ctx.Set(TraceIDContextKey, traceID)
+		// 自动输出到log包及组件日志
+		_context.SetLogField(ctx, TraceIDLogField, traceID)
 
 		ctx.Next()
 
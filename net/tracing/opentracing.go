@@ -17,6 +17,8 @@ const (
 
 	// context中存放trace id的key
 	TraceIDContextKey = "context_trace_id"
+	// trace id的日志字段名
+	TraceIDLogField = "trace_id"
 )
 
 const (
@@ -28,6 +28,7 @@ type spanInfo struct {
 // 新建钩子管理器
 func NewHookManager(renderConfig *render.Config) *hook.Manager {
 	return hook.NewManager().
+		SetComponent(hook.ComponentQueue).
 		RegisterLogHook(renderConfig, patternMap)
 }
 